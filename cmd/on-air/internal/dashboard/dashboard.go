@@ -0,0 +1,36 @@
+// Package dashboard serves the embedded single-page web dashboard.
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// AssetsPrefix is the path the dashboard assets are served under.
+const AssetsPrefix = "/dashboard/"
+
+func assets() fs.FS {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		// the embedded directory is known at compile time
+		panic(err)
+	}
+	return sub
+}
+
+// Index serves the dashboard page.
+func Index() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFileFS(w, r, assets(), "index.html")
+	}
+}
+
+// Assets serves the dashboard scripts and stylesheets under AssetsPrefix.
+func Assets() http.Handler {
+	return http.StripPrefix(AssetsPrefix, http.FileServer(http.FS(assets())))
+}
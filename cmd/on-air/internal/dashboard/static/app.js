@@ -0,0 +1,192 @@
+(function () {
+  "use strict";
+
+  var historyLimit = 20;
+  var current = null;
+  var stream = null;
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  function api(method, path, body) {
+    var opts = { method: method, credentials: "same-origin", headers: {} };
+    if (body !== undefined) {
+      opts.headers["Content-Type"] = "application/json";
+      opts.body = JSON.stringify(body);
+    }
+    return fetch(path, opts).then(function (resp) {
+      if (resp.status === 401) {
+        showLogin();
+        throw new Error("unauthorized");
+      }
+      if (!resp.ok) {
+        return resp.json().then(function (e) {
+          throw new Error(typeof e.error === "string" ? e.error : resp.statusText);
+        });
+      }
+      return resp.status === 204 ? null : resp.json();
+    });
+  }
+
+  function showError(err) {
+    if (err.message === "unauthorized") {
+      return;
+    }
+    $("error").textContent = err.message;
+    $("error").hidden = false;
+  }
+
+  function since(ts) {
+    var mins = Math.floor((Date.now() - new Date(ts).getTime()) / 60000);
+    if (mins < 1) return "just now";
+    if (mins < 60) return mins + "m ago";
+    var hours = Math.floor(mins / 60);
+    if (hours < 24) return hours + "h " + (mins % 60) + "m ago";
+    return Math.floor(hours / 24) + "d " + (hours % 24) + "h ago";
+  }
+
+  function renderStatus(s) {
+    current = s;
+    $("status").className = "status " + (s.is_on_air ? "on" : "off");
+    $("status-label").textContent = s.is_on_air ? "On air" : "Off air";
+    $("status-since").textContent = (s.message ? s.message + " · " : "") +
+      (s.last_updated ? "since " + since(s.last_updated) : "");
+    $("toggle").textContent = s.is_on_air ? "Go off air" : "Go on air";
+    if (document.activeElement !== $("message")) {
+      $("message").value = s.message || "";
+    }
+  }
+
+  function renderHistory(items) {
+    var list = $("history");
+    list.textContent = "";
+    items.forEach(function (t) {
+      var li = document.createElement("li");
+      var at = document.createElement("time");
+      at.dateTime = t.at;
+      at.textContent = new Date(t.at).toLocaleString();
+      var state = document.createElement("span");
+      state.className = t.is_on_air ? "on" : "off";
+      state.textContent = t.is_on_air ? "On air" : "Off air";
+      var msg = document.createElement("span");
+      msg.textContent = t.message || "";
+      li.append(at, state, msg);
+      list.append(li);
+    });
+  }
+
+  function renderSchedules(items) {
+    var list = $("schedules");
+    list.textContent = "";
+    if (items.length === 0) {
+      var empty = document.createElement("li");
+      empty.textContent = "Nothing scheduled";
+      list.append(empty);
+      return;
+    }
+    items.forEach(function (s) {
+      var li = document.createElement("li");
+      var at = document.createElement("time");
+      at.dateTime = s.start;
+      at.textContent = new Date(s.start).toLocaleString() + " – " +
+        new Date(s.end).toLocaleTimeString();
+      var msg = document.createElement("span");
+      msg.textContent = s.message || "";
+      li.append(at, msg);
+      list.append(li);
+    });
+  }
+
+  function refreshSchedules() {
+    return api("GET", "/v1/schedules").then(renderSchedules).catch(showError);
+  }
+
+  function refreshHistory() {
+    return api("GET", "/v1/history?limit=" + historyLimit).then(renderHistory).catch(showError);
+  }
+
+  function listen() {
+    if (stream) {
+      stream.close();
+    }
+    stream = new EventSource("/v1/onAir/stream");
+    stream.addEventListener("status", function (e) {
+      renderStatus(JSON.parse(e.data));
+      refreshHistory();
+      refreshSchedules();
+    });
+  }
+
+  function showLogin() {
+    if (stream) {
+      stream.close();
+      stream = null;
+    }
+    $("app").hidden = true;
+    $("login").hidden = false;
+  }
+
+  function start() {
+    return api("GET", "/v1/onAir").then(function (s) {
+      $("login").hidden = true;
+      $("app").hidden = false;
+      renderStatus(s);
+      refreshHistory();
+      refreshSchedules();
+      // the link stays hidden when the feed is disabled
+      api("GET", "/v1/calendar/token").then(function (c) {
+        $("calendar").href = c.url.replace(/^https?:/, "webcal:");
+        $("calendar").hidden = false;
+      }).catch(function () {});
+      listen();
+    }).catch(showError);
+  }
+
+  $("login-form").addEventListener("submit", function (e) {
+    e.preventDefault();
+    $("login-error").hidden = true;
+    fetch("/login", {
+      method: "POST",
+      credentials: "same-origin",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ api_key: $("api-key").value })
+    }).then(function (resp) {
+      if (!resp.ok) {
+        throw new Error("Invalid API key");
+      }
+      $("api-key").value = "";
+      return start();
+    }).catch(function (err) {
+      $("login-error").textContent = err.message;
+      $("login-error").hidden = false;
+    });
+  });
+
+  $("logout").addEventListener("click", function () {
+    api("POST", "/logout").then(showLogin).catch(showError);
+  });
+
+  $("toggle").addEventListener("click", function () {
+    $("error").hidden = true;
+    api("POST", "/v1/toggle").then(renderStatus).catch(showError);
+  });
+
+  $("message-form").addEventListener("submit", function (e) {
+    e.preventDefault();
+    $("error").hidden = true;
+    api("POST", "/v1/onAir", {
+      is_on_air: current ? current.is_on_air : false,
+      message: $("message").value
+    }).then(renderStatus).catch(showError);
+  });
+
+  // keep the relative times fresh
+  setInterval(function () {
+    if (current) {
+      renderStatus(current);
+    }
+  }, 30000);
+
+  start();
+})();
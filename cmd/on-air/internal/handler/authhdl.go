@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"on-air/cmd/on-air/internal/middleware"
+	"on-air/internal/acontext"
+	"on-air/internal/entities"
+	"on-air/internal/service/auth"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"time"
+)
+
+type loginBody struct {
+	APIKey string `json:"api_key"`
+}
+
+type sessionResponse struct {
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Login exchanges an API key for a session cookie.
+func Login(wl wlog.Logger, authService auth.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var loginReq loginBody
+		if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
+			render.BadRequest(ctx, wl, w, render.ErrJSONDecode)
+			return
+		}
+
+		user, err := authService.Authenticate(ctx, wl, loginReq.APIKey)
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			render.Unauthorized(ctx, wl, w, err)
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		token, expires, err := authService.NewSession(ctx, wl, user)
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		http.SetCookie(w, sessionCookie(r, token, expires))
+		render.JSON(ctx, wl, w, sessionResponse{UserID: user.ID, ExpiresAt: expires}, http.StatusOK)
+	}
+}
+
+// Logout clears the session cookie.
+func Logout(wl wlog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, sessionCookie(r, "", time.Unix(0, 0)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func sessionCookie(r *http.Request, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     middleware.SessionCookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		// cloud run terminates TLS in front of the service
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+type permissionsV1 struct {
+	UserID   *string             `json:"user_id"`
+	Role     string              `json:"role"`
+	Actions  []string            `json:"actions"`
+	Channels map[string][]string `json:"channels"`
+}
+
+// GetPermissions returns the effective permissions of the caller.
+func GetPermissions(wl wlog.Logger, authService auth.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var userID string
+		if authService.Enabled() {
+			id, err := acontext.UserID(ctx)
+			if err != nil {
+				render.InternalError(ctx, wl, w, err)
+				return
+			}
+			userID = id
+		}
+
+		perms, err := authService.Permissions(ctx, wl, userID)
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		render.JSON(ctx, wl, w, newPermissionsV1(perms), http.StatusOK)
+	}
+}
+
+func newPermissionsV1(perms entities.Permissions) permissionsV1 {
+	resp := permissionsV1{
+		Role:     string(perms.Role),
+		Actions:  actionsV1(perms.Actions),
+		Channels: make(map[string][]string, len(perms.Channels)),
+	}
+	if perms.UserID != "" {
+		resp.UserID = &perms.UserID
+	}
+	for c, actions := range perms.Channels {
+		resp.Channels[c] = actionsV1(actions)
+	}
+	return resp
+}
+
+func actionsV1(actions []entities.Action) []string {
+	// always render an array, never null
+	s := make([]string, 0, len(actions))
+	for _, a := range actions {
+		s = append(s, string(a))
+	}
+	return s
+}
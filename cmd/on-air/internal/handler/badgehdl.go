@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"on-air/internal/badge"
+	"on-air/internal/service/channel"
+	"on-air/internal/service/group"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"strings"
+	"time"
+)
+
+// BadgeSVG renders the status of the default channel, or of the channel or
+// group query parameter, as an SVG badge.
+func BadgeSVG(wl wlog.Logger, channelService channel.SVC, groupService group.SVC) http.HandlerFunc {
+	return badgeHandler(wl, channelService, groupService, "svg", badge.Badge.SVG, render.ImageSVG)
+}
+
+// BadgePNG renders the status of the default channel, or of the channel or
+// group query parameter, as a PNG badge.
+func BadgePNG(wl wlog.Logger, channelService channel.SVC, groupService group.SVC) http.HandlerFunc {
+	return badgeHandler(wl, channelService, groupService, "png", badge.Badge.PNG, render.ImagePNG)
+}
+
+type badgeEncoder func(badge.Badge) ([]byte, error)
+
+type badgeWriter func(ctx context.Context, wl wlog.Logger, w http.ResponseWriter, b []byte)
+
+func badgeHandler(
+	wl wlog.Logger,
+	channelService channel.SVC,
+	groupService group.SVC,
+	format string,
+	encode badgeEncoder,
+	write badgeWriter,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		q := r.URL.Query()
+		opts := badge.Options{
+			Style: badge.Style(q.Get("style")),
+			Label: q.Get("label"),
+			Size:  badge.Size(q.Get("size")),
+		}
+		if err := opts.Validate(); err != nil {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+
+		if q.Get("channel") != "" && q.Get("group") != "" {
+			render.BadRequest(ctx, wl, w, render.NewErrorStr("channel and group can't both be set"))
+			return
+		}
+
+		var onAirService onair.Reader
+		var err error
+		if id := q.Get("group"); id != "" {
+			onAirService, err = groupService.Get(ctx, wl, id)
+		} else {
+			id = q.Get("channel")
+			if id == "" {
+				id = onair.DefaultChannel
+			}
+			onAirService, err = channelService.Get(ctx, wl, id)
+		}
+		if errors.Is(err, channel.ErrChannelNotFound) || errors.Is(err, group.ErrGroupNotFound) {
+			render.NotFound(ctx, wl, w, err)
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		onAirStatus, err := onAirService.GetOnAirStatus(ctx, wl)
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		now := time.Now()
+		b := badge.New(onAirStatus, opts, now)
+
+		// the badge shows a duration with a minute granularity, so it can be
+		// cached until the next minute boundary since the last update
+		maxAge := time.Minute
+		if onAirStatus.LastUpdated.Valid {
+			maxAge -= now.Sub(onAirStatus.LastUpdated.Time) % time.Minute
+			w.Header().Set("Last-Modified", onAirStatus.LastUpdated.Time.UTC().Format(http.TimeFormat))
+		}
+		etag := badgeETag(b, format)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, must-revalidate", int(maxAge.Seconds())))
+
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		img, err := encode(b)
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		write(ctx, wl, w, img)
+	}
+}
+
+// badgeETag derives an entity tag from everything that is drawn on the badge.
+func badgeETag(b badge.Badge, format string) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s|%s|%d", format, b.Label, b.Value, b.Color, b.Style, b.Scale)
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+func etagMatch(header string, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"on-air/internal/acontext"
+	"on-air/internal/entities"
+	"on-air/internal/ical"
+	"on-air/internal/service/auth"
+	"on-air/internal/service/onair"
+	"on-air/internal/service/schedule"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/guregu/null"
+)
+
+const (
+	calendarProdID      = "-//on-air//on-air//EN"
+	calendarName        = "On Air"
+	calendarUIDDomain   = "on-air"
+	defaultCalendarDays = 90
+	maxCalendarDays     = 366
+)
+
+type calendarTokenV1 struct {
+	Token *string `json:"token"`
+	URL   string  `json:"url"`
+}
+
+// Calendar serves the completed sessions of the last days and the upcoming
+// schedules as an iCalendar feed. Calendar apps can't send headers so the
+// feed is authenticated with a feed token in the query. It's not found
+// unless the feed is enabled.
+func Calendar(
+	wl wlog.Logger,
+	authService auth.SVC,
+	onAirService onair.SVC,
+	scheduleService schedule.SVC,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		q := r.URL.Query()
+
+		if !authService.FeedEnabled() {
+			render.NotFound(ctx, wl, w, auth.ErrFeedDisabled)
+			return
+		}
+
+		if authService.Enabled() {
+			if _, err := authService.VerifyFeedToken(ctx, wl, q.Get("token")); err != nil {
+				render.Unauthorized(ctx, wl, w, err)
+				return
+			}
+		}
+
+		days := defaultCalendarDays
+		tz := q.Get("tz")
+		errs := validation.Errors{}
+		if v := q.Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxCalendarDays {
+				errs["days"] = errors.New("must be a number of days between 1 and 366")
+			}
+			days = n
+		}
+		if tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil {
+				errs["tz"] = errors.New("must be a valid IANA timezone")
+			}
+		}
+		if err := errs.Filter(); err != nil {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+
+		now := time.Now()
+		cal := ical.Calendar{
+			ProdID:   calendarProdID,
+			Name:     calendarName,
+			TimeZone: tz,
+		}
+
+		sessionFilter := entities.SessionFilter{From: null.TimeFrom(now.AddDate(0, 0, -days))}
+		err := onAirService.EachSession(ctx, wl, sessionFilter, func(s entities.Session) error {
+			// running sessions only show up once they're over so their end
+			// never changes
+			if s.End.Valid {
+				cal.Events = append(cal.Events, sessionEvent(s))
+			}
+			return nil
+		})
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		schedules, err := scheduleService.ListSchedules(ctx, wl, entities.ScheduleFilter{From: null.TimeFrom(now)})
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+		for _, s := range schedules {
+			cal.Events = append(cal.Events, scheduleEvent(s))
+		}
+
+		w.Header().Set("Content-Type", ical.ContentType)
+		w.Header().Set("Content-Disposition", `inline; filename="on-air.ics"`)
+		if err := cal.Encode(w); err != nil {
+			wl.Error(err)
+		}
+	}
+}
+
+// GetCalendarToken returns the feed URL of the calendar for the caller.
+func GetCalendarToken(wl wlog.Logger, authService auth.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if !authService.FeedEnabled() {
+			render.NotFound(ctx, wl, w, auth.ErrFeedDisabled)
+			return
+		}
+
+		feed := url.URL{Scheme: "http", Host: r.Host, Path: "/calendar.ics"}
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			feed.Scheme = "https"
+		}
+
+		resp := calendarTokenV1{}
+		if authService.Enabled() {
+			userID, err := acontext.UserID(ctx)
+			if err != nil {
+				render.InternalError(ctx, wl, w, err)
+				return
+			}
+
+			token, err := authService.NewFeedToken(ctx, wl, entities.User{ID: userID})
+			if err != nil {
+				render.InternalError(ctx, wl, w, err)
+				return
+			}
+
+			resp.Token = &token
+			feed.RawQuery = url.Values{"token": {token}}.Encode()
+		}
+		resp.URL = feed.String()
+
+		render.JSON(ctx, wl, w, resp, http.StatusOK)
+	}
+}
+
+func sessionEvent(s entities.Session) ical.Event {
+	summary := "On air"
+	if line, _, _ := strings.Cut(s.Notes, "\n"); line != "" {
+		summary += ": " + line
+	}
+
+	return ical.Event{
+		UID:         "session-" + s.ID + "@" + calendarUIDDomain,
+		Stamp:       s.End.Time,
+		Start:       s.Start,
+		End:         s.End.Time,
+		Summary:     summary,
+		Description: s.Notes,
+		Categories:  s.Tags,
+		Status:      ical.StatusConfirmed,
+	}
+}
+
+func scheduleEvent(s entities.Schedule) ical.Event {
+	summary := "Scheduled on air"
+	if s.Message != "" {
+		summary += ": " + s.Message
+	}
+
+	return ical.Event{
+		UID:         "schedule-" + s.ID + "@" + calendarUIDDomain,
+		Stamp:       s.CreatedAt,
+		Start:       s.Start,
+		End:         s.End,
+		Summary:     summary,
+		Description: s.Message,
+		Status:      ical.StatusConfirmed,
+	}
+}
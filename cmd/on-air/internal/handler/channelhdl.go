@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"on-air/internal/service/channel"
+	"on-air/internal/service/group"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+
+	"github.com/gorilla/mux"
+)
+
+// ForChannel serves the request with the handler built for the channel
+// of the path.
+func ForChannel(wl wlog.Logger, channelService channel.SVC, h func(onair.SVC) http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		onAirService, err := channelService.Get(ctx, wl, mux.Vars(r)["channel"])
+		if errors.Is(err, channel.ErrChannelNotFound) {
+			render.NotFound(ctx, wl, w, err)
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		h(onAirService).ServeHTTP(w, r)
+	}
+}
+
+// ForGroup serves the request with the handler built for the group of the
+// path. Groups are read-only.
+func ForGroup(wl wlog.Logger, groupService group.SVC, h func(onair.Reader) http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		g, err := groupService.Get(ctx, wl, mux.Vars(r)["group"])
+		if errors.Is(err, group.ErrGroupNotFound) {
+			render.NotFound(ctx, wl, w, err)
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		h(g).ServeHTTP(w, r)
+	}
+}
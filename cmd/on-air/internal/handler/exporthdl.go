@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"on-air/internal/entities"
+	"on-air/internal/export"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/guregu/null"
+)
+
+// Exportable item types.
+const (
+	exportSessions    = "sessions"
+	exportTransitions = "transitions"
+)
+
+// exportFlushEvery is the number of items written between flushes.
+const exportFlushEvery = 100
+
+type exportQuery struct {
+	Type     string
+	Format   export.Format
+	Location *time.Location
+	Columns  []string
+	Filter   entities.SessionFilter
+}
+
+// Export streams the sessions or transitions in the from and to range as
+// CSV, JSON or NDJSON, with the selected columns and timestamps in tz.
+func Export(wl wlog.Logger, onAirService onair.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		q, err := parseExportQuery(r)
+		if err != nil {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+
+		fw := &flushWriter{w: w}
+		if f, ok := w.(http.Flusher); ok {
+			fw.f = f
+		}
+
+		switch q.Type {
+		case exportTransitions:
+			err = streamExport(w, fw, q, export.TransitionColumns, func(fn func(entities.Transition) error) error {
+				return onAirService.EachTransition(ctx, wl, q.Filter.From, q.Filter.To, fn)
+			})
+		default:
+			err = streamExport(w, fw, q, export.SessionColumns, func(fn func(entities.Session) error) error {
+				return onAirService.EachSession(ctx, wl, q.Filter, fn)
+			})
+		}
+
+		var colErr *exportColumnsError
+		if errors.As(err, &colErr) {
+			render.BadRequest(ctx, wl, w, validation.Errors{"columns": colErr.err})
+			return
+		}
+		if err != nil {
+			// the status has already been sent, the export is truncated
+			wl.Error(fmt.Errorf("error streaming export: %w", err))
+		}
+	}
+}
+
+// exportColumnsError is returned before anything is written when the
+// requested columns are not valid.
+type exportColumnsError struct {
+	err error
+}
+
+func (e *exportColumnsError) Error() string {
+	return e.err.Error()
+}
+
+func streamExport[T any](
+	w http.ResponseWriter,
+	fw *flushWriter,
+	q exportQuery,
+	all []export.Column[T],
+	each func(fn func(T) error) error,
+) error {
+	columns, err := export.SelectColumns(all, q.Columns)
+	if err != nil {
+		return &exportColumnsError{err: err}
+	}
+
+	ew, err := export.NewWriter(fw, q.Format, columns, q.Location)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", q.Format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(
+		"attachment; filename=\"on-air-%s.%s\"", q.Type, q.Format))
+	w.WriteHeader(http.StatusOK)
+
+	if err := ew.Begin(); err != nil {
+		return err
+	}
+
+	n := 0
+	err = each(func(item T) error {
+		if err := ew.Write(item); err != nil {
+			return err
+		}
+		n++
+		if n%exportFlushEvery == 0 {
+			fw.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := ew.End(); err != nil {
+		return err
+	}
+	fw.Flush()
+
+	return nil
+}
+
+func parseExportQuery(r *http.Request) (exportQuery, error) {
+	qs := r.URL.Query()
+	errs := validation.Errors{}
+
+	q := exportQuery{
+		Type:     qs.Get("type"),
+		Format:   export.Format(qs.Get("format")),
+		Location: time.UTC,
+		Filter:   entities.SessionFilter{Tag: qs.Get("tag")},
+	}
+	if q.Type == "" {
+		q.Type = exportSessions
+	}
+	if q.Format == "" {
+		q.Format = export.FormatCSV
+	}
+	if c := qs.Get("columns"); c != "" {
+		q.Columns = strings.Split(c, ",")
+	}
+
+	if err := validation.Validate(q.Type, validation.In(exportSessions, exportTransitions)); err != nil {
+		errs["type"] = err
+	}
+	if err := validation.Validate(q.Format, validation.In(export.FormatCSV, export.FormatJSON, export.FormatNDJSON)); err != nil {
+		errs["format"] = err
+	}
+
+	if tz := qs.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			errs["tz"] = errors.New("must be a valid IANA timezone")
+		} else {
+			q.Location = loc
+		}
+	}
+
+	for name, dst := range map[string]*null.Time{"from": &q.Filter.From, "to": &q.Filter.To} {
+		if v := qs.Get(name); v != "" {
+			t, err := parseTime(v, q.Location)
+			if err != nil {
+				errs[name] = err
+				continue
+			}
+			*dst = null.TimeFrom(t)
+		}
+	}
+
+	if err := errs.Filter(); err != nil {
+		return exportQuery{}, err
+	}
+
+	return q, nil
+}
+
+// flushWriter writes to the response and flushes it on demand.
+type flushWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+func (fw *flushWriter) Write(b []byte) (int, error) {
+	return fw.w.Write(b)
+}
+
+func (fw *flushWriter) Flush() {
+	if fw.f != nil {
+		fw.f.Flush()
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/guregu/null"
+)
+
+const maxLockReasonLen = 200
+
+type lockBody struct {
+	Reason    string    `json:"reason"`
+	ExpiresAt null.Time `json:"expires_at"`
+}
+
+// Validate makes sure the lock is valid.
+// It returns an error when the lock is not valid.
+func (b *lockBody) Validate() error {
+	return validation.ValidateStruct(
+		b,
+		validation.Field(&b.Reason, validation.RuneLength(0, maxLockReasonLen)),
+		validation.Field(&b.ExpiresAt, validation.By(inFuture)),
+	)
+}
+
+func inFuture(value interface{}) error {
+	t, _ := value.(null.Time)
+	if t.Valid && !t.Time.After(time.Now()) {
+		return errors.New("must be in the future")
+	}
+	return nil
+}
+
+// LockOnAirStatus locks the status so only the caller and the admins can
+// set or toggle it, until it's unlocked or expires_at. The body is optional.
+func LockOnAirStatus(wl wlog.Logger, onAirService onair.SVC, p Presenter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var body lockBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			render.BadRequest(ctx, wl, w, render.ErrJSONDecode)
+			return
+		}
+		if err := body.Validate(); err != nil {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+
+		onAir, err := onAirService.Lock(ctx, wl, body.Reason, body.ExpiresAt)
+		if errors.Is(err, onair.ErrLocked) {
+			render.Conflict(ctx, wl, w, render.NewError(err))
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		render.JSON(ctx, wl, w, p.Status(onAir), http.StatusOK)
+	}
+}
+
+// UnlockOnAirStatus releases the lock. Unlocking a status that isn't locked
+// is a no-op.
+func UnlockOnAirStatus(wl wlog.Logger, onAirService onair.SVC, p Presenter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		onAir, err := onAirService.Unlock(ctx, wl)
+		if errors.Is(err, onair.ErrLocked) {
+			render.Conflict(ctx, wl, w, render.NewError(err))
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		render.JSON(ctx, wl, w, p.Status(onAir), http.StatusOK)
+	}
+}
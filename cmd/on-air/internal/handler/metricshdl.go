@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+)
+
+// servedMetrics are the expvar maps served, the others like cmdline may hold
+// secrets passed as flags.
+var servedMetrics = []string{"onair"}
+
+// GetMetrics returns the served expvar metrics, in the format of
+// expvar.Handler.
+func GetMetrics(wl wlog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		metrics := make(map[string]json.RawMessage, len(servedMetrics))
+		for _, name := range servedMetrics {
+			if v := expvar.Get(name); v != nil {
+				metrics[name] = json.RawMessage(v.String())
+			}
+		}
+
+		render.JSON(ctx, wl, w, metrics, http.StatusOK)
+	}
+}
@@ -1,43 +1,117 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"on-air/internal/entities"
 	"on-air/internal/service/onair"
 	"on-air/internal/wlog"
 	"on-air/pkg/render"
+	"slices"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+const (
+	// maxLongPollWait caps how long a GET /onAir?wait= request can block.
+	maxLongPollWait = 2 * time.Minute
+	// streamKeepAlive is the interval at which comments are sent on idle streams.
+	streamKeepAlive     = 25 * time.Second
+	defaultHistoryLimit = 50
 )
 
 type onAirStatusBody struct {
-	IsOnAir bool `json:"is_on_air,omitempty"`
+	IsOnAir bool   `json:"is_on_air,omitempty"`
+	Message string `json:"message,omitempty"`
+	// ExpiresAt ends the manual claim, which lasts until released otherwise
+	ExpiresAt null.Time `json:"expires_at"`
+}
+
+// Validate makes sure the status is valid.
+// It returns an error when the status is not valid.
+func (b *onAirStatusBody) Validate() error {
+	return validation.ValidateStruct(
+		b,
+		validation.Field(&b.ExpiresAt, validation.By(inFuture)),
+	)
 }
 
-func GetOnAirStatus(wl wlog.Logger, onAirService onair.SVC) http.HandlerFunc {
+// GetOnAirStatus returns the current status. When the wait query parameter is
+// set, the request is held until the status revision moves past since (the
+// current revision by default) or the wait duration elapses.
+func GetOnAirStatus(wl wlog.Logger, onAirService onair.Reader, p Presenter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		onAirStatus, err := onAirService.GetOnAirStatus(ctx, wl)
 		if err != nil {
 			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		q := r.URL.Query()
+		if q.Get("wait") == "" {
+			render.JSON(ctx, wl, w, p.Status(onAirStatus), http.StatusOK)
+			return
+		}
+
+		wait, err := time.ParseDuration(q.Get("wait"))
+		if err != nil || wait <= 0 || wait > maxLongPollWait {
+			render.BadRequest(ctx, wl, w, render.NewErrorStr(
+				fmt.Sprintf("wait must be a duration between 0s and %s", maxLongPollWait)))
+			return
+		}
+
+		since := onAirStatus.Revision
+		if s := q.Get("since"); s != "" {
+			since, err = strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				render.BadRequest(ctx, wl, w, render.NewErrorStr("since must be a revision number"))
+				return
+			}
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, wait)
+		defer cancel()
+
+		onAirStatus, err = onAirService.WaitForChange(waitCtx, wl, since)
+		if errors.Is(err, context.Canceled) {
+			wl.Debug("client went away while waiting for onAir change")
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
 		}
 
-		render.JSON(ctx, wl, w, onAirStatus, http.StatusOK)
+		render.JSON(ctx, wl, w, p.Status(onAirStatus), http.StatusOK)
 	}
 }
 
-func ToggleOnAirStatus(wl wlog.Logger, onAirService onair.SVC) http.HandlerFunc {
+func ToggleOnAirStatus(wl wlog.Logger, onAirService onair.SVC, p Presenter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		onAir, err := onAirService.ToggleOnAirStatus(ctx, wl)
+		if errors.Is(err, onair.ErrLocked) {
+			render.Conflict(ctx, wl, w, render.NewError(err))
+			return
+		}
 		if err != nil {
 			render.InternalError(ctx, wl, w, err)
+			return
 		}
 
-		render.JSON(ctx, wl, w, onAir, http.StatusOK)
+		render.JSON(ctx, wl, w, p.Status(onAir), http.StatusOK)
 	}
 }
 
-func SetOnAirStatus(wl wlog.Logger, onAirService onair.SVC) http.HandlerFunc {
+func SetOnAirStatus(wl wlog.Logger, onAirService onair.SVC, p Presenter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
@@ -48,13 +122,132 @@ func SetOnAirStatus(wl wlog.Logger, onAirService onair.SVC) http.HandlerFunc {
 			return
 		}
 
-		onAir := entities.OnAirStatus{IsOnAir: onAirReq.IsOnAir}
+		if err := onAirReq.Validate(); err != nil {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+
+		onAirUpdated, err := onAirService.Claim(ctx, wl, entities.Claim{
+			Source:    entities.SourceManual,
+			IsOnAir:   onAirReq.IsOnAir,
+			Message:   onAirReq.Message,
+			ExpiresAt: onAirReq.ExpiresAt,
+		})
+		if errors.Is(err, onair.ErrLocked) {
+			render.Conflict(ctx, wl, w, render.NewError(err))
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		render.JSON(ctx, wl, w, p.Status(onAirUpdated), http.StatusOK)
+	}
+}
+
+// ReleaseClaim drops the claim of the source in the path, the status falls
+// back to the claims of the other sources.
+func ReleaseClaim(wl wlog.Logger, onAirService onair.SVC, p Presenter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		source := entities.Source(mux.Vars(r)["source"])
+		if !slices.Contains(entities.Sources, source) {
+			render.NotFound(ctx, wl, w, fmt.Errorf("unknown source %q", source))
+			return
+		}
+
+		onAir, err := onAirService.Release(ctx, wl, source)
+		if errors.Is(err, onair.ErrLocked) {
+			render.Conflict(ctx, wl, w, render.NewError(err))
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		render.JSON(ctx, wl, w, p.Status(onAir), http.StatusOK)
+	}
+}
+
+// GetOnAirHistory returns the most recent transitions, newest first.
+func GetOnAirHistory(wl wlog.Logger, onAirService onair.SVC, p Presenter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		limit := defaultHistoryLimit
+		if l := r.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 {
+				render.BadRequest(ctx, wl, w, render.NewErrorStr("limit must be a positive number"))
+				return
+			}
+			limit = n
+		}
 
-		onAirUpdated, err := onAirService.SetOnAirStatus(ctx, wl, onAir)
+		history, err := onAirService.GetHistory(ctx, wl, limit)
 		if err != nil {
 			render.InternalError(ctx, wl, w, err)
+			return
 		}
 
-		render.JSON(ctx, wl, w, onAirUpdated, http.StatusOK)
+		render.JSON(ctx, wl, w, p.History(history), http.StatusOK)
+	}
+}
+
+// StreamOnAirStatus streams every status change as server-sent events.
+// The current status is sent first unless the client resumes from a
+// Last-Event-ID that is still current.
+func StreamOnAirStatus(wl wlog.Logger, onAirService onair.Reader, p Presenter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			render.InternalError(ctx, wl, w, errors.New("streaming is not supported"))
+			return
+		}
+
+		var since uint64
+		if id := r.Header.Get("Last-Event-ID"); id != "" {
+			since, _ = strconv.ParseUint(id, 10, 64)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		// disable response buffering in reverse proxies
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			waitCtx, cancel := context.WithTimeout(ctx, streamKeepAlive)
+			onAirStatus, err := onAirService.WaitForChange(waitCtx, wl, since)
+			cancel()
+			if err != nil {
+				wl.Debugf("closing onAir stream: %s", err)
+				return
+			}
+
+			if onAirStatus.Revision <= since {
+				// keep the connection open through proxies
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+				continue
+			}
+
+			data, err := json.Marshal(p.Status(onAirStatus))
+			if err != nil {
+				wl.Error(err)
+				return
+			}
+
+			fmt.Fprintf(w, "id: %d\nevent: status\ndata: %s\n\n", onAirStatus.Revision, data)
+			flusher.Flush()
+			since = onAirStatus.Revision
+		}
 	}
 }
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"on-air/internal/entities"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// Presenter converts entities into the response bodies of an API version.
+type Presenter struct {
+	Status  func(entities.OnAirStatus) interface{}
+	History func([]entities.Transition) interface{}
+}
+
+// Legacy renders the entities as is, exposing their Go field names.
+// It is used by the deprecated unversioned routes.
+var Legacy = Presenter{
+	Status:  func(s entities.OnAirStatus) interface{} { return s },
+	History: func(h []entities.Transition) interface{} { return h },
+}
+
+// V1 renders the stable snake_case contract of the /v1 routes.
+var V1 = Presenter{
+	Status:  func(s entities.OnAirStatus) interface{} { return newOnAirStatusV1(s) },
+	History: func(h []entities.Transition) interface{} { return newTransitionsV1(h) },
+}
+
+type onAirStatusV1 struct {
+	IsOnAir     bool      `json:"is_on_air"`
+	Message     string    `json:"message"`
+	LastUpdated *string   `json:"last_updated"`
+	LastOnAir   *string   `json:"last_on_air"`
+	Revision    uint64    `json:"revision"`
+	Lock        *lockV1   `json:"lock"`
+	Source      *string   `json:"source"`
+	Claims      []claimV1 `json:"claims"`
+}
+
+type claimV1 struct {
+	Source    string  `json:"source"`
+	IsOnAir   bool    `json:"is_on_air"`
+	Message   string  `json:"message"`
+	ClaimedAt string  `json:"claimed_at"`
+	ExpiresAt *string `json:"expires_at"`
+}
+
+type transitionV1 struct {
+	Revision uint64  `json:"revision"`
+	IsOnAir  bool    `json:"is_on_air"`
+	Message  string  `json:"message"`
+	At       string  `json:"at"`
+	Lock     *lockV1 `json:"lock"`
+	Source   *string `json:"source"`
+}
+
+type lockV1 struct {
+	// Holder is null when the lock was taken without authentication
+	Holder    *string `json:"holder"`
+	Reason    string  `json:"reason"`
+	LockedAt  string  `json:"locked_at"`
+	ExpiresAt *string `json:"expires_at"`
+}
+
+func newOnAirStatusV1(s entities.OnAirStatus) onAirStatusV1 {
+	return onAirStatusV1{
+		IsOnAir:     s.IsOnAir,
+		Message:     s.Message,
+		LastUpdated: timestampV1(s.LastUpdated),
+		LastOnAir:   timestampV1(s.LastOnAir),
+		Revision:    s.Revision,
+		Lock:        newLockV1(s.Lock),
+		Source:      sourceV1(s.Source),
+		Claims:      newClaimsV1(s.Claims),
+	}
+}
+
+func newClaimsV1(claims []entities.Claim) []claimV1 {
+	// always render an array, never null
+	cs := make([]claimV1, 0, len(claims))
+	for _, c := range claims {
+		cs = append(cs, claimV1{
+			Source:    string(c.Source),
+			IsOnAir:   c.IsOnAir,
+			Message:   c.Message,
+			ClaimedAt: c.ClaimedAt.UTC().Format(time.RFC3339),
+			ExpiresAt: timestampV1(c.ExpiresAt),
+		})
+	}
+	return cs
+}
+
+// sourceV1 returns nil when there's no source so it's rendered as an
+// explicit null.
+func sourceV1(source entities.Source) *string {
+	if source == "" {
+		return nil
+	}
+
+	s := string(source)
+	return &s
+}
+
+func newTransitionsV1(h []entities.Transition) []transitionV1 {
+	// always render an array, never null
+	ts := make([]transitionV1, 0, len(h))
+	for _, t := range h {
+		ts = append(ts, transitionV1{
+			Revision: t.Revision,
+			IsOnAir:  t.IsOnAir,
+			Message:  t.Message,
+			At:       t.At.UTC().Format(time.RFC3339),
+			Lock:     newLockV1(t.Lock),
+			Source:   sourceV1(t.Source),
+		})
+	}
+	return ts
+}
+
+func newLockV1(l *entities.Lock) *lockV1 {
+	if l == nil {
+		return nil
+	}
+
+	lock := &lockV1{
+		Reason:    l.Reason,
+		LockedAt:  l.LockedAt.UTC().Format(time.RFC3339),
+		ExpiresAt: timestampV1(l.ExpiresAt),
+	}
+	if l.Holder != "" {
+		lock.Holder = &l.Holder
+	}
+	return lock
+}
+
+// timestampV1 formats the time as an RFC3339 UTC timestamp, or nil so it's
+// rendered as an explicit null.
+func timestampV1(t null.Time) *string {
+	if !t.Valid {
+		return nil
+	}
+
+	s := t.Time.UTC().Format(time.RFC3339)
+	return &s
+}
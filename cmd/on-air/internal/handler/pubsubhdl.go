@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"on-air/internal/pubsub"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+)
+
+// PubSubPush applies the set and toggle commands pushed by a Pub/Sub
+// subscription. Messages that can never be applied are acknowledged so they
+// aren't redelivered, the others are retried by Pub/Sub until they succeed.
+func PubSubPush(wl wlog.Logger, receiver *pubsub.Receiver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if err := receiver.Authenticate(ctx, wl, r.Header.Get("Authorization")); err != nil {
+			render.Unauthorized(ctx, wl, w, err)
+			return
+		}
+
+		msg, err := pubsub.DecodePush(r.Body)
+		if err != nil {
+			render.ACKPushEvent(ctx, wl, w, err)
+			return
+		}
+
+		err = receiver.Receive(ctx, wl, msg)
+		// a locked status won't be unlocked by retrying
+		if errors.Is(err, pubsub.ErrInvalidCommand) || errors.Is(err, onair.ErrLocked) {
+			render.ACKPushEvent(ctx, wl, w, err)
+			return
+		}
+		if err != nil {
+			render.NACKPushEvent(ctx, wl, w, err)
+			return
+		}
+
+		render.ACKPushEvent(ctx, wl, w, nil)
+	}
+}
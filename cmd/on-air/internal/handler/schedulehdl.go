@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"on-air/internal/entities"
+	"on-air/internal/service/schedule"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+type scheduleV1 struct {
+	ID        string `json:"id"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Message   string `json:"message"`
+	CreatedAt string `json:"created_at"`
+}
+
+type scheduleBody struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Message string    `json:"message"`
+}
+
+// ListSchedules returns the schedules overlapping the from and to range,
+// soonest first. Only the upcoming and running schedules are returned by
+// default.
+func ListSchedules(wl wlog.Logger, scheduleService schedule.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		q := r.URL.Query()
+		filter := entities.ScheduleFilter{From: null.TimeFrom(time.Now())}
+		errs := validation.Errors{}
+		for name, dst := range map[string]*null.Time{"from": &filter.From, "to": &filter.To} {
+			if v := q.Get(name); v != "" {
+				t, err := parseTime(v, time.UTC)
+				if err != nil {
+					errs[name] = err
+					continue
+				}
+				*dst = null.TimeFrom(t)
+			}
+		}
+		if err := errs.Filter(); err != nil {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+
+		schedules, err := scheduleService.ListSchedules(ctx, wl, filter)
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		resp := make([]scheduleV1, 0, len(schedules))
+		for _, s := range schedules {
+			resp = append(resp, newScheduleV1(s))
+		}
+
+		render.JSON(ctx, wl, w, resp, http.StatusOK)
+	}
+}
+
+// CreateSchedule adds a window during which the status is set on air.
+func CreateSchedule(wl wlog.Logger, scheduleService schedule.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var body scheduleBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			render.BadRequest(ctx, wl, w, render.ErrJSONDecode)
+			return
+		}
+		s, err := scheduleService.CreateSchedule(ctx, wl, entities.Schedule{
+			Start:   body.Start,
+			End:     body.End,
+			Message: body.Message,
+		})
+		var verrs validation.Errors
+		if errors.As(err, &verrs) {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+		if errors.Is(err, schedule.ErrScheduleOverlap) {
+			render.Conflict(ctx, wl, w, render.NewError(err))
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		render.JSON(ctx, wl, w, newScheduleV1(s), http.StatusCreated)
+	}
+}
+
+// DeleteSchedule removes a schedule. A running schedule is ended first.
+func DeleteSchedule(wl wlog.Logger, scheduleService schedule.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		err := scheduleService.DeleteSchedule(ctx, wl, mux.Vars(r)["id"])
+		if errors.Is(err, schedule.ErrScheduleNotFound) {
+			render.NotFound(ctx, wl, w, err)
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newScheduleV1(s entities.Schedule) scheduleV1 {
+	return scheduleV1{
+		ID:        s.ID,
+		Start:     s.Start.UTC().Format(time.RFC3339),
+		End:       s.End.UTC().Format(time.RFC3339),
+		Message:   s.Message,
+		CreatedAt: s.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+const (
+	maxNotesLen = 2000
+	maxTags     = 20
+	maxTagLen   = 50
+)
+
+type sessionV1 struct {
+	ID              string   `json:"id"`
+	Start           string   `json:"start"`
+	End             *string  `json:"end"`
+	DurationSeconds int64    `json:"duration_seconds"`
+	Running         bool     `json:"running"`
+	Notes           string   `json:"notes"`
+	Tags            []string `json:"tags"`
+}
+
+type sessionUpdateBody struct {
+	Notes *string `json:"notes"`
+	// Tags is nil when omitted, and empty when cleared
+	Tags []string `json:"tags"`
+}
+
+// Validate makes sure the update is valid.
+// It returns an error when the update is not valid.
+func (b *sessionUpdateBody) Validate() error {
+	return validation.ValidateStruct(
+		b,
+		validation.Field(&b.Notes, validation.RuneLength(0, maxNotesLen)),
+		validation.Field(&b.Tags, validation.Length(0, maxTags),
+			validation.Each(validation.Required, validation.RuneLength(1, maxTagLen))),
+	)
+}
+
+// ListSessions returns the sessions matching the tag and overlapping the
+// from and to range, newest first.
+func ListSessions(wl wlog.Logger, onAirService onair.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		q := r.URL.Query()
+		filter := entities.SessionFilter{Tag: q.Get("tag")}
+		errs := validation.Errors{}
+		for name, dst := range map[string]*null.Time{"from": &filter.From, "to": &filter.To} {
+			if v := q.Get(name); v != "" {
+				t, err := parseTime(v, time.UTC)
+				if err != nil {
+					errs[name] = err
+					continue
+				}
+				*dst = null.TimeFrom(t)
+			}
+		}
+		if err := errs.Filter(); err != nil {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+
+		sessions, err := onAirService.ListSessions(ctx, wl, filter)
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		now := time.Now()
+		resp := make([]sessionV1, 0, len(sessions))
+		for _, s := range sessions {
+			resp = append(resp, newSessionV1(s, now))
+		}
+
+		render.JSON(ctx, wl, w, resp, http.StatusOK)
+	}
+}
+
+// GetSession returns a single session.
+func GetSession(wl wlog.Logger, onAirService onair.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		s, err := onAirService.GetSession(ctx, wl, mux.Vars(r)["id"])
+		if errors.Is(err, onair.ErrSessionNotFound) {
+			render.NotFound(ctx, wl, w, err)
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		render.JSON(ctx, wl, w, newSessionV1(s, time.Now()), http.StatusOK)
+	}
+}
+
+// UpdateSession edits the notes and tags of a session. Omitted fields are
+// left untouched and tags replace the existing ones.
+func UpdateSession(wl wlog.Logger, onAirService onair.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var body sessionUpdateBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			render.BadRequest(ctx, wl, w, render.ErrJSONDecode)
+			return
+		}
+		if err := body.Validate(); err != nil {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+
+		update := entities.SessionUpdate{Notes: body.Notes}
+		if body.Tags != nil {
+			update.Tags = &body.Tags
+		}
+
+		s, err := onAirService.UpdateSession(ctx, wl, mux.Vars(r)["id"], update)
+		if errors.Is(err, onair.ErrSessionNotFound) {
+			render.NotFound(ctx, wl, w, err)
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		render.JSON(ctx, wl, w, newSessionV1(s, time.Now()), http.StatusOK)
+	}
+}
+
+func newSessionV1(s entities.Session, now time.Time) sessionV1 {
+	return sessionV1{
+		ID:              s.ID,
+		Start:           s.Start.UTC().Format(time.RFC3339),
+		End:             timestampV1(s.End),
+		DurationSeconds: int64(s.Duration(now).Seconds()),
+		Running:         !s.End.Valid,
+		Notes:           s.Notes,
+		Tags:            s.Tags,
+	}
+}
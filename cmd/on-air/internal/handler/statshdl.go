@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"on-air/internal/entities"
+	"on-air/internal/service/stats"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	dateLayout        = "2006-01-02"
+	defaultStatsRange = 30 // days
+)
+
+type statsV1 struct {
+	From                  string          `json:"from"`
+	To                    string          `json:"to"`
+	Timezone              string          `json:"timezone"`
+	Period                stats.Period    `json:"period"`
+	TotalOnAirSeconds     int64           `json:"total_on_air_seconds"`
+	TotalOnAirHours       float64         `json:"total_on_air_hours"`
+	Sessions              int             `json:"sessions"`
+	LongestSessionSeconds int64           `json:"longest_session_seconds"`
+	AverageSessionSeconds int64           `json:"average_session_seconds"`
+	Buckets               []statsBucketV1 `json:"buckets"`
+	// Truncated is set when sessions of the range have been dropped from
+	// memory, CoveredFrom then tells where the statistics start.
+	Truncated   bool    `json:"truncated"`
+	CoveredFrom *string `json:"covered_from"`
+}
+
+type statsBucketV1 struct {
+	Start        string  `json:"start"`
+	End          string  `json:"end"`
+	OnAirSeconds int64   `json:"on_air_seconds"`
+	OnAirHours   float64 `json:"on_air_hours"`
+	Sessions     int     `json:"sessions"`
+}
+
+// GetStats returns the on air time statistics for a range, bucketed by day,
+// week or month in the requested timezone.
+func GetStats(wl wlog.Logger, statsService stats.SVC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		q, err := parseStatsQuery(r, time.Now())
+		if err != nil {
+			render.BadRequest(ctx, wl, w, err)
+			return
+		}
+
+		s, err := statsService.GetStats(ctx, wl, q)
+		var verrs validation.Errors
+		if errors.As(err, &verrs) || errors.Is(err, stats.ErrTooManyBuckets) {
+			render.BadRequest(ctx, wl, w, render.NewError(err))
+			return
+		}
+		if err != nil {
+			render.InternalError(ctx, wl, w, err)
+			return
+		}
+
+		render.JSON(ctx, wl, w, newStatsV1(s, q.Period), http.StatusOK)
+	}
+}
+
+// parseStatsQuery reads the tz, period, from and to query parameters.
+// Dates without a time are interpreted as midnight in tz. The range defaults
+// to the last 30 days.
+func parseStatsQuery(r *http.Request, now time.Time) (stats.Query, error) {
+	qs := r.URL.Query()
+	errs := validation.Errors{}
+
+	q := stats.Query{
+		Location: time.UTC,
+		Period:   stats.Period(qs.Get("period")),
+	}
+	if q.Period == "" {
+		q.Period = stats.PeriodDay
+	}
+
+	if tz := qs.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			errs["tz"] = errors.New("must be a valid IANA timezone")
+		} else {
+			q.Location = loc
+		}
+	}
+
+	q.To = now
+	if to := qs.Get("to"); to != "" {
+		t, err := parseTime(to, q.Location)
+		if err != nil {
+			errs["to"] = err
+		}
+		q.To = t
+	}
+
+	y, m, d := q.To.In(q.Location).Date()
+	q.From = time.Date(y, m, d-defaultStatsRange, 0, 0, 0, 0, q.Location)
+	if from := qs.Get("from"); from != "" {
+		t, err := parseTime(from, q.Location)
+		if err != nil {
+			errs["from"] = err
+		}
+		q.From = t
+	}
+
+	if err := errs.Filter(); err != nil {
+		return stats.Query{}, err
+	}
+
+	return q, nil
+}
+
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateLayout, s, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("must be a date (2006-01-02) or an RFC3339 timestamp")
+}
+
+func newStatsV1(s entities.Stats, p stats.Period) statsV1 {
+	resp := statsV1{
+		From:                  s.From.Format(time.RFC3339),
+		To:                    s.To.Format(time.RFC3339),
+		Timezone:              s.Location.String(),
+		Period:                p,
+		TotalOnAirSeconds:     int64(s.TotalOnAir.Seconds()),
+		TotalOnAirHours:       hours(s.TotalOnAir),
+		Sessions:              s.Sessions,
+		LongestSessionSeconds: int64(s.LongestSession.Seconds()),
+		AverageSessionSeconds: int64(s.AverageSession.Seconds()),
+		Buckets:               make([]statsBucketV1, 0, len(s.Buckets)),
+	}
+
+	if s.Truncated() {
+		from := s.CoveredFrom.Time.In(s.Location).Format(time.RFC3339)
+		resp.Truncated = true
+		resp.CoveredFrom = &from
+	}
+
+	for _, b := range s.Buckets {
+		resp.Buckets = append(resp.Buckets, statsBucketV1{
+			Start:        b.Start.Format(time.RFC3339),
+			End:          b.End.Format(time.RFC3339),
+			OnAirSeconds: int64(b.OnAir.Seconds()),
+			OnAirHours:   hours(b.OnAir),
+			Sessions:     b.Sessions,
+		})
+	}
+
+	return resp
+}
+
+// hours rounds the duration to hundredths of an hour.
+func hours(d time.Duration) float64 {
+	return math.Round(d.Hours()*100) / 100
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"on-air/internal/acontext"
+	"on-air/internal/wlog"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// AccessLog logs every request once served, with its status, size and
+// latency, tied to the trace of its X-Cloud-Trace-Context header. The header
+// is also kept in the context, see wlog.WithServiceRequest.
+func AccessLog(wl wlog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			trace := r.Header.Get(string(acontext.ContextKeyTraceIDHeader))
+			if trace != "" {
+				r = r.WithContext(context.WithValue(r.Context(), acontext.ContextKeyTraceIDHeader, trace))
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			wlog.WithTraceHeader(wl, trace).Access(wlog.HTTPRequest{
+				Method:       r.Method,
+				URL:          redactURL(r.URL),
+				Status:       status,
+				RequestSize:  max(r.ContentLength, 0),
+				ResponseSize: sw.size,
+				UserAgent:    r.UserAgent(),
+				RemoteIP:     remoteIP(r),
+				Referer:      redactReferer(r.Referer()),
+				Protocol:     r.Proto,
+				Latency:      time.Since(start),
+			})
+		})
+	}
+}
+
+// sensitiveParams are the query parameters carrying credentials, e.g. the
+// token of the calendar feed, never logged.
+var sensitiveParams = []string{"token"}
+
+// redactURL returns the URL with the values of the sensitive query
+// parameters replaced.
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	redacted := false
+	for _, p := range sensitiveParams {
+		if q.Has(p) {
+			q.Set(p, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+
+	clean := *u
+	clean.RawQuery = q.Encode()
+	return clean.String()
+}
+
+// redactReferer redacts the referring URL like redactURL, a feed URL may be
+// shared as a link.
+func redactReferer(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return redactURL(u)
+}
+
+// remoteIP returns the IP of the client, the first one forwarded by the
+// proxies when behind any.
+func remoteIP(r *http.Request) string {
+	if fwd := r.Header.Get(string(acontext.ContextKeyForwardedForHeader)); fwd != "" {
+		ip, _, _ := strings.Cut(fwd, ",")
+		return strings.TrimSpace(ip)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusWriter writes the response through while keeping its status and
+// size. It can be flushed for the streamed responses.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.size += int64(n)
+	return n, err
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
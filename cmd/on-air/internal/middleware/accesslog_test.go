@@ -0,0 +1,76 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"on-air/cmd/on-air/internal/middleware"
+	"on-air/internal/acontext"
+	"on-air/internal/wlog"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	wl, err := wlog.NewBasicLoggerWithOutput(&wlog.Config{MinLogLevel: "info", Format: wlog.FormatGCP, Project: "radio"}, &buf)
+	assert.NilError(t, err)
+
+	var trace interface{}
+	h := middleware.AccessLog(wl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = r.Context().Value(acontext.ContextKeyTraceIDHeader)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+		// streams can still be flushed
+		w.(http.Flusher).Flush()
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/onAir?x=1", strings.NewReader(`{"is_on_air":true}`))
+	r.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/255;o=1")
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	r.Header.Set("User-Agent", "test")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, trace, "105445aa7843bc8bf206b12000100000/255;o=1")
+
+	var entry map[string]interface{}
+	assert.NilError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, entry["severity"], "INFO")
+	assert.Equal(t, entry["message"], "POST /v1/onAir?x=1 201")
+	assert.Equal(t, entry["logging.googleapis.com/trace"], "projects/radio/traces/105445aa7843bc8bf206b12000100000")
+	assert.Equal(t, entry["logging.googleapis.com/spanId"], "00000000000000ff")
+
+	req := entry["httpRequest"].(map[string]interface{})
+	assert.Equal(t, req["requestMethod"], "POST")
+	assert.Equal(t, req["requestUrl"], "/v1/onAir?x=1")
+	assert.Equal(t, req["status"], float64(201))
+	assert.Equal(t, req["requestSize"], "18")
+	assert.Equal(t, req["responseSize"], "7")
+	assert.Equal(t, req["userAgent"], "test")
+	assert.Equal(t, req["remoteIp"], "203.0.113.7")
+	assert.Equal(t, req["protocol"], "HTTP/1.1")
+	assert.Assert(t, strings.HasSuffix(req["latency"].(string), "s"))
+}
+
+func TestAccessLogRedactsTokens(t *testing.T) {
+	var buf bytes.Buffer
+	wl, err := wlog.NewBasicLoggerWithOutput(&wlog.Config{MinLogLevel: "info"}, &buf)
+	assert.NilError(t, err)
+
+	h := middleware.AccessLog(wl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "/calendar.ics?token=s3cret&tz=UTC", nil)
+	r.Header.Set("Referer", "https://on-air.example.com/calendar.ics?token=s3cret")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Assert(t, !strings.Contains(buf.String(), "s3cret"), buf.String())
+
+	var entry map[string]interface{}
+	assert.NilError(t, json.Unmarshal(buf.Bytes(), &entry))
+	req := entry["httpRequest"].(map[string]interface{})
+	assert.Equal(t, req["requestUrl"], "/calendar.ics?token=REDACTED&tz=UTC")
+	assert.Equal(t, req["referer"], "https://on-air.example.com/calendar.ics?token=REDACTED")
+	assert.Equal(t, entry["message"], "GET /calendar.ics?token=REDACTED&tz=UTC 200")
+}
@@ -0,0 +1,111 @@
+// Package middleware holds the http middlewares shared by the routes.
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"on-air/internal/acontext"
+	"on-air/internal/entities"
+	"on-air/internal/service/auth"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// SessionCookieName is the name of the cookie holding a dashboard session.
+	SessionCookieName = "onair_session"
+	// APIKeyHeader can be used instead of a bearer token.
+	APIKeyHeader = "X-API-Key"
+
+	bearerPrefix = "Bearer "
+)
+
+// ErrMissingCredentials is returned when a request carries no credentials.
+var ErrMissingCredentials = errors.New("missing credentials")
+
+// Auth rejects requests without a valid API key or session cookie, except for
+// the public paths. A public path ending with a slash covers its sub-paths.
+func Auth(wl wlog.Logger, authService auth.SVC, publicPaths ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !authService.Enabled() || isPublic(r.URL.Path, publicPaths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			user, err := authenticate(r, wl, authService)
+			if err != nil {
+				render.Unauthorized(ctx, wl, w, err)
+				return
+			}
+
+			ctx = acontext.WithUserID(ctx, user.ID)
+			ctx = acontext.WithUserRole(ctx, string(user.Role))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func authenticate(r *http.Request, wl wlog.Logger, authService auth.SVC) (entities.User, error) {
+	ctx := r.Context()
+
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, bearerPrefix) {
+		return authService.Authenticate(ctx, wl, strings.TrimPrefix(h, bearerPrefix))
+	}
+
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return authService.Authenticate(ctx, wl, key)
+	}
+
+	if c, err := r.Cookie(SessionCookieName); err == nil {
+		return authService.VerifySession(ctx, wl, c.Value)
+	}
+
+	return entities.User{}, ErrMissingCredentials
+}
+
+func isPublic(path string, publicPaths []string) bool {
+	for _, p := range publicPaths {
+		if path == p || (p != "/" && strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize lets the request through only when the authenticated user can
+// perform the action on the channel, and responds with a 403 otherwise.
+// It has to run after Auth.
+func Authorize(wl wlog.Logger, authService auth.SVC, action entities.Action, channel string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authService.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		userID, err := acontext.UserID(ctx)
+		if err != nil {
+			render.Forbidden(ctx, wl, w, err)
+			return
+		}
+
+		if err := authService.Authorize(ctx, wl, userID, action, channel); err != nil {
+			render.Forbidden(ctx, wl, w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthorizeChannel is Authorize for the channel variable of the route.
+func AuthorizeChannel(wl wlog.Logger, authService auth.SVC, action entities.Action, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Authorize(wl, authService, action, mux.Vars(r)["channel"], next).ServeHTTP(w, r)
+	})
+}
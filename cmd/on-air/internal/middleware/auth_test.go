@@ -0,0 +1,140 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"on-air/cmd/on-air/internal/middleware"
+	"on-air/internal/acontext"
+	"on-air/internal/entities"
+	"on-air/internal/service/auth"
+	"on-air/internal/wlog"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAuth(t *testing.T) {
+	wl := wlog.NewNopLogger()
+	authService, err := auth.New(&auth.Config{
+		APIKeys:       []string{"alice:s3cret"},
+		SessionSecret: "secret",
+		SessionTTL:    auth.DefaultSessionTTL,
+	})
+	assert.NilError(t, err)
+
+	session, _, err := authService.NewSession(context.Background(), wl, entities.User{ID: "alice"})
+	assert.NilError(t, err)
+
+	var userID string
+	h := middleware.Auth(wl, authService, "/", "/dashboard/")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ = acontext.UserID(r.Context())
+		}))
+
+	testData := []struct {
+		name         string
+		path         string
+		header       string
+		value        string
+		cookie       string
+		expectedCode int
+		expectedUser string
+	}{
+		{"root is public", "/", "", "", "", 200, ""},
+		{"public prefix", "/dashboard/app.js", "", "", "", 200, ""},
+		{"root does not match everything", "/onAir", "", "", "", 401, ""},
+		{"bearer token", "/onAir", "Authorization", "Bearer s3cret", "", 200, "alice"},
+		{"api key header", "/onAir", middleware.APIKeyHeader, "s3cret", "", 200, "alice"},
+		{"invalid api key", "/onAir", middleware.APIKeyHeader, "nope", "", 401, ""},
+		{"session cookie", "/onAir", "", "", session, 200, "alice"},
+		{"tampered session cookie", "/onAir", "", "", session + "x", 401, ""},
+	}
+
+	for _, tc := range testData {
+		userID = ""
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.header != "" {
+			r.Header.Set(tc.header, tc.value)
+		}
+		if tc.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: middleware.SessionCookieName, Value: tc.cookie})
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, w.Code, tc.expectedCode, tc.name)
+		assert.Equal(t, userID, tc.expectedUser, tc.name)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	wl := wlog.NewNopLogger()
+	authService, err := auth.New(&auth.Config{
+		APIKeys: []string{
+			"viewer:v-key:viewer",
+			"operator:o-key:operator:studio-a|studio-b",
+			"admin:a-key:admin",
+		},
+		SessionTTL: auth.DefaultSessionTTL,
+	})
+	assert.NilError(t, err)
+
+	testData := []struct {
+		name         string
+		userID       string
+		action       entities.Action
+		channel      string
+		expectedCode int
+	}{
+		{"viewer reads", "viewer", entities.ActionRead, "", 200},
+		{"viewer can't set the status", "viewer", entities.ActionSetStatus, "studio-a", 403},
+		{"operator sets a granted channel", "operator", entities.ActionSetStatus, "studio-b", 200},
+		{"operator can't set another channel", "operator", entities.ActionSetStatus, "studio-c", 403},
+		{"operator can't manage schedules", "operator", entities.ActionManageSchedules, "", 403},
+		{"admin manages schedules", "admin", entities.ActionManageSchedules, "", 200},
+		{"unknown user", "mallory", entities.ActionRead, "", 403},
+		{"no user", "", entities.ActionRead, "", 403},
+	}
+
+	for _, tc := range testData {
+		h := middleware.Authorize(wl, authService, tc.action, tc.channel,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.userID != "" {
+			r = r.WithContext(acontext.WithUserID(r.Context(), tc.userID))
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, w.Code, tc.expectedCode, tc.name)
+	}
+}
+
+func TestAPIKeyFormat(t *testing.T) {
+	testData := []struct {
+		name  string
+		keys  []string
+		valid bool
+	}{
+		{"user and key", []string{"alice:s3cret"}, true},
+		{"role", []string{"alice:s3cret:viewer"}, true},
+		{"channels", []string{"alice:s3cret:operator:a|b"}, true},
+		{"key with colons", []string{"alice:s3cret:owner"}, true},
+		{"key with colons and a role", []string{"alice:s3cret:owner:viewer"}, true},
+		{"empty channel", []string{"alice:s3cret:operator:a||b"}, false},
+		{"missing key", []string{"alice"}, false},
+		{"empty key", []string{"alice:"}, false},
+		{"empty key with a role", []string{"alice::viewer"}, false},
+		{"same user, same role", []string{"alice:k1:viewer", "alice:k2:viewer"}, true},
+		{"same user, different roles", []string{"alice:k1:viewer", "alice:k2:admin"}, false},
+	}
+
+	for _, tc := range testData {
+		cfg := &auth.Config{APIKeys: tc.keys, SessionTTL: auth.DefaultSessionTTL}
+		err := cfg.Validate()
+		assert.Equal(t, err == nil, tc.valid, tc.name)
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Deprecated marks the responses of a route as deprecated and points clients
+// to its successor through the Deprecation and Link headers.
+func Deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		next.ServeHTTP(w, r)
+	})
+}
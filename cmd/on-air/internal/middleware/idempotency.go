@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"on-air/internal/acontext"
+	"on-air/internal/idempotency"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// IdempotencyKeyHeader carries the key identifying retries of a request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on the replayed responses.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// Idempotency replays the stored response of the mutating requests retried
+// with the same Idempotency-Key, instead of applying them again. Keys are
+// scoped to the authenticated user, and reusing a key for a different request
+// is answered with a 422. Server errors aren't stored so they can be retried,
+// and neither are the cookies set. The public paths, whose callers aren't
+// authenticated, are skipped; they match like the ones of Auth.
+func Idempotency(wl wlog.Logger, store idempotency.Store, publicPaths ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !isMutating(r.Method) || isPublic(r.URL.Path, publicPaths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > idempotency.MaxKeyLen {
+				render.BadRequest(ctx, wl, w, render.NewErrorStr(
+					fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, idempotency.MaxKeyLen)))
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				render.BadRequest(ctx, wl, w, render.NewErrorStr("body could not be read"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			userID, _ := acontext.UserID(ctx)
+			key = userID + ":" + key
+			fingerprint := idempotency.Fingerprint(r.Method, r.URL.RequestURI(), body)
+
+			stored, err := store.Begin(ctx, key, fingerprint, time.Now())
+			if errors.Is(err, idempotency.ErrKeyReused) {
+				render.UnprocessableEntity(ctx, wl, w, render.NewError(err))
+				return
+			}
+			if errors.Is(err, idempotency.ErrInFlight) {
+				render.Conflict(ctx, wl, w, render.NewError(err))
+				return
+			}
+			if err != nil {
+				render.InternalError(ctx, wl, w, err)
+				return
+			}
+
+			if stored != nil {
+				wl.Debugf("replaying the response to idempotency key %q", r.Header.Get(IdempotencyKeyHeader))
+				for k, v := range stored.Header {
+					w.Header()[k] = v
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Body)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			// the client may be gone, the response is stored all the same
+			ctx = context.WithoutCancel(ctx)
+			if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+				if err := store.Release(ctx, key); err != nil {
+					wl.Error(err)
+				}
+				return
+			}
+
+			// a session must never be handed to whoever replays the key
+			header := w.Header().Clone()
+			header.Del("Set-Cookie")
+
+			resp := idempotency.Response{
+				StatusCode: rec.status,
+				Header:     header,
+				Body:       rec.body.Bytes(),
+			}
+			if err := store.Complete(ctx, key, resp, time.Now()); err != nil {
+				wl.Error(err)
+				if err := store.Release(ctx, key); err != nil {
+					wl.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder writes the response through while keeping a copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,50 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"on-air/cmd/on-air/internal/middleware"
+	"on-air/internal/idempotency"
+	"on-air/internal/wlog"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestIdempotencyCookies(t *testing.T) {
+	var calls int
+	h := middleware.Idempotency(wlog.NewNopLogger(), idempotency.NewMemoryStore(time.Hour), "/login")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			http.SetCookie(w, &http.Cookie{Name: "onair_session", Value: "secret"})
+			w.Write([]byte("ok"))
+		}))
+
+	// the steps share the handler and run in order
+	testData := []struct {
+		name             string
+		path             string
+		expectedCalls    int
+		expectedReplayed bool
+		expectedCookie   bool
+	}{
+		{"first request", "/v1/onAir", 1, false, true},
+		{"the replay has no cookie", "/v1/onAir", 1, true, false},
+		{"public paths are skipped", "/login", 2, false, true},
+		{"and never replayed", "/login", 3, false, true},
+	}
+
+	for _, tc := range testData {
+		r := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(`{}`))
+		r.Header.Set(middleware.IdempotencyKeyHeader, "k1")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, w.Code, http.StatusOK, tc.name)
+		assert.Equal(t, calls, tc.expectedCalls, tc.name)
+		assert.Equal(t, w.Header().Get(middleware.IdempotentReplayedHeader) == "true", tc.expectedReplayed, tc.name)
+		assert.Equal(t, w.Header().Get("Set-Cookie") != "", tc.expectedCookie, tc.name)
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"on-air/cmd/on-air/internal/openapi"
+	"on-air/internal/wlog"
+	"on-air/pkg/render"
+
+	"github.com/gorilla/mux"
+)
+
+// ValidateRequest rejects requests that don't match the operation documented
+// for their route in the OpenAPI spec with a 400 bad request, and bodies
+// larger than openapi.MaxBodySize with a 413.
+// Routes and methods missing from the spec are passed through untouched,
+// their bodies are still limited.
+func ValidateRequest(wl wlog.Logger, spec *openapi.Spec) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, openapi.MaxBodySize)
+			}
+
+			route := mux.CurrentRoute(r)
+			if route == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tpl, err := route.GetPathTemplate()
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			op, ok := spec.Operation(tpl, r.Method)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			err = spec.ValidateRequest(r, op)
+			if errors.Is(err, openapi.ErrBodyTooLarge) {
+				render.RequestEntityTooLarge(r.Context(), wl, w, render.NewError(err))
+				return
+			}
+			if err != nil {
+				render.BadRequest(r.Context(), wl, w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
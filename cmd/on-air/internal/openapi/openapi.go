@@ -0,0 +1,153 @@
+// Package openapi holds the OpenAPI document describing the API and
+// validates requests against it.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// Spec is the subset of an OpenAPI 3 document used to validate requests.
+type Spec struct {
+	Paths      map[string]PathItem `json:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema    `json:"schemas"`
+		Parameters map[string]*Parameter `json:"parameters"`
+	} `json:"components"`
+
+	// operations are parsed once, keyed by path template and lowercase method
+	operations map[operationKey]*Operation
+}
+
+// PathItem maps lowercase http methods to operations.
+type PathItem map[string]json.RawMessage
+
+type operationKey struct {
+	path   string
+	method string
+}
+
+// Operation describes a single API operation on a path.
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []Parameter  `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// Parameter is a query, header or path parameter of an operation.
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes the body expected by an operation.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema of a request body content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Load parses the embedded OpenAPI document, its operations and the patterns
+// of its schemas.
+func Load() (*Spec, error) {
+	var s Spec
+	if err := json.Unmarshal(document, &s); err != nil {
+		return nil, fmt.Errorf("error parsing openapi document: %w", err)
+	}
+
+	s.operations = map[operationKey]*Operation{}
+	for path, item := range s.Paths {
+		for method, raw := range item {
+			var op Operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("error parsing operation %s %s: %w", method, path, err)
+			}
+			s.operations[operationKey{path: path, method: method}] = &op
+		}
+	}
+
+	if err := s.compilePatterns(); err != nil {
+		return nil, fmt.Errorf("error parsing openapi document: %w", err)
+	}
+
+	return &s, nil
+}
+
+// Operation returns the operation documented for the path template and method.
+func (s *Spec) Operation(pathTemplate string, method string) (*Operation, bool) {
+	op, ok := s.operations[operationKey{path: pathTemplate, method: strings.ToLower(method)}]
+	return op, ok
+}
+
+// compilePatterns compiles the pattern of every schema, so requests don't
+// compile them again.
+func (s *Spec) compilePatterns() error {
+	var schemas []*Schema
+	for _, schema := range s.Components.Schemas {
+		schemas = append(schemas, schema)
+	}
+	for _, p := range s.Components.Parameters {
+		schemas = append(schemas, p.Schema)
+	}
+	for _, op := range s.operations {
+		for _, p := range op.Parameters {
+			schemas = append(schemas, p.Schema)
+		}
+		if op.RequestBody != nil {
+			for _, mt := range op.RequestBody.Content {
+				schemas = append(schemas, mt.Schema)
+			}
+		}
+	}
+
+	for _, schema := range schemas {
+		if err := compilePattern(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compilePattern(schema *Schema) error {
+	if schema == nil {
+		return nil
+	}
+
+	if schema.Pattern != "" {
+		re, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", schema.Pattern, err)
+		}
+		schema.pattern = re
+	}
+
+	for _, prop := range schema.Properties {
+		if err := compilePattern(prop); err != nil {
+			return err
+		}
+	}
+	return compilePattern(schema.Items)
+}
+
+// Handler serves the OpenAPI document.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(document)
+	}
+}
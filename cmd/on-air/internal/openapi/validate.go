@@ -0,0 +1,306 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	schemaRefPrefix    = "#/components/schemas/"
+	parameterRefPrefix = "#/components/parameters/"
+	jsonContentType    = "application/json"
+
+	// MaxBodySize is the size of the largest request body accepted, in bytes.
+	MaxBodySize = 1 << 20
+)
+
+// ErrBodyTooLarge is returned when the request body is larger than MaxBodySize.
+var ErrBodyTooLarge = fmt.Errorf("body must be at most %d bytes", MaxBodySize)
+
+// Schema is the subset of a JSON schema supported by the validator.
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Enum       []interface{}      `json:"enum"`
+	Properties map[string]*Schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *Schema            `json:"items"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+	MinLength  *int               `json:"minLength"`
+	MaxLength  *int               `json:"maxLength"`
+	Pattern    string             `json:"pattern"`
+	Nullable   bool               `json:"nullable"`
+
+	// pattern is compiled when the spec is loaded
+	pattern *regexp.Regexp
+}
+
+// ValidateRequest checks the parameters and body of the request against the
+// operation. It returns validation.Errors keyed by parameter location and name,
+// e.g. {"query": {"wait": "..."}, "body": {"message": "..."}}, or
+// ErrBodyTooLarge. The request body is restored so handlers can read it again.
+func (s *Spec) ValidateRequest(r *http.Request, op *Operation) error {
+	errs := validation.Errors{}
+
+	params := map[string]validation.Errors{}
+	for _, p := range op.Parameters {
+		p := s.resolveParam(p)
+		var value string
+		var present bool
+		switch p.In {
+		case "query":
+			present = r.URL.Query().Has(p.Name)
+			value = r.URL.Query().Get(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		default:
+			// path parameters are matched by the router
+			continue
+		}
+
+		var err error
+		if !present {
+			if p.Required {
+				err = errors.New("is required")
+			}
+		} else {
+			err = s.validateParam(value, p.Schema)
+		}
+
+		if err != nil {
+			if params[p.In] == nil {
+				params[p.In] = validation.Errors{}
+			}
+			params[p.In][p.Name] = err
+		}
+	}
+	for in, e := range params {
+		errs[in] = e
+	}
+
+	if op.RequestBody != nil {
+		err := s.validateBody(r, op.RequestBody)
+		if errors.Is(err, ErrBodyTooLarge) {
+			return err
+		}
+		if err != nil {
+			errs["body"] = err
+		}
+	}
+
+	return errs.Filter()
+}
+
+func (s *Spec) validateBody(r *http.Request, rb *RequestBody) error {
+	mt, ok := rb.Content[jsonContentType]
+	if !ok {
+		return nil
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(nil, r.Body, MaxBodySize))
+		r.Body.Close()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return ErrBodyTooLarge
+		}
+		if err != nil {
+			return errors.New("could not be read")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if rb.Required {
+			return errors.New("is required")
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return errors.New("must be valid json")
+	}
+
+	return s.validateValue(v, mt.Schema)
+}
+
+// validateParam converts the raw parameter to the schema type before validating it.
+func (s *Spec) validateParam(raw string, schema *Schema) error {
+	schema = s.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	var v interface{} = raw
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return fmt.Errorf("must be a %s", schema.Type)
+		}
+		v = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		v = b
+	}
+
+	return s.validateValue(v, schema)
+}
+
+func (s *Spec) validateValue(v interface{}, schema *Schema) error {
+	schema = s.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	if v == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return errors.New("must not be null")
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(v, schema.Enum) {
+		return errors.New("must be a valid value")
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return errors.New("must be an object")
+		}
+		return s.validateObject(obj, schema)
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return errors.New("must be an array")
+		}
+		errs := validation.Errors{}
+		for i, item := range arr {
+			if err := s.validateValue(item, schema.Items); err != nil {
+				errs[strconv.Itoa(i)] = err
+			}
+		}
+		return errs.Filter()
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return errors.New("must be a string")
+		}
+		return validateString(str, schema)
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("must be a %s", schema.Type)
+		}
+		return validateNumber(n, schema)
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return errors.New("must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+func (s *Spec) validateObject(obj map[string]interface{}, schema *Schema) error {
+	errs := validation.Errors{}
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			errs[name] = errors.New("is required")
+		}
+	}
+
+	for name, prop := range schema.Properties {
+		value, ok := obj[name]
+		if !ok {
+			continue
+		}
+		if err := s.validateValue(value, prop); err != nil {
+			errs[name] = err
+		}
+	}
+
+	return errs.Filter()
+}
+
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.Components.Schemas[strings.TrimPrefix(schema.Ref, schemaRefPrefix)]
+	}
+	return schema
+}
+
+func (s *Spec) resolveParam(p Parameter) Parameter {
+	if p.Ref == "" {
+		return p
+	}
+	if shared, ok := s.Components.Parameters[strings.TrimPrefix(p.Ref, parameterRefPrefix)]; ok {
+		return *shared
+	}
+	return p
+}
+
+func validateString(str string, schema *Schema) error {
+	n := utf8.RuneCountInString(str)
+	if schema.MinLength != nil && n < *schema.MinLength {
+		return fmt.Errorf("must be at least %d characters long", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && n > *schema.MaxLength {
+		return fmt.Errorf("must be at most %d characters long", *schema.MaxLength)
+	}
+	if schema.pattern != nil && !schema.pattern.MatchString(str) {
+		return errors.New("must be in a valid format")
+	}
+
+	return nil
+}
+
+func validateNumber(n json.Number, schema *Schema) error {
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("must be a %s", schema.Type)
+	}
+	if schema.Type == "integer" {
+		if _, err := n.Int64(); err != nil {
+			return errors.New("must be an integer")
+		}
+	}
+	if schema.Minimum != nil && f < *schema.Minimum {
+		return fmt.Errorf("must be no less than %v", *schema.Minimum)
+	}
+	if schema.Maximum != nil && f > *schema.Maximum {
+		return fmt.Errorf("must be no greater than %v", *schema.Maximum)
+	}
+
+	return nil
+}
+
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,21 +1,41 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"on-air/cmd/on-air/internal/handler"
+	"on-air/cmd/on-air/internal/openapi"
+	"on-air/internal/calendarimport"
+	"on-air/internal/homeassistant"
+	"on-air/internal/idempotency"
+	"on-air/internal/migrate"
+	"on-air/internal/pubsub"
+	"on-air/internal/service/auth"
+	"on-air/internal/service/channel"
+	"on-air/internal/service/group"
 	"on-air/internal/service/onair"
+	"on-air/internal/service/schedule"
+	"on-air/internal/service/stats"
+	"on-air/internal/snapshot"
 	"on-air/internal/wlog"
 	"on-air/pkg/utils"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/caarlos0/env/v6"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
 
+// shutdownTimeout is how long in-flight requests get to complete on shutdown.
+const shutdownTimeout = 10 * time.Second
+
 func DBConnection() (*sqlx.DB, error) {
 	dbUser := os.Getenv("DB_USER")
 	dbPwd := os.Getenv("DB_PASS")
@@ -69,16 +89,16 @@ func DBConnection() (*sqlx.DB, error) {
 }
 
 func main() {
-	var env string
+	var envFile string
 	var local bool
-	flag.StringVar(&env, "env", "", "path to env file")
-	flag.StringVar(&env, "e", "", "shorthand for env")
+	flag.StringVar(&envFile, "env", "", "path to env file")
+	flag.StringVar(&envFile, "e", "", "shorthand for env")
 	flag.BoolVar(&local, "local", false, "local mode")
 
 	flag.Parse()
 
-	if env != "" {
-		if err := utils.PrimeEnv(env, local); err != nil {
+	if envFile != "" {
+		if err := utils.PrimeEnv(envFile, local); err != nil {
 			log.Fatalf("error priming eng: %s", err)
 		}
 	}
@@ -88,11 +108,29 @@ func main() {
 		log.Fatal("error configuring logger")
 	}
 
+	migrateCfg := &migrate.Config{}
+	if err := env.Parse(migrateCfg); err != nil {
+		log.Fatalf("unable to parse migrate config: %s", err)
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(context.Background(), wl, migrateCfg, os.Stdout, flag.Args()[1:]); err != nil {
+			log.Fatalf("unable to migrate: %s", err)
+		}
+		return
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		log.Fatal("$PORT environment variable must be set")
 	}
 
+	if migrateCfg.AutoMigrate {
+		if err := autoMigrate(context.Background(), wl, migrateCfg); err != nil {
+			log.Fatalf("unable to migrate: %s", err)
+		}
+	}
+
 	// setup db
 	// db, err := DBConnection()
 	// if err != nil {
@@ -100,29 +138,243 @@ func main() {
 	// }
 	// defer db.Close()
 
+	// shut down gracefully on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// setup home assistant
+	haCfg := &homeassistant.Config{}
+	if err := env.Parse(haCfg); err != nil {
+		log.Fatalf("unable to parse home assistant config: %s", err)
+	}
+
+	onAirCfg := &onair.Config{}
+	if err := env.Parse(onAirCfg); err != nil {
+		log.Fatalf("unable to parse on air config: %s", err)
+	}
+
+	onAirOpts := []onair.Option{onair.WithConfig(onAirCfg)}
+	var haBridge *homeassistant.Bridge
+	if haCfg.Enabled() {
+		haBridge, err = homeassistant.New(haCfg)
+		if err != nil {
+			log.Fatalf("unable to init home assistant bridge: %s", err)
+		}
+		defer haBridge.Close()
+
+		onAirOpts = append(onAirOpts, onair.WithListener(haBridge.PublishStatus))
+	}
+
+	// setup the status events
+	publishCfg := &pubsub.PublishConfig{}
+	if err := env.Parse(publishCfg); err != nil {
+		log.Fatalf("unable to parse pubsub publish config: %s", err)
+	}
+
+	var relay *pubsub.Relay
+	if publishCfg.Enabled() {
+		outbox := pubsub.NewMemoryOutbox(publishCfg.OutboxSize)
+		relay, err = pubsub.NewRelay(publishCfg, outbox, pubsub.NewPublisher(publishCfg))
+		if err != nil {
+			log.Fatalf("unable to init pubsub relay: %s", err)
+		}
+
+		onAirOpts = append(onAirOpts, onair.WithListener(relay.Record))
+	}
+
 	// setup services
-	onAirService, err := onair.New()
+	onAirService, err := onair.New(onAirOpts...)
 	if err != nil {
 		log.Fatal("unable to init on air service: %w", err)
 	}
 
-	// setup router and handlers
-	router := mux.NewRouter().StrictSlash(true)
+	channelCfg := &channel.Config{}
+	if err := env.Parse(channelCfg); err != nil {
+		log.Fatalf("unable to parse channel config: %s", err)
+	}
 
-	router.HandleFunc("/", Index)
-	router.Handle("/onAir", handler.GetOnAirStatus(
-		wl, onAirService)).Methods(http.MethodGet, http.MethodOptions)
+	channelService, err := channel.New(channelCfg, onAirService, onair.WithConfig(onAirCfg))
+	if err != nil {
+		log.Fatalf("unable to init channels: %s", err)
+	}
 
-	router.Handle("/toggle", handler.ToggleOnAirStatus(
-		wl, onAirService)).Methods(http.MethodPost, http.MethodOptions)
+	groupCfg := &group.Config{}
+	if err := env.Parse(groupCfg); err != nil {
+		log.Fatalf("unable to parse channel group config: %s", err)
+	}
 
-	router.Handle("/onAir", handler.SetOnAirStatus(
-		wl, onAirService)).Methods(http.MethodPost, http.MethodOptions)
+	groupService, err := group.New(groupCfg, channelService)
+	if err != nil {
+		log.Fatalf("unable to init channel groups: %s", err)
+	}
+
+	statsService, err := stats.New(onAirService)
+	if err != nil {
+		log.Fatalf("unable to init stats service: %s", err)
+	}
+
+	scheduleCfg := &schedule.Config{}
+	if err := env.Parse(scheduleCfg); err != nil {
+		log.Fatalf("unable to parse schedule config: %s", err)
+	}
+
+	scheduleService, err := schedule.New(onAirService, scheduleCfg)
+	if err != nil {
+		log.Fatalf("unable to init schedule service: %s", err)
+	}
+
+	// restore the state before anything can change it
+	snapshotCfg := &snapshot.Config{}
+	if err := env.Parse(snapshotCfg); err != nil {
+		log.Fatalf("unable to parse snapshot config: %s", err)
+	}
+
+	var snapshotter *snapshot.Snapshotter
+	if snapshotCfg.Enabled() {
+		storage, err := snapshot.NewStorage(snapshotCfg)
+		if err != nil {
+			log.Fatalf("unable to init snapshot storage: %s", err)
+		}
+		defer storage.Close()
+
+		snapshotter, err = snapshot.New(snapshotCfg, storage, channelService, scheduleService)
+		if err != nil {
+			log.Fatalf("unable to init snapshots: %s", err)
+		}
+
+		if err := snapshotter.Restore(ctx, wl); err != nil {
+			log.Fatalf("unable to restore snapshot: %s", err)
+		}
+	}
+
+	if haBridge != nil {
+		if err := haBridge.Start(ctx, wl, onAirService); err != nil {
+			log.Fatalf("unable to start home assistant bridge: %s", err)
+		}
+	}
+
+	calCfg := &calendarimport.Config{}
+	if err := env.Parse(calCfg); err != nil {
+		log.Fatalf("unable to parse calendar import config: %s", err)
+	}
+
+	if calCfg.Enabled() {
+		importer, err := calendarimport.New(calCfg)
+		if err != nil {
+			log.Fatalf("unable to init calendar import: %s", err)
+		}
+		importer.Start(ctx, wl, onAirService)
+	}
+
+	go scheduleService.Run(ctx, wl)
+	go groupService.Run(ctx, wl)
+
+	// snapshots outlive ctx so the final one is taken once the server is down
+	snapshotCtx, stopSnapshots := context.WithCancel(context.Background())
+	defer stopSnapshots()
+
+	var snapshotDone <-chan struct{}
+	if snapshotter != nil {
+		snapshotDone = snapshotter.Start(snapshotCtx, wl)
+	}
+
+	// like snapshots, the events of the requests served during the shutdown
+	// are published once the server is down
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+
+	var relayDone <-chan struct{}
+	if relay != nil {
+		relayDone = relay.Start(relayCtx, wl)
+	}
+
+	pubsubCfg := &pubsub.Config{}
+	if err := env.Parse(pubsubCfg); err != nil {
+		log.Fatalf("unable to parse pubsub config: %s", err)
+	}
+
+	pubsubReceiver, err := pubsub.NewReceiver(pubsubCfg, onAirService)
+	if err != nil {
+		log.Fatalf("unable to init pubsub receiver: %s", err)
+	}
+
+	authCfg := &auth.Config{}
+	if err := env.Parse(authCfg); err != nil {
+		log.Fatalf("unable to parse auth config: %s", err)
+	}
+
+	authService, err := auth.New(authCfg)
+	if err != nil {
+		log.Fatalf("unable to init auth service: %s", err)
+	}
+
+	idempotencyCfg := &idempotency.Config{}
+	if err := env.Parse(idempotencyCfg); err != nil {
+		log.Fatalf("unable to parse idempotency config: %s", err)
+	}
+
+	var idempotencyDB *sqlx.DB
+	if idempotencyCfg.Store == idempotency.StorePostgres {
+		idempotencyDB, err = DBConnection()
+		if err != nil {
+			log.Fatalf("unable to setup db: %s", err)
+		}
+		defer idempotencyDB.Close()
+	}
+
+	idempotencyStore, err := idempotency.NewStore(idempotencyCfg, idempotencyDB)
+	if err != nil {
+		log.Fatalf("unable to init idempotency store: %s", err)
+	}
+
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Fatalf("unable to load openapi spec: %s", err)
+	}
+
+	router := newRouter(wl, spec, services{
+		onAir:    onAirService,
+		channel:  channelService,
+		group:    groupService,
+		auth:     authService,
+		stats:    statsService,
+		schedule: scheduleService,
+		pubsub:   pubsubReceiver,
+
+		idempotency: idempotencyStore,
+	})
+
+	srv := &http.Server{
+		Addr:        ":" + port,
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			wl.Error(fmt.Errorf("error shutting down: %w", err))
+		}
+	}()
 
 	wl.Debugf("running on port: %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
-}
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-shutdownDone
 
-func Index(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintln(w, "Hello there and welcome to your service!")
+	// publish the last events and take the final snapshot
+	stopRelay()
+	if relayDone != nil {
+		<-relayDone
+	}
+	stopSnapshots()
+	if snapshotDone != nil {
+		<-snapshotDone
+	}
+	wl.Info("shut down")
 }
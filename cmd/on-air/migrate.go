@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"on-air/internal/migrate"
+	"on-air/internal/wlog"
+	"on-air/migrations"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+var errMigrateUsage = errors.New("usage: on-air migrate up|down [steps]|status")
+
+// runMigrate runs the migrate subcommand: up applies the pending migrations,
+// down reverts the latest one (or steps of them) and status lists them.
+func runMigrate(ctx context.Context, wl wlog.Logger, cfg *migrate.Config, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	steps := 1
+	switch {
+	case args[0] == "down" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid steps %q: %w", args[1], errMigrateUsage)
+		}
+		steps = n
+	case len(args) > 1:
+		return errMigrateUsage
+	}
+
+	mg, err := newMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer mg.close()
+
+	switch args[0] {
+	case "up":
+		n, err := mg.Up(ctx, wl)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migrations\n", n)
+	case "down":
+		n, err := mg.Down(ctx, wl, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "reverted %d migrations\n", n)
+	case "status":
+		statuses, err := mg.Status(ctx, wl)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt.Valid {
+				applied = s.AppliedAt.Time.UTC().Format(time.RFC3339)
+			}
+			if s.Unknown {
+				applied += " (unknown to this version)"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+	default:
+		return errMigrateUsage
+	}
+
+	return nil
+}
+
+// autoMigrate applies the pending migrations at startup.
+func autoMigrate(ctx context.Context, wl wlog.Logger, cfg *migrate.Config) error {
+	mg, err := newMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer mg.close()
+
+	n, err := mg.Up(ctx, wl)
+	if err != nil {
+		return err
+	}
+	wl.Infof("applied %d migrations", n)
+
+	return nil
+}
+
+type migrator struct {
+	*migrate.Migrator
+	close func() error
+}
+
+// newMigrator connects to the database and loads the embedded migrations.
+func newMigrator(cfg *migrate.Config) (migrator, error) {
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return migrator{}, err
+	}
+
+	db, err := DBConnection()
+	if err != nil {
+		return migrator{}, fmt.Errorf("unable to setup db: %w", err)
+	}
+
+	mg, err := migrate.New(db, all, cfg)
+	if err != nil {
+		db.Close()
+		return migrator{}, err
+	}
+
+	return migrator{Migrator: mg, close: db.Close}, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"net/http"
+	"on-air/cmd/on-air/internal/dashboard"
+	"on-air/cmd/on-air/internal/handler"
+	"on-air/cmd/on-air/internal/middleware"
+	"on-air/cmd/on-air/internal/openapi"
+	"on-air/internal/entities"
+	"on-air/internal/idempotency"
+	"on-air/internal/pubsub"
+	"on-air/internal/service/auth"
+	"on-air/internal/service/channel"
+	"on-air/internal/service/group"
+	"on-air/internal/service/onair"
+	"on-air/internal/service/schedule"
+	"on-air/internal/service/stats"
+	"on-air/internal/wlog"
+
+	"github.com/gorilla/mux"
+)
+
+// services holds the dependencies of the http handlers.
+type services struct {
+	onAir    onair.SVC
+	channel  channel.SVC
+	group    group.SVC
+	auth     auth.SVC
+	stats    stats.SVC
+	schedule schedule.SVC
+	pubsub   *pubsub.Receiver
+	// idempotency keeps the responses replayed to retried requests
+	idempotency idempotency.Store
+}
+
+// newRouter registers every route of the API. Every route has to be
+// documented in the OpenAPI spec.
+func newRouter(wl wlog.Logger, spec *openapi.Spec, svcs services) *mux.Router {
+	publicPaths := []string{
+		"/",
+		"/login",
+		"/logout",
+		dashboard.AssetsPrefix,
+		"/badge.svg",
+		"/badge.png",
+		"/openapi.json",
+		// the calendar feed checks its own token
+		"/calendar.ics",
+	}
+	// Pub/Sub can't send API keys, pushes are authenticated by their token
+	// when verification is enabled
+	if svcs.pubsub.VerifyEnabled() {
+		publicPaths = append(publicPaths, "/pubsub/push")
+	}
+
+	// every authenticated user can read, the other actions are checked per route
+	setStatus := func(h http.Handler) http.Handler {
+		return middleware.Authorize(wl, svcs.auth, entities.ActionSetStatus, onair.DefaultChannel, h)
+	}
+	setChannelStatus := func(h http.Handler) http.Handler {
+		return middleware.AuthorizeChannel(wl, svcs.auth, entities.ActionSetStatus, h)
+	}
+	editSessions := func(h http.Handler) http.Handler {
+		return middleware.Authorize(wl, svcs.auth, entities.ActionEditSessions, onair.DefaultChannel, h)
+	}
+	manageSchedules := func(h http.Handler) http.Handler {
+		return middleware.Authorize(wl, svcs.auth, entities.ActionManageSchedules, "", h)
+	}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.Use(middleware.AccessLog(wl))
+	router.Use(middleware.Auth(wl, svcs.auth, publicPaths...))
+	router.Use(middleware.ValidateRequest(wl, spec))
+	router.Use(middleware.Idempotency(wl, svcs.idempotency, publicPaths...))
+
+	router.Handle("/", dashboard.Index()).Methods(http.MethodGet)
+	router.PathPrefix(dashboard.AssetsPrefix).Handler(dashboard.Assets()).Methods(http.MethodGet)
+
+	router.Handle("/openapi.json", openapi.Handler()).Methods(http.MethodGet)
+
+	// hysteresis metrics
+	router.Handle("/debug/vars", handler.GetMetrics(wl)).Methods(http.MethodGet)
+
+	router.Handle("/login", handler.Login(
+		wl, svcs.auth)).Methods(http.MethodPost, http.MethodOptions)
+
+	router.Handle("/logout", handler.Logout(
+		wl)).Methods(http.MethodPost, http.MethodOptions)
+
+	// the unversioned routes are kept for existing clients
+	router.Handle("/onAir", middleware.Deprecated("/v1/onAir", handler.GetOnAirStatus(
+		wl, svcs.onAir, handler.Legacy))).Methods(http.MethodGet, http.MethodOptions)
+
+	router.Handle("/onAir/stream", middleware.Deprecated("/v1/onAir/stream", handler.StreamOnAirStatus(
+		wl, svcs.onAir, handler.Legacy))).Methods(http.MethodGet, http.MethodOptions)
+
+	router.Handle("/history", middleware.Deprecated("/v1/history", handler.GetOnAirHistory(
+		wl, svcs.onAir, handler.Legacy))).Methods(http.MethodGet, http.MethodOptions)
+
+	router.Handle("/toggle", middleware.Deprecated("/v1/toggle", setStatus(handler.ToggleOnAirStatus(
+		wl, svcs.onAir, handler.Legacy)))).Methods(http.MethodPost, http.MethodOptions)
+
+	router.Handle("/onAir", middleware.Deprecated("/v1/onAir", setStatus(handler.SetOnAirStatus(
+		wl, svcs.onAir, handler.Legacy)))).Methods(http.MethodPost, http.MethodOptions)
+
+	router.Handle("/onAir/lock", middleware.Deprecated("/v1/onAir/lock", setStatus(handler.LockOnAirStatus(
+		wl, svcs.onAir, handler.Legacy)))).Methods(http.MethodPost, http.MethodOptions)
+
+	router.Handle("/onAir/lock", middleware.Deprecated("/v1/onAir/lock", setStatus(handler.UnlockOnAirStatus(
+		wl, svcs.onAir, handler.Legacy)))).Methods(http.MethodDelete, http.MethodOptions)
+
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	v1.Handle("/onAir", handler.GetOnAirStatus(
+		wl, svcs.onAir, handler.V1)).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/onAir/stream", handler.StreamOnAirStatus(
+		wl, svcs.onAir, handler.V1)).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/history", handler.GetOnAirHistory(
+		wl, svcs.onAir, handler.V1)).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/toggle", setStatus(handler.ToggleOnAirStatus(
+		wl, svcs.onAir, handler.V1))).Methods(http.MethodPost, http.MethodOptions)
+
+	v1.Handle("/onAir", setStatus(handler.SetOnAirStatus(
+		wl, svcs.onAir, handler.V1))).Methods(http.MethodPost, http.MethodOptions)
+
+	v1.Handle("/onAir/claims/{source}", setStatus(handler.ReleaseClaim(
+		wl, svcs.onAir, handler.V1))).Methods(http.MethodDelete, http.MethodOptions)
+
+	v1.Handle("/onAir/lock", setStatus(handler.LockOnAirStatus(
+		wl, svcs.onAir, handler.V1))).Methods(http.MethodPost, http.MethodOptions)
+
+	v1.Handle("/onAir/lock", setStatus(handler.UnlockOnAirStatus(
+		wl, svcs.onAir, handler.V1))).Methods(http.MethodDelete, http.MethodOptions)
+
+	v1.Handle("/channels/{channel}/onAir", handler.ForChannel(wl, svcs.channel, func(s onair.SVC) http.Handler {
+		return handler.GetOnAirStatus(wl, s, handler.V1)
+	})).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/channels/{channel}/onAir/stream", handler.ForChannel(wl, svcs.channel, func(s onair.SVC) http.Handler {
+		return handler.StreamOnAirStatus(wl, s, handler.V1)
+	})).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/channels/{channel}/toggle", setChannelStatus(handler.ForChannel(wl, svcs.channel, func(s onair.SVC) http.Handler {
+		return handler.ToggleOnAirStatus(wl, s, handler.V1)
+	}))).Methods(http.MethodPost, http.MethodOptions)
+
+	v1.Handle("/channels/{channel}/onAir", setChannelStatus(handler.ForChannel(wl, svcs.channel, func(s onair.SVC) http.Handler {
+		return handler.SetOnAirStatus(wl, s, handler.V1)
+	}))).Methods(http.MethodPost, http.MethodOptions)
+
+	v1.Handle("/channels/{channel}/onAir/claims/{source}", setChannelStatus(handler.ForChannel(wl, svcs.channel, func(s onair.SVC) http.Handler {
+		return handler.ReleaseClaim(wl, s, handler.V1)
+	}))).Methods(http.MethodDelete, http.MethodOptions)
+
+	v1.Handle("/channels/{channel}/onAir/lock", setChannelStatus(handler.ForChannel(wl, svcs.channel, func(s onair.SVC) http.Handler {
+		return handler.LockOnAirStatus(wl, s, handler.V1)
+	}))).Methods(http.MethodPost, http.MethodOptions)
+
+	v1.Handle("/channels/{channel}/onAir/lock", setChannelStatus(handler.ForChannel(wl, svcs.channel, func(s onair.SVC) http.Handler {
+		return handler.UnlockOnAirStatus(wl, s, handler.V1)
+	}))).Methods(http.MethodDelete, http.MethodOptions)
+
+	// groups are read-only virtual channels
+	v1.Handle("/groups/{group}/onAir", handler.ForGroup(wl, svcs.group, func(s onair.Reader) http.Handler {
+		return handler.GetOnAirStatus(wl, s, handler.V1)
+	})).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/groups/{group}/onAir/stream", handler.ForGroup(wl, svcs.group, func(s onair.Reader) http.Handler {
+		return handler.StreamOnAirStatus(wl, s, handler.V1)
+	})).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/sessions", handler.ListSessions(
+		wl, svcs.onAir)).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/sessions/{id}", handler.GetSession(
+		wl, svcs.onAir)).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/sessions/{id}", editSessions(handler.UpdateSession(
+		wl, svcs.onAir))).Methods(http.MethodPatch, http.MethodOptions)
+
+	v1.Handle("/export", handler.Export(
+		wl, svcs.onAir)).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/stats", handler.GetStats(
+		wl, svcs.stats)).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/schedules", handler.ListSchedules(
+		wl, svcs.schedule)).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/schedules", manageSchedules(handler.CreateSchedule(
+		wl, svcs.schedule))).Methods(http.MethodPost, http.MethodOptions)
+
+	v1.Handle("/schedules/{id}", manageSchedules(handler.DeleteSchedule(
+		wl, svcs.schedule))).Methods(http.MethodDelete, http.MethodOptions)
+
+	v1.Handle("/me/permissions", handler.GetPermissions(
+		wl, svcs.auth)).Methods(http.MethodGet, http.MethodOptions)
+
+	v1.Handle("/calendar/token", handler.GetCalendarToken(
+		wl, svcs.auth)).Methods(http.MethodGet, http.MethodOptions)
+
+	router.Handle("/calendar.ics", handler.Calendar(
+		wl, svcs.auth, svcs.onAir, svcs.schedule)).Methods(http.MethodGet, http.MethodOptions)
+
+	// pushes authenticated by their token don't carry a user
+	var pubsubPush http.Handler = handler.PubSubPush(wl, svcs.pubsub)
+	if !svcs.pubsub.VerifyEnabled() {
+		pubsubPush = setStatus(pubsubPush)
+	}
+	router.Handle("/pubsub/push", pubsubPush).Methods(http.MethodPost, http.MethodOptions)
+
+	router.Handle("/badge.svg", handler.BadgeSVG(
+		wl, svcs.channel, svcs.group)).Methods(http.MethodGet, http.MethodOptions)
+
+	router.Handle("/badge.png", handler.BadgePNG(
+		wl, svcs.channel, svcs.group)).Methods(http.MethodGet, http.MethodOptions)
+
+	return router
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"on-air/cmd/on-air/internal/openapi"
+	"on-air/internal/idempotency"
+	"on-air/internal/pubsub"
+	"on-air/internal/service/auth"
+	"on-air/internal/service/channel"
+	"on-air/internal/service/group"
+	"on-air/internal/service/onair"
+	"on-air/internal/service/schedule"
+	"on-air/internal/service/stats"
+	"on-air/internal/wlog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gotest.tools/v3/assert"
+)
+
+func testRouter(t *testing.T) (*mux.Router, *openapi.Spec) {
+	t.Helper()
+	return testRouterWithAuth(t, &auth.Config{SessionTTL: auth.DefaultSessionTTL, CalendarFeed: true})
+}
+
+func testRouterWithAuth(t *testing.T, authCfg *auth.Config) (*mux.Router, *openapi.Spec) {
+	t.Helper()
+
+	spec, err := openapi.Load()
+	assert.NilError(t, err)
+
+	onAirService, err := onair.New()
+	assert.NilError(t, err)
+
+	authService, err := auth.New(authCfg)
+	assert.NilError(t, err)
+
+	channelService, err := channel.New(&channel.Config{Channels: []string{"studio-a", "studio-b"}}, onAirService)
+	assert.NilError(t, err)
+
+	groupService, err := group.New(&group.Config{Groups: []string{
+		"id=hallway;policy=any;channels=default|studio-a|studio-b",
+	}}, channelService)
+	assert.NilError(t, err)
+
+	statsService, err := stats.New(onAirService)
+	assert.NilError(t, err)
+
+	scheduleService, err := schedule.New(onAirService, &schedule.Config{Tick: time.Second})
+	assert.NilError(t, err)
+
+	pubsubReceiver, err := pubsub.NewReceiver(&pubsub.Config{DedupeTTL: time.Hour}, onAirService)
+	assert.NilError(t, err)
+
+	return newRouter(wlog.NewNopLogger(), spec, services{
+		onAir:    onAirService,
+		channel:  channelService,
+		group:    groupService,
+		auth:     authService,
+		stats:    statsService,
+		schedule: scheduleService,
+		pubsub:   pubsubReceiver,
+
+		idempotency: idempotency.NewMemoryStore(time.Hour),
+	}), spec
+}
+
+func TestRoutesAreDocumented(t *testing.T) {
+	router, spec := testRouter(t)
+
+	registered := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		// subrouter mounts have no handler of their own
+		if route.GetHandler() == nil {
+			return nil
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+
+		for _, m := range methods {
+			// preflight requests are not part of the API contract
+			if m == http.MethodOptions {
+				continue
+			}
+			registered[m+" "+tpl] = true
+
+			_, ok := spec.Operation(tpl, m)
+			assert.Check(t, ok, "route %s %s is not documented in openapi.json", m, tpl)
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			key := strings.ToUpper(method) + " " + path
+			assert.Check(t, registered[key], "%s is documented in openapi.json but not registered", key)
+		}
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	router, _ := testRouter(t)
+
+	testData := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			"valid body",
+			http.MethodPost, "/onAir", `{"is_on_air":true,"message":"live"}`,
+			200, "",
+		},
+		{
+			"wrong body type",
+			http.MethodPost, "/onAir", `{"is_on_air":"yes"}`,
+			400, `{"error":{"body":{"is_on_air":"must be a boolean"}}}`,
+		},
+		{
+			"missing body",
+			http.MethodPost, "/onAir", ``,
+			400, `{"error":{"body":"is required"}}`,
+		},
+		{
+			"invalid query parameter",
+			http.MethodGet, "/history?limit=0", ``,
+			400, `{"error":{"query":{"limit":"must be no less than 1"}}}`,
+		},
+		{
+			"invalid enum",
+			http.MethodGet, "/badge.svg?style=round", ``,
+			400, `{"error":{"query":{"style":"must be a valid value"}}}`,
+		},
+		{
+			"v1 requires is_on_air",
+			http.MethodPost, "/v1/onAir", `{"message":"live"}`,
+			400, `{"error":{"body":{"is_on_air":"is required"}}}`,
+		},
+		{
+			"invalid duration",
+			http.MethodGet, "/onAir?wait=soon", ``,
+			400, `{"error":{"query":{"wait":"must be in a valid format"}}}`,
+		},
+		{
+			"schedule ending before it starts",
+			http.MethodPost, "/v1/schedules", `{"start":"2024-01-02T15:00:00Z","end":"2024-01-02T14:00:00Z"}`,
+			400, `{"error":{"end":"must be after start"}}`,
+		},
+		{
+			"body too large",
+			http.MethodPost, "/v1/onAir", `{"is_on_air":true,"message":"` + strings.Repeat("a", openapi.MaxBodySize) + `"}`,
+			413, `{"error":"body must be at most 1048576 bytes"}`,
+		},
+		{
+			"invalid calendar range",
+			http.MethodGet, "/calendar.ics?days=400", ``,
+			400, `{"error":{"query":{"days":"must be no greater than 366"}}}`,
+		},
+	}
+
+	for _, tc := range testData {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, w.Code, tc.expectedCode, tc.name)
+		if tc.expectedResp != "" {
+			assert.Equal(t, strings.TrimSpace(w.Body.String()), tc.expectedResp, tc.name)
+		}
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	router, _ := testRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/onAir", nil))
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("Deprecation"), "true")
+	assert.Equal(t, w.Header().Get("Link"), `</v1/onAir>; rel="successor-version"`)
+	assert.Assert(t, strings.Contains(w.Body.String(), `"IsOnAir":false`))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/onAir", nil))
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("Deprecation"), "")
+	assert.Assert(t, strings.Contains(w.Body.String(), `"is_on_air":false`))
+	assert.Assert(t, strings.Contains(w.Body.String(), `"last_on_air":null`))
+}
+
+func TestRoles(t *testing.T) {
+	router, _ := testRouterWithAuth(t, &auth.Config{
+		APIKeys: []string{
+			"viewer:v-key:viewer",
+			"studio:s-key:operator:" + onair.DefaultChannel,
+			"other:o-key:operator:studio-b",
+			"admin:a-key",
+			// keys can contain colons
+			"legacy:l-key:with:colons",
+			"colons:c-key:with:colons:viewer",
+		},
+		SessionTTL: auth.DefaultSessionTTL,
+	})
+
+	testData := []struct {
+		name         string
+		key          string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+	}{
+		{"viewer reads", "v-key", http.MethodGet, "/v1/onAir", "", http.StatusOK},
+		{"viewer can't toggle", "v-key", http.MethodPost, "/v1/toggle", "", http.StatusForbidden},
+		{"viewer can't use the legacy route", "v-key", http.MethodPost, "/toggle", "", http.StatusForbidden},
+		{"viewer can't edit sessions", "v-key", http.MethodPatch, "/v1/sessions/nope", `{"notes": "ep 1"}`, http.StatusForbidden},
+		{"operator toggles its channel", "s-key", http.MethodPost, "/v1/toggle", "", http.StatusOK},
+		{"operator of another channel", "o-key", http.MethodPost, "/v1/toggle", "", http.StatusForbidden},
+		{"operator can't schedule", "s-key", http.MethodPost, "/v1/schedules",
+			`{"start": "2030-01-01T10:00:00Z", "end": "2030-01-01T11:00:00Z"}`, http.StatusForbidden},
+		{"admin schedules", "a-key", http.MethodPost, "/v1/schedules",
+			`{"start": "2030-01-01T10:00:00Z", "end": "2030-01-01T11:00:00Z"}`, http.StatusCreated},
+		{"admin toggles", "a-key", http.MethodPost, "/v1/toggle", "", http.StatusOK},
+		{"operator toggles a granted channel", "o-key", http.MethodPost, "/v1/channels/studio-b/toggle", "", http.StatusOK},
+		{"operator can't toggle another channel", "o-key", http.MethodPost, "/v1/channels/studio-a/toggle", "", http.StatusForbidden},
+		{"key with colons", "l-key:with:colons", http.MethodPost, "/v1/toggle", "", http.StatusOK},
+		{"key with colons and a role", "c-key:with:colons", http.MethodPost, "/v1/toggle", "", http.StatusForbidden},
+		{"the role isn't part of the key", "c-key:with:colons:viewer", http.MethodGet, "/v1/onAir", "", http.StatusUnauthorized},
+	}
+
+	for _, tc := range testData {
+		r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		r.Header.Set("Authorization", "Bearer "+tc.key)
+		if tc.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, w.Code, tc.expectedCode, tc.name)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/me/permissions", nil)
+	r.Header.Set("Authorization", "Bearer o-key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, strings.TrimSpace(w.Body.String()),
+		`{"user_id":"other","role":"operator","actions":["read"],"channels":{"studio-b":["set_status","edit_sessions"]}}`)
+}
+
+func TestCalendarFeed(t *testing.T) {
+	_, err := auth.New(&auth.Config{
+		APIKeys:      []string{"admin:a-key:admin"},
+		SessionTTL:   auth.DefaultSessionTTL,
+		CalendarFeed: true,
+	})
+	assert.ErrorContains(t, err, "is required by the calendar feed")
+
+	cfg := func(keys ...string) *auth.Config {
+		return &auth.Config{
+			APIKeys:       keys,
+			SessionSecret: "secret",
+			SessionTTL:    auth.DefaultSessionTTL,
+			CalendarFeed:  true,
+		}
+	}
+	get := func(router *mux.Router, path, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			r.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	router, _ := testRouterWithAuth(t, cfg("admin:a-key:admin", "viewer:v-key:viewer"))
+	w := get(router, "/v1/calendar/token", "v-key")
+	assert.Equal(t, w.Code, http.StatusOK)
+	var resp struct {
+		Token string `json:"token"`
+	}
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	feed := "/calendar.ics?token=" + url.QueryEscape(resp.Token)
+	assert.Equal(t, get(router, feed, "").Code, http.StatusOK)
+	assert.Equal(t, get(router, "/calendar.ics?token=nope", "").Code, http.StatusUnauthorized)
+
+	// the tokens survive a restart
+	router, _ = testRouterWithAuth(t, cfg("admin:a-key:admin", "viewer:v-key:viewer"))
+	assert.Equal(t, get(router, feed, "").Code, http.StatusOK)
+
+	// changing the keys of the user revokes their tokens
+	router, _ = testRouterWithAuth(t, cfg("admin:a-key:admin", "viewer:v-key2:viewer"))
+	assert.Equal(t, get(router, feed, "").Code, http.StatusUnauthorized)
+
+	router, _ = testRouterWithAuth(t, &auth.Config{SessionTTL: auth.DefaultSessionTTL})
+	assert.Equal(t, get(router, "/v1/calendar/token", "").Code, http.StatusNotFound)
+	assert.Equal(t, get(router, "/calendar.ics", "").Code, http.StatusNotFound)
+}
+
+func TestChannelRoutes(t *testing.T) {
+	router, _ := testRouter(t)
+
+	testData := []struct {
+		name         string
+		method       string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"toggle a channel", http.MethodPost, "/v1/channels/studio-a/toggle", http.StatusOK, `"is_on_air":true`},
+		{"the channel is on air", http.MethodGet, "/v1/channels/studio-a/onAir", http.StatusOK, `"is_on_air":true`},
+		{"the default channel isn't", http.MethodGet, "/v1/channels/default/onAir", http.StatusOK, `"is_on_air":false`},
+		{"unknown channel", http.MethodGet, "/v1/channels/nope/onAir", http.StatusNotFound, ""},
+		{"group", http.MethodGet, "/v1/groups/hallway/onAir", http.StatusOK, `"revision":1`},
+		{"groups are read-only", http.MethodPost, "/v1/groups/hallway/onAir", http.StatusNotFound, ""},
+		{"unknown group", http.MethodGet, "/v1/groups/nope/onAir", http.StatusNotFound, ""},
+		{"channel badge", http.MethodGet, "/badge.svg?channel=studio-a", http.StatusOK, ""},
+		{"group badge", http.MethodGet, "/badge.svg?group=hallway", http.StatusOK, ""},
+		{"unknown group badge", http.MethodGet, "/badge.png?group=nope", http.StatusNotFound, ""},
+		{"channel and group badge", http.MethodGet, "/badge.svg?channel=studio-a&group=hallway", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range testData {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, w.Code, tc.expectedCode, tc.name)
+		assert.Assert(t, strings.Contains(w.Body.String(), tc.expectedBody), tc.name)
+	}
+}
+
+func TestLock(t *testing.T) {
+	router, _ := testRouterWithAuth(t, &auth.Config{
+		APIKeys: []string{
+			"alice:al-key:operator",
+			"bob:b-key:operator",
+			"admin:a-key",
+		},
+		SessionTTL: auth.DefaultSessionTTL,
+	})
+
+	// the steps share the router and run in order
+	testData := []struct {
+		name         string
+		key          string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"lock", "al-key", http.MethodPost, "/v1/onAir/lock",
+			`{"reason": "live show", "expires_at": "2100-01-01T00:00:00Z"}`, http.StatusOK, `"holder":"alice"`},
+		{"others can't toggle", "b-key", http.MethodPost, "/v1/toggle", "", http.StatusConflict,
+			"status locked by alice until 2100-01-01T00:00:00Z: live show"},
+		{"others can't set", "b-key", http.MethodPost, "/v1/onAir", `{"is_on_air": true}`, http.StatusConflict, ""},
+		{"others can't use the legacy route", "b-key", http.MethodPost, "/toggle", "", http.StatusConflict, ""},
+		{"others can't take the lock", "b-key", http.MethodPost, "/v1/onAir/lock", "", http.StatusConflict, ""},
+		{"others can't unlock", "b-key", http.MethodDelete, "/v1/onAir/lock", "", http.StatusConflict, ""},
+		{"the holder toggles", "al-key", http.MethodPost, "/v1/toggle", "", http.StatusOK, `"is_on_air":true`},
+		{"admins set", "a-key", http.MethodPost, "/v1/onAir", `{"is_on_air": false}`, http.StatusOK, `"reason":"live show"`},
+		{"the status shows the lock", "b-key", http.MethodGet, "/v1/onAir", "", http.StatusOK, `"holder":"alice"`},
+		{"the history shows the lock", "b-key", http.MethodGet, "/v1/history?limit=1", "", http.StatusOK, `"holder":"alice"`},
+		{"other channels aren't locked", "b-key", http.MethodPost, "/v1/channels/studio-a/toggle", "", http.StatusOK, ""},
+		{"expired lock", "al-key", http.MethodPost, "/v1/onAir/lock",
+			`{"expires_at": "2000-01-01T00:00:00Z"}`, http.StatusBadRequest, ""},
+		{"unlock", "al-key", http.MethodDelete, "/v1/onAir/lock", "", http.StatusOK, `"lock":null`},
+		{"unlock again", "al-key", http.MethodDelete, "/v1/onAir/lock", "", http.StatusOK, `"lock":null`},
+		{"others toggle", "b-key", http.MethodPost, "/v1/toggle", "", http.StatusOK, `"is_on_air":true`},
+		{"lock with the legacy route", "al-key", http.MethodPost, "/onAir/lock", "", http.StatusOK, `"IsOnAir":true`},
+		{"others can't toggle the legacy lock", "b-key", http.MethodPost, "/v1/toggle", "", http.StatusConflict, ""},
+		{"unlock with the legacy route", "al-key", http.MethodDelete, "/onAir/lock", "", http.StatusOK, ""},
+		{"others toggle again", "b-key", http.MethodPost, "/v1/toggle", "", http.StatusOK, `"is_on_air":false`},
+	}
+
+	for _, tc := range testData {
+		r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		r.Header.Set("Authorization", "Bearer "+tc.key)
+		if tc.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, w.Code, tc.expectedCode, tc.name)
+		assert.Assert(t, strings.Contains(w.Body.String(), tc.expectedBody), "%s: %s", tc.name, w.Body.String())
+	}
+}
+
+func TestSessions(t *testing.T) {
+	router, _ := testRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/toggle", nil))
+	assert.Equal(t, w.Code, http.StatusOK)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/sessions", nil))
+	assert.Equal(t, w.Code, http.StatusOK)
+	var sessions []struct {
+		ID      string `json:"id"`
+		Running bool   `json:"running"`
+	}
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	assert.Equal(t, len(sessions), 1)
+	assert.Assert(t, sessions[0].Running)
+	session := "/v1/sessions/" + sessions[0].ID
+
+	// the steps share the router and run in order
+	testData := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"edit notes and tags", http.MethodPatch, session, `{"notes": "ep 1", "tags": ["Podcast", "podcast", "live"]}`,
+			http.StatusOK, `"notes":"ep 1","tags":["podcast","live"]`},
+		{"omitted fields are kept", http.MethodPatch, session, `{"notes": "ep 1, take 2"}`,
+			http.StatusOK, `"notes":"ep 1, take 2","tags":["podcast","live"]`},
+		{"clear the tags", http.MethodPatch, session, `{"tags": []}`,
+			http.StatusOK, `"tags":[]`},
+		{"empty tag", http.MethodPatch, session, `{"tags": [""]}`, http.StatusBadRequest, ""},
+		{"notes too long", http.MethodPatch, session, `{"notes": "` + strings.Repeat("n", 2001) + `"}`, http.StatusBadRequest, ""},
+		{"unknown session", http.MethodPatch, "/v1/sessions/nope", `{"notes": "ep 2"}`, http.StatusNotFound, ""},
+		{"the session is still running", http.MethodGet, session, "",
+			http.StatusOK, `"end":null,"duration_seconds":0,"running":true,"notes":"ep 1, take 2"`},
+	}
+
+	for _, tc := range testData {
+		r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, w.Code, tc.expectedCode, "%s: %s", tc.name, w.Body.String())
+		assert.Assert(t, strings.Contains(w.Body.String(), tc.expectedBody), "%s: %s", tc.name, w.Body.String())
+	}
+}
+
+func TestExport(t *testing.T) {
+	router, _ := testRouter(t)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, serve(http.MethodPost, "/v1/toggle", "").Code, http.StatusOK)
+	assert.Equal(t, serve(http.MethodPost, "/v1/toggle", "").Code, http.StatusOK)
+
+	w := serve(http.MethodGet, "/v1/sessions", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	var sessions []struct {
+		ID string `json:"id"`
+	}
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	assert.Equal(t, len(sessions), 1)
+
+	w = serve(http.MethodPatch, "/v1/sessions/"+sessions[0].ID, `{"notes": "=HYPERLINK(\"https://example.com\")", "tags": ["@ops", "live"]}`)
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+
+	w = serve(http.MethodGet, "/v1/export?type=sessions&format=csv&columns=notes,tags,duration_seconds", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("Content-Type"), "text/csv; charset=utf-8")
+
+	// the cells read as formulas are quoted
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.Equal(t, lines[0], "notes,tags,duration_seconds")
+	assert.Equal(t, lines[1], `"'=HYPERLINK(""https://example.com"")",'@ops;live,0`)
+}
+
+func TestMetrics(t *testing.T) {
+	router, _ := testRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Assert(t, strings.Contains(w.Body.String(), `"onair":`), w.Body.String())
+	assert.Assert(t, !strings.Contains(w.Body.String(), `"cmdline"`), w.Body.String())
+	assert.Assert(t, !strings.Contains(w.Body.String(), `"memstats"`), w.Body.String())
+}
+
+func TestIdempotency(t *testing.T) {
+	router, _ := testRouter(t)
+
+	// the steps share the router and run in order
+	testData := []struct {
+		name             string
+		key              string
+		method           string
+		path             string
+		body             string
+		expectedCode     int
+		expectedBody     string
+		expectedReplayed bool
+	}{
+		{"toggle", "k1", http.MethodPost, "/v1/toggle", "", http.StatusOK, `"revision":2`, false},
+		{"retry", "k1", http.MethodPost, "/v1/toggle", "", http.StatusOK, `"revision":2`, true},
+		{"the toggle is applied once", "", http.MethodGet, "/v1/onAir", "", http.StatusOK, `"is_on_air":true`, false},
+		{"another key", "k2", http.MethodPost, "/v1/toggle", "", http.StatusOK, `"revision":3`, false},
+		{"set", "k3", http.MethodPost, "/v1/onAir", `{"is_on_air": true, "message": "live"}`, http.StatusOK, `"revision":4`, false},
+		{"retry set", "k3", http.MethodPost, "/v1/onAir", `{"is_on_air": true, "message": "live"}`, http.StatusOK, `"revision":4`, true},
+		{"different body", "k3", http.MethodPost, "/v1/onAir", `{"is_on_air": false}`, http.StatusUnprocessableEntity, "different request", false},
+		{"different route", "k3", http.MethodPost, "/v1/toggle", "", http.StatusUnprocessableEntity, "different request", false},
+		{"key too long", strings.Repeat("k", 256), http.MethodPost, "/v1/toggle", "", http.StatusBadRequest, "", false},
+		{"no key", "", http.MethodPost, "/v1/toggle", "", http.StatusOK, `"revision":5`, false},
+	}
+
+	for _, tc := range testData {
+		r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.key != "" {
+			r.Header.Set("Idempotency-Key", tc.key)
+		}
+		if tc.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, w.Code, tc.expectedCode, tc.name)
+		assert.Assert(t, strings.Contains(w.Body.String(), tc.expectedBody), "%s: %s", tc.name, w.Body.String())
+		assert.Equal(t, w.Header().Get("Idempotent-Replayed") == "true", tc.expectedReplayed, tc.name)
+	}
+}
+
+func TestClaims(t *testing.T) {
+	router, _ := testRouter(t)
+
+	push := `{"message": {"messageId": "1", "attributes": {"action": "set", "is_on_air": "true", "message": "from pubsub"}},
+		"subscription": "projects/p/subscriptions/s"}`
+
+	// the steps share the router and run in order
+	testData := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"nothing claims the status", http.MethodGet, "/v1/onAir", "", http.StatusOK, `"source":null,"claims":[]`},
+		{"pubsub claims it", http.MethodPost, "/pubsub/push", push, http.StatusOK, ""},
+		{"the status is the pubsub claim", http.MethodGet, "/v1/onAir", "", http.StatusOK,
+			`"is_on_air":true,"message":"from pubsub"`},
+		{"manual beats pubsub", http.MethodPost, "/v1/onAir", `{"is_on_air": false}`, http.StatusOK,
+			`"is_on_air":false,"message":"","last_updated"`},
+		{"both claims are listed", http.MethodGet, "/v1/onAir", "", http.StatusOK,
+			`"source":"manual","claims":[{"source":"manual","is_on_air":false`},
+		{"the history has the source", http.MethodGet, "/v1/history?limit=1", "", http.StatusOK, `"source":"manual"`},
+		{"release the manual claim", http.MethodDelete, "/v1/onAir/claims/manual", "", http.StatusOK,
+			`"is_on_air":true,"message":"from pubsub"`},
+		{"release it again", http.MethodDelete, "/v1/onAir/claims/manual", "", http.StatusOK, `"source":"pubsub"`},
+		{"unknown source", http.MethodDelete, "/v1/onAir/claims/nope", "", http.StatusNotFound, ""},
+		{"expired claim", http.MethodPost, "/v1/onAir", `{"is_on_air": true, "expires_at": "2000-01-01T00:00:00Z"}`,
+			http.StatusBadRequest, ""},
+		{"expiring claim", http.MethodPost, "/v1/onAir", `{"is_on_air": false, "expires_at": "2100-01-01T00:00:00Z"}`,
+			http.StatusOK, `"expires_at":"2100-01-01T00:00:00Z"`},
+	}
+
+	for _, tc := range testData {
+		r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, w.Code, tc.expectedCode, tc.name)
+		assert.Assert(t, strings.Contains(w.Body.String(), tc.expectedBody), "%s: %s", tc.name, w.Body.String())
+	}
+}
@@ -12,6 +12,8 @@ type ContextKey string
 const (
 	// ContextKeyUserID holds the context key for the user ID.
 	ContextKeyUserID ContextKey = "userID"
+	// ContextKeyUserRole holds the context key for the user role.
+	ContextKeyUserRole ContextKey = "userRole"
 	// ContextKeyRequestIDHeader holds the context key for the request ID header.
 	ContextKeyRequestIDHeader ContextKey = "X-Request-ID"
 	// ContextKeyCallerIDHeader holds the context key for the caller ID header.
@@ -38,3 +40,15 @@ func UserID(ctx context.Context) (string, error) {
 	}
 	return userID, nil
 }
+
+// WithUserRole creates a new context with the passed user role.
+func WithUserRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, ContextKeyUserRole, role)
+}
+
+// UserRole attempts to retrieve the user role from the context.
+// It returns an empty string if no role is found.
+func UserRole(ctx context.Context) string {
+	role, _ := ctx.Value(ContextKeyUserRole).(string)
+	return role
+}
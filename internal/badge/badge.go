@@ -0,0 +1,151 @@
+// Package badge renders the on air status as a shields-style badge
+// in either SVG or PNG format.
+package badge
+
+import (
+	"fmt"
+	"on-air/internal/entities"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Style is the visual style of a badge.
+type Style string
+
+// Supported badge styles.
+const (
+	StyleFlat        Style = "flat"
+	StyleFlatSquare  Style = "flat-square"
+	StyleForTheBadge Style = "for-the-badge"
+)
+
+// Size is the scale a badge is rendered at.
+type Size string
+
+// Supported badge sizes.
+const (
+	SizeSmall  Size = "small"
+	SizeMedium Size = "medium"
+	SizeLarge  Size = "large"
+)
+
+// Badge colors.
+const (
+	ColorLabel  = "#555555"
+	ColorOnAir  = "#e05d44"
+	ColorOffAir = "#9f9f9f"
+)
+
+const (
+	defaultLabel  = "on air"
+	separator     = " | "
+	maxMessageLen = 40
+)
+
+var scales = map[Size]int{
+	SizeSmall:  1,
+	SizeMedium: 2,
+	SizeLarge:  3,
+}
+
+// Options holds the user controllable parts of a badge.
+type Options struct {
+	Style Style  `json:"style"`
+	Label string `json:"label"`
+	Size  Size   `json:"size"`
+}
+
+// Validate makes sure the options are valid.
+// It returns an error when the options are not valid.
+func (o *Options) Validate() error {
+	return validation.ValidateStruct(
+		o,
+		validation.Field(&o.Style, validation.In(StyleFlat, StyleFlatSquare, StyleForTheBadge)),
+		validation.Field(&o.Size, validation.In(SizeSmall, SizeMedium, SizeLarge)),
+		validation.Field(&o.Label, validation.RuneLength(0, maxMessageLen)),
+	)
+}
+
+// Badge is a two-part badge: a label on the left and the status on the right.
+type Badge struct {
+	Label string
+	Value string
+	Color string
+	Style Style
+	Scale int
+}
+
+// New builds the badge for the status as of now.
+func New(onAir entities.OnAirStatus, opts Options, now time.Time) Badge {
+	b := Badge{
+		Label: opts.Label,
+		Value: "off air",
+		Color: ColorOffAir,
+		Style: opts.Style,
+		Scale: scales[opts.Size],
+	}
+
+	if b.Label == "" {
+		b.Label = defaultLabel
+	}
+	if b.Style == "" {
+		b.Style = StyleFlat
+	}
+	if b.Scale == 0 {
+		b.Scale = scales[SizeSmall]
+	}
+
+	if onAir.IsOnAir {
+		b.Value = "live"
+		b.Color = ColorOnAir
+	}
+
+	if msg := truncate(onAir.Message, maxMessageLen); msg != "" {
+		b.Value += separator + msg
+	}
+
+	if onAir.LastUpdated.Valid {
+		b.Value += separator + Since(now.Sub(onAir.LastUpdated.Time))
+	}
+
+	if b.Style == StyleForTheBadge {
+		b.Label = strings.ToUpper(b.Label)
+		b.Value = strings.ToUpper(b.Value)
+	}
+
+	return b
+}
+
+// Since formats a duration for display with a minute granularity,
+// e.g. "5m", "2h 3m" or "3d 4h".
+func Since(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+
+	d = d.Truncate(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	return string([]rune(s)[:n-3]) + "..."
+}
@@ -0,0 +1,77 @@
+package badge_test
+
+import (
+	"on-air/internal/badge"
+	"on-air/internal/entities"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"gotest.tools/v3/assert"
+)
+
+func TestSince(t *testing.T) {
+	testData := []struct {
+		name     string
+		input    time.Duration
+		expected string
+	}{
+		{"seconds", 30 * time.Second, "<1m"},
+		{"minutes", 5*time.Minute + 20*time.Second, "5m"},
+		{"hours", 2*time.Hour + 3*time.Minute, "2h 3m"},
+		{"days", 76 * time.Hour, "3d 4h"},
+	}
+
+	for _, tc := range testData {
+		assert.Equal(t, badge.Since(tc.input), tc.expected, tc.name)
+	}
+}
+
+func TestNew(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	onAir := entities.OnAirStatus{
+		IsOnAir:     true,
+		Message:     "podcast",
+		LastUpdated: null.TimeFrom(now.Add(-90 * time.Minute)),
+	}
+
+	testData := []struct {
+		name     string
+		opts     badge.Options
+		expected badge.Badge
+	}{
+		{
+			"defaults",
+			badge.Options{},
+			badge.Badge{Label: "on air", Value: "live | podcast | 1h 30m", Color: badge.ColorOnAir, Style: badge.StyleFlat, Scale: 1},
+		},
+		{
+			"for the badge",
+			badge.Options{Style: badge.StyleForTheBadge, Label: "studio", Size: badge.SizeLarge},
+			badge.Badge{Label: "STUDIO", Value: "LIVE | PODCAST | 1H 30M", Color: badge.ColorOnAir, Style: badge.StyleForTheBadge, Scale: 3},
+		},
+	}
+
+	for _, tc := range testData {
+		assert.DeepEqual(t, badge.New(onAir, tc.opts, now), tc.expected)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.NilError(t, (&badge.Options{Style: badge.StyleFlatSquare, Size: badge.SizeMedium}).Validate())
+	assert.ErrorContains(t, (&badge.Options{Style: "round"}).Validate(), "style")
+	assert.ErrorContains(t, (&badge.Options{Label: strings.Repeat("a", 41)}).Validate(), "label")
+}
+
+func TestRender(t *testing.T) {
+	b := badge.New(entities.OnAirStatus{}, badge.Options{Size: badge.SizeMedium}, time.Now())
+
+	svg, err := b.SVG()
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(string(svg), "<svg"))
+
+	png, err := b.PNG()
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(string(png), "\x89PNG"))
+}
@@ -0,0 +1,111 @@
+package badge
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	pngPadding    = 6
+	pngHeight     = 20
+	pngTallHeight = 28
+	pngRadius     = 3
+)
+
+// PNG renders the badge as a PNG image using a fixed-width bitmap font.
+func (b Badge) PNG() ([]byte, error) {
+	face := basicfont.Face7x13
+
+	height := pngHeight
+	radius := pngRadius
+	switch b.Style {
+	case StyleFlatSquare:
+		radius = 0
+	case StyleForTheBadge:
+		height = pngTallHeight
+		radius = 0
+	}
+
+	labelWidth := textWidth(b.Label, face.Advance) + 2*pngPadding
+	valueWidth := textWidth(b.Value, face.Advance) + 2*pngPadding
+	width := labelWidth + valueWidth
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, image.Rect(0, 0, labelWidth, height),
+		image.NewUniform(hexColor(ColorLabel)), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(labelWidth, 0, width, height),
+		image.NewUniform(hexColor(b.Color)), image.Point{}, draw.Src)
+
+	baseline := (height+face.Ascent-face.Descent)/2 + 1
+	d := font.Drawer{Dst: img, Src: image.White, Face: face}
+	d.Dot = fixed.P(pngPadding, baseline)
+	d.DrawString(b.Label)
+	d.Dot = fixed.P(labelWidth+pngPadding, baseline)
+	d.DrawString(b.Value)
+
+	roundCorners(img, radius)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scale(img, b.Scale)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// roundCorners clears the pixels outside of a circle of radius r in every corner.
+func roundCorners(img *image.RGBA, r int) {
+	bounds := img.Bounds()
+	for y := 0; y < r; y++ {
+		for x := 0; x < r; x++ {
+			dx, dy := r-x, r-y
+			if dx*dx+dy*dy <= r*r {
+				continue
+			}
+			img.Set(x, y, color.Transparent)
+			img.Set(bounds.Max.X-1-x, y, color.Transparent)
+			img.Set(x, bounds.Max.Y-1-y, color.Transparent)
+			img.Set(bounds.Max.X-1-x, bounds.Max.Y-1-y, color.Transparent)
+		}
+	}
+}
+
+// scale enlarges the image by an integer factor using nearest neighbor sampling
+// so the bitmap font stays crisp.
+func scale(src *image.RGBA, factor int) image.Image {
+	if factor <= 1 {
+		return src
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx()*factor, bounds.Dy()*factor))
+	for y := 0; y < dst.Bounds().Dy(); y++ {
+		for x := 0; x < dst.Bounds().Dx(); x++ {
+			dst.Set(x, y, src.At(x/factor, y/factor))
+		}
+	}
+
+	return dst
+}
+
+// hexColor parses a #rrggbb color, falling back to black.
+func hexColor(s string) color.RGBA {
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{A: 0xff}
+	}
+
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{A: 0xff}
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
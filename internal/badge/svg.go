@@ -0,0 +1,87 @@
+package badge
+
+import (
+	"bytes"
+	"html/template"
+	"unicode/utf8"
+)
+
+const (
+	svgCharWidth    = 7
+	svgPadding      = 6
+	svgHeight       = 20
+	svgTallHeight   = 28
+	svgFontSize     = 11
+	svgRadius       = 3
+	svgFontFamilies = "Verdana,Geneva,DejaVu Sans,sans-serif"
+)
+
+var svgTemplate = template.Must(template.New("badge").Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.ViewWidth}} {{.ViewHeight}}" role="img" aria-label="{{.Label}}: {{.Value}}">
+<title>{{.Label}}: {{.Value}}</title>
+<clipPath id="r"><rect width="{{.ViewWidth}}" height="{{.ViewHeight}}" rx="{{.Radius}}" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="{{.ViewHeight}}" fill="{{.LabelColor}}"/>
+<rect x="{{.LabelWidth}}" width="{{.ValueWidth}}" height="{{.ViewHeight}}" fill="{{.Color}}"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="{{.FontFamily}}" font-size="{{.FontSize}}">
+<text x="{{.LabelX}}" y="{{.TextY}}">{{.Label}}</text>
+<text x="{{.ValueX}}" y="{{.TextY}}">{{.Value}}</text>
+</g>
+</svg>
+`))
+
+type svgData struct {
+	Badge
+	Width, Height         int
+	ViewWidth, ViewHeight int
+	LabelWidth            int
+	ValueWidth            int
+	LabelX, ValueX        float64
+	TextY                 int
+	Radius                int
+	LabelColor            string
+	FontFamily            string
+	FontSize              int
+}
+
+// SVG renders the badge as an SVG document.
+func (b Badge) SVG() ([]byte, error) {
+	d := svgData{
+		Badge:      b,
+		LabelWidth: textWidth(b.Label, svgCharWidth) + 2*svgPadding,
+		ValueWidth: textWidth(b.Value, svgCharWidth) + 2*svgPadding,
+		ViewHeight: svgHeight,
+		Radius:     svgRadius,
+		LabelColor: ColorLabel,
+		FontFamily: svgFontFamilies,
+		FontSize:   svgFontSize,
+	}
+
+	switch b.Style {
+	case StyleFlatSquare:
+		d.Radius = 0
+	case StyleForTheBadge:
+		d.Radius = 0
+		d.ViewHeight = svgTallHeight
+	}
+
+	d.ViewWidth = d.LabelWidth + d.ValueWidth
+	d.Width = d.ViewWidth * b.Scale
+	d.Height = d.ViewHeight * b.Scale
+	d.LabelX = float64(d.LabelWidth) / 2
+	d.ValueX = float64(d.LabelWidth) + float64(d.ValueWidth)/2
+	// vertically center the text baseline
+	d.TextY = (d.ViewHeight + svgFontSize) / 2
+
+	var buf bytes.Buffer
+	if err := svgTemplate.Execute(&buf, d); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func textWidth(s string, charWidth int) int {
+	return utf8.RuneCountInString(s) * charWidth
+}
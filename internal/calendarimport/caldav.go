@@ -0,0 +1,119 @@
+package calendarimport
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"on-air/internal/ical"
+	"strings"
+	"time"
+)
+
+const (
+	methodPropfind = "PROPFIND"
+	methodReport   = "REPORT"
+
+	caldavTimeLayout = "20060102T150405Z"
+
+	propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:">
+  <D:prop><D:displayname/></D:prop>
+</D:propfind>`
+
+	// the server expands recurring events into their occurrences
+	calendarQueryBody = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <C:calendar-data>
+      <C:expand start="%[1]s" end="%[2]s"/>
+    </C:calendar-data>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%[1]s" end="%[2]s"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+)
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				DisplayName  string `xml:"DAV: displayname"`
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// fetchCalDAV reads the collection name and its events overlapping the range.
+func (im *Importer) fetchCalDAV(ctx context.Context, from, to time.Time) ([]ical.Calendar, error) {
+	var names multistatus
+	if err := im.dav(ctx, methodPropfind, "0", propfindBody, &names); err != nil {
+		return nil, fmt.Errorf("error reading calendar name: %w", err)
+	}
+
+	var name string
+	for _, r := range names.Responses {
+		for _, ps := range r.Propstat {
+			if ps.Prop.DisplayName != "" {
+				name = ps.Prop.DisplayName
+			}
+		}
+	}
+
+	var events multistatus
+	body := fmt.Sprintf(calendarQueryBody, from.UTC().Format(caldavTimeLayout), to.UTC().Format(caldavTimeLayout))
+	if err := im.dav(ctx, methodReport, "1", body, &events); err != nil {
+		return nil, fmt.Errorf("error querying calendar events: %w", err)
+	}
+
+	var cals []ical.Calendar
+	for _, r := range events.Responses {
+		for _, ps := range r.Propstat {
+			if strings.TrimSpace(ps.Prop.CalendarData) == "" {
+				continue
+			}
+
+			cal, err := ical.Parse(strings.NewReader(ps.Prop.CalendarData), im.loc)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s: %w", r.Href, err)
+			}
+			cal.Name = name
+			cals = append(cals, cal)
+		}
+	}
+
+	return cals, nil
+}
+
+func (im *Importer) dav(ctx context.Context, method, depth, body string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, im.cfg.URL, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", depth)
+
+	resp, err := im.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return fmt.Errorf("%w: expected status %d, got %d", ErrInvalidResponse, http.StatusMultiStatus, resp.StatusCode)
+	}
+
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidResponse, err)
+	}
+	return nil
+}
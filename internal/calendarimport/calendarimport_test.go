@@ -0,0 +1,417 @@
+package calendarimport_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"on-air/internal/calendarimport"
+	"on-air/internal/clock"
+	"on-air/internal/entities"
+	"on-air/internal/ical"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"gotest.tools/v3/assert"
+)
+
+var start = time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+
+// advanceTo moves the clock of the on air service to now, the claims expire
+// on it.
+func advanceTo(clk *clock.Fake, now time.Time) {
+	clk.Advance(now.Sub(clk.Now()))
+}
+
+const events = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//test//EN\r\n" +
+	"X-WR-CALNAME:Studio A\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:recording@test\r\n" +
+	"DTSTART:20240102T150000Z\r\n" +
+	"DTEND:20240102T160000Z\r\n" +
+	"SUMMARY:Podcast recording\r\n" +
+	"CATEGORIES:podcast\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:lunch@test\r\n" +
+	"DTSTART:20240102T170000Z\r\n" +
+	"DTEND:20240102T180000Z\r\n" +
+	"SUMMARY:Lunch\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@test\r\n" +
+	"DTSTART:20240102T190000Z\r\n" +
+	"DTEND:20240102T200000Z\r\n" +
+	"SUMMARY:Cancelled recording\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+// caldavServer is a minimal CalDAV collection serving the events, one
+// calendar object per event like real servers do.
+func caldavServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+
+		switch {
+		case r.Method == "PROPFIND" && r.Header.Get("Depth") == "0":
+			w.WriteHeader(http.StatusMultiStatus)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:">
+  <d:response>
+    <d:href>/calendars/alice/studio/</d:href>
+    <d:propstat>
+      <d:prop><d:displayname>Studio B</d:displayname></d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+</d:multistatus>`)
+		case r.Method == "REPORT" && strings.Contains(string(body), "calendar-query"):
+			w.WriteHeader(http.StatusMultiStatus)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">`)
+			for _, obj := range splitEvents(events) {
+				fmt.Fprintf(w, `
+  <d:response>
+    <d:href>/calendars/alice/studio/event.ics</d:href>
+    <d:propstat>
+      <d:prop><cal:calendar-data>%s</cal:calendar-data></d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>`, obj)
+			}
+			fmt.Fprint(w, `
+</d:multistatus>`)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+// splitEvents wraps every event of the feed in its own calendar.
+func splitEvents(feed string) []string {
+	var objs []string
+	parts := strings.Split(feed, "BEGIN:VEVENT\r\n")
+	for _, p := range parts[1:] {
+		p = strings.TrimSuffix(p, "END:VCALENDAR\r\n")
+		objs = append(objs, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\n"+p+"END:VCALENDAR\r\n")
+	}
+	return objs
+}
+
+func TestImporter(t *testing.T) {
+	caldav := caldavServer(t)
+	defer caldav.Close()
+
+	ics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ical.ContentType)
+		fmt.Fprint(w, events)
+	}))
+	defer ics.Close()
+
+	testData := []struct {
+		name     string
+		cfg      calendarimport.Config
+		expected []bool
+	}{
+		{
+			"caldav with a title rule",
+			calendarimport.Config{URL: caldav.URL, Source: calendarimport.SourceCalDAV, Rules: []string{"title=RECORDING"}},
+			[]bool{true, false, false, false},
+		},
+		{
+			"caldav with a calendar rule",
+			calendarimport.Config{URL: caldav.URL, Source: calendarimport.SourceCalDAV, Rules: []string{"calendar=studio b"}},
+			[]bool{true, false, true, false},
+		},
+		{
+			"ics with calendar and category rules",
+			calendarimport.Config{URL: ics.URL, Source: calendarimport.SourceICS, Rules: []string{"calendar=Studio A;category=podcast"}},
+			[]bool{true, false, false, false},
+		},
+		{
+			"ics with a rule matching another calendar",
+			calendarimport.Config{URL: ics.URL, Source: calendarimport.SourceICS, Rules: []string{"calendar=Studio B"}},
+			[]bool{false, false, false, false},
+		},
+		{
+			"ics without rules",
+			calendarimport.Config{URL: ics.URL, Source: calendarimport.SourceICS},
+			[]bool{true, false, true, false},
+		},
+	}
+
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+	// during the recording, between the events, during lunch, after lunch
+	checks := []time.Time{
+		start.Add(30 * time.Minute),
+		start.Add(90 * time.Minute),
+		start.Add(150 * time.Minute),
+		start.Add(210 * time.Minute),
+	}
+
+	for _, tc := range testData {
+		tc.cfg.Username = "alice"
+		tc.cfg.Password = "secret"
+		tc.cfg.Interval = time.Minute
+		tc.cfg.Window = 24 * time.Hour
+		tc.cfg.Timezone = "UTC"
+
+		im, err := calendarimport.New(&tc.cfg)
+		assert.NilError(t, err, tc.name)
+
+		clk := clock.NewFake(start)
+		onAirService, err := onair.New(onair.WithClock(clk))
+		assert.NilError(t, err, tc.name)
+
+		assert.NilError(t, im.Sync(ctx, wl, start), tc.name)
+
+		for i, now := range checks {
+			advanceTo(clk, now)
+			assert.NilError(t, im.Apply(ctx, wl, onAirService, now), tc.name)
+
+			status, err := onAirService.GetOnAirStatus(ctx, wl)
+			assert.NilError(t, err, tc.name)
+			assert.Equal(t, status.IsOnAir, tc.expected[i], "%s: check %d", tc.name, i)
+		}
+	}
+}
+
+func TestImporterRecurringEvents(t *testing.T) {
+	ics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "BEGIN:VCALENDAR\r\n"+
+			"BEGIN:VEVENT\r\n"+
+			"UID:weekly@test\r\n"+
+			"DTSTART:20231226T150000Z\r\n"+
+			"DTEND:20231226T160000Z\r\n"+
+			"SUMMARY:Weekly show\r\n"+
+			"RRULE:FREQ=WEEKLY\r\n"+
+			"END:VEVENT\r\n"+
+			"END:VCALENDAR\r\n")
+	}))
+	defer ics.Close()
+
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	im, err := calendarimport.New(&calendarimport.Config{
+		URL:      ics.URL,
+		Source:   calendarimport.SourceICS,
+		Interval: time.Minute,
+		Window:   14 * 24 * time.Hour,
+		Timezone: "UTC",
+	})
+	assert.NilError(t, err)
+
+	clk := clock.NewFake(start)
+	onAirService, err := onair.New(onair.WithClock(clk))
+	assert.NilError(t, err)
+
+	// the first occurrence has ended by the time of the sync
+	assert.NilError(t, im.Sync(ctx, wl, start.Add(-time.Hour)))
+
+	for i, check := range []struct {
+		now      time.Time
+		expected bool
+	}{
+		{start.Add(30 * time.Minute), true},
+		{start.Add(90 * time.Minute), false},
+		{start.AddDate(0, 0, 7).Add(30 * time.Minute), true},
+	} {
+		advanceTo(clk, check.now)
+		assert.NilError(t, im.Apply(ctx, wl, onAirService, check.now))
+
+		status, err := onAirService.GetOnAirStatus(ctx, wl)
+		assert.NilError(t, err)
+		assert.Equal(t, status.IsOnAir, check.expected, "check %d", i)
+	}
+}
+
+func TestApplyWhileLocked(t *testing.T) {
+	ics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, events)
+	}))
+	defer ics.Close()
+
+	ctx := context.Background()
+	var logs strings.Builder
+	wl, err := wlog.NewBasicLoggerWithOutput(&wlog.Config{MinLogLevel: "info"}, &logs)
+	assert.NilError(t, err)
+
+	im, err := calendarimport.New(&calendarimport.Config{
+		URL:      ics.URL,
+		Source:   calendarimport.SourceICS,
+		Interval: time.Minute,
+		Window:   time.Hour,
+		Timezone: "UTC",
+		Rules:    []string{"title=recording"},
+	})
+	assert.NilError(t, err)
+
+	clk := clock.NewFake(start)
+	onAirService, err := onair.New(onair.WithClock(clk))
+	assert.NilError(t, err)
+	_, err = onAirService.Lock(ctx, wl, "maintenance", null.Time{})
+	assert.NilError(t, err)
+
+	assert.NilError(t, im.Sync(ctx, wl, start))
+	for i := 0; i < 3; i++ {
+		advanceTo(clk, start.Add(time.Duration(i)*time.Minute))
+		assert.NilError(t, im.Apply(ctx, wl, onAirService, clk.Now()))
+	}
+
+	status, err := onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, status.IsOnAir, false)
+	// the refused change is only logged once
+	assert.Equal(t, strings.Count(logs.String(), "calendar change not applied"), 1)
+
+	// and applied once unlocked
+	_, err = onAirService.Unlock(ctx, wl)
+	assert.NilError(t, err)
+	advanceTo(clk, start.Add(3*time.Minute))
+	assert.NilError(t, im.Apply(ctx, wl, onAirService, clk.Now()))
+
+	status, err = onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, status.IsOnAir, true)
+	assert.Equal(t, status.Message, "Podcast recording")
+}
+
+func TestManualChangeLastsUntilTheEventEnds(t *testing.T) {
+	ics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, events)
+	}))
+	defer ics.Close()
+
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	im, err := calendarimport.New(&calendarimport.Config{
+		URL:      ics.URL,
+		Source:   calendarimport.SourceICS,
+		Interval: time.Minute,
+		Window:   time.Hour,
+		Timezone: "UTC",
+		Rules:    []string{"title=recording"},
+	})
+	assert.NilError(t, err)
+
+	clk := clock.NewFake(start)
+	onAirService, err := onair.New(onair.WithClock(clk))
+	assert.NilError(t, err)
+
+	assert.NilError(t, im.Sync(ctx, wl, start))
+	assert.NilError(t, im.Apply(ctx, wl, onAirService, start))
+
+	status, err := onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, status.IsOnAir, true)
+	assert.Equal(t, status.Message, "Podcast recording")
+	assert.Equal(t, status.Source, entities.SourceCalendar)
+
+	// going off air manually during the event overrides the calendar claim
+	_, err = onAirService.ToggleOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	advanceTo(clk, start.Add(time.Minute))
+	assert.NilError(t, im.Apply(ctx, wl, onAirService, clk.Now()))
+
+	status, err = onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, status.IsOnAir, false)
+	assert.Equal(t, status.Source, entities.SourceManual)
+
+	// until the event ends and the calendar releases its claim
+	advanceTo(clk, start.Add(time.Hour))
+	assert.NilError(t, im.Apply(ctx, wl, onAirService, clk.Now()))
+
+	status, err = onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, status.IsOnAir, false)
+	assert.Equal(t, len(status.Claims), 0)
+}
+
+func TestClaimExpiresWithTheEvent(t *testing.T) {
+	ics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, events)
+	}))
+	defer ics.Close()
+
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	im, err := calendarimport.New(&calendarimport.Config{
+		URL:      ics.URL,
+		Source:   calendarimport.SourceICS,
+		Interval: time.Minute,
+		Window:   time.Hour,
+		Timezone: "UTC",
+		Rules:    []string{"title=recording"},
+	})
+	assert.NilError(t, err)
+
+	clk := clock.NewFake(start)
+	onAirService, err := onair.New(onair.WithClock(clk))
+	assert.NilError(t, err)
+
+	assert.NilError(t, im.Sync(ctx, wl, start))
+	assert.NilError(t, im.Apply(ctx, wl, onAirService, start))
+
+	status, err := onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, status.IsOnAir, true)
+
+	// the status goes off air when the event ends without another apply
+	advanceTo(clk, start.Add(time.Hour))
+
+	status, err = onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, status.IsOnAir, false)
+	assert.Equal(t, len(status.Claims), 0)
+}
+
+func TestParseRule(t *testing.T) {
+	testData := []struct {
+		name     string
+		rule     string
+		expected calendarimport.Rule
+		err      error
+	}{
+		{"single field", "title=recording", calendarimport.Rule{Title: "recording"}, nil},
+		{
+			"all fields",
+			"calendar=Studio A; title = recording ;CATEGORY=podcast",
+			calendarimport.Rule{Calendar: "Studio A", Title: "recording", Category: "podcast"},
+			nil,
+		},
+		{"unknown field", "location=studio", calendarimport.Rule{}, calendarimport.ErrInvalidRule},
+		{"missing value", "title=", calendarimport.Rule{}, calendarimport.ErrInvalidRule},
+		{"missing separator", "recording", calendarimport.Rule{}, calendarimport.ErrInvalidRule},
+	}
+
+	for _, tc := range testData {
+		got, err := calendarimport.ParseRule(tc.rule)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err, tc.name)
+			continue
+		}
+		assert.NilError(t, err, tc.name)
+		assert.Equal(t, got, tc.expected, tc.name)
+	}
+}
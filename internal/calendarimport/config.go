@@ -0,0 +1,64 @@
+package calendarimport
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Calendar sources.
+const (
+	SourceICS    = "ics"
+	SourceCalDAV = "caldav"
+)
+
+// Config holds the configuration options for the calendar import.
+type Config struct {
+	// An ICS feed URL or a CalDAV collection URL.
+	// The import is disabled when empty.
+	URL string `env:"CALENDAR_IMPORT_URL"`
+	// ics or caldav
+	Source string `env:"CALENDAR_IMPORT_SOURCE" envDefault:"ics"`
+	// Basic auth credentials sent with every request
+	Username string `env:"CALENDAR_IMPORT_USERNAME"`
+	Password string `env:"CALENDAR_IMPORT_PASSWORD"`
+	// How often the calendar is fetched
+	Interval time.Duration `env:"CALENDAR_IMPORT_INTERVAL" envDefault:"5m"`
+	// How far ahead recurring events are expanded and CalDAV events fetched
+	Window time.Duration `env:"CALENDAR_IMPORT_WINDOW" envDefault:"168h"`
+	// The timezone of floating times and all-day events
+	Timezone string `env:"CALENDAR_IMPORT_TZ" envDefault:"UTC"`
+	// The rules events have to match, see ParseRule. All events match when empty.
+	Rules []string `env:"CALENDAR_IMPORT_RULES" envSeparator:","`
+}
+
+// Enabled reports whether a calendar has been configured.
+func (c *Config) Enabled() bool {
+	return c.URL != ""
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.URL, validation.Required),
+		validation.Field(&c.Source, validation.Required, validation.In(SourceICS, SourceCalDAV)),
+		validation.Field(&c.Interval, validation.Min(time.Minute)),
+		validation.Field(&c.Window, validation.Min(time.Hour)),
+		validation.Field(&c.Timezone, validation.By(validateTimezone)),
+		validation.Field(&c.Rules, validation.Each(validation.By(validateRule))),
+	)
+}
+
+func validateTimezone(value interface{}) error {
+	s, _ := value.(string)
+	_, err := time.LoadLocation(s)
+	return err
+}
+
+func validateRule(value interface{}) error {
+	s, _ := value.(string)
+	_, err := ParseRule(s)
+	return err
+}
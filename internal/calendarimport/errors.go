@@ -0,0 +1,8 @@
+package calendarimport
+
+import "errors"
+
+var (
+	ErrInvalidRule     = errors.New("invalid rule")
+	ErrInvalidResponse = errors.New("invalid caldav response")
+)
@@ -0,0 +1,253 @@
+// Package calendarimport sets the on air status from the events of a shared
+// calendar, read from an ICS feed or a CalDAV collection.
+package calendarimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"on-air/internal/entities"
+	"on-air/internal/ical"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"on-air/pkg/client"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/guregu/null"
+)
+
+const (
+	// how often the imported events are checked against the clock
+	applyTick  = 5 * time.Second
+	maxRetries = 3
+	// CalDAV events that started this long ago are still fetched
+	lookBehind = 24 * time.Hour
+)
+
+// Importer periodically imports the events matching the rules and keeps the
+// status on air while one of them is happening.
+type Importer struct {
+	cfg    *Config
+	rules  []Rule
+	loc    *time.Location
+	client *client.BackoffHTTPClient
+
+	mu     sync.Mutex
+	events []ical.Event
+
+	// active identifies the event the status has been set on air for, and
+	// locked the change the lock refused last. Both are only used by Apply.
+	active string
+	locked string
+}
+
+// New creates an Importer from the given configuration.
+// Call Start to begin importing.
+func New(cfg *Config, opts ...client.Option) (*Importer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	loc, _ := time.LoadLocation(cfg.Timezone)
+
+	rules := make([]Rule, 0, len(cfg.Rules))
+	for _, s := range cfg.Rules {
+		r, _ := ParseRule(s)
+		rules = append(rules, r)
+	}
+
+	policy := backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxRetries)
+
+	return &Importer{
+		cfg:    cfg,
+		rules:  rules,
+		loc:    loc,
+		client: client.NewBackoffHTTPClient(policy, opts...),
+	}, nil
+}
+
+// Start imports the calendar every interval and applies the imported events
+// until the context is done.
+func (im *Importer) Start(ctx context.Context, wl wlog.Logger, onAirService onair.SVC) {
+	go func() {
+		syncTicker := time.NewTicker(im.cfg.Interval)
+		defer syncTicker.Stop()
+		applyTicker := time.NewTicker(applyTick)
+		defer applyTicker.Stop()
+
+		im.syncAndLog(ctx, wl)
+		for {
+			if err := im.Apply(ctx, wl, onAirService, time.Now()); err != nil {
+				wl.Error(fmt.Errorf("error applying calendar events: %w", err))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-syncTicker.C:
+				im.syncAndLog(ctx, wl)
+			case <-applyTicker.C:
+			}
+		}
+	}()
+
+	wl.Infof("importing calendar events from %s every %s", im.cfg.URL, im.cfg.Interval)
+}
+
+func (im *Importer) syncAndLog(ctx context.Context, wl wlog.Logger) {
+	if err := im.Sync(ctx, wl, time.Now()); err != nil {
+		// the previously imported events are kept until the next sync
+		wl.Error(fmt.Errorf("error importing calendar: %w", err))
+	}
+}
+
+// Sync fetches the calendar and keeps the events matching the rules that
+// haven't ended by now, recurring events are expanded up to the window.
+func (im *Importer) Sync(ctx context.Context, wl wlog.Logger, now time.Time) error {
+	var cals []ical.Calendar
+	var err error
+	switch im.cfg.Source {
+	case SourceCalDAV:
+		cals, err = im.fetchCalDAV(ctx, now.Add(-lookBehind), now.Add(im.cfg.Window))
+	default:
+		var cal ical.Calendar
+		cal, err = im.fetchICS(ctx)
+		cals = []ical.Calendar{cal}
+	}
+	if err != nil {
+		return err
+	}
+
+	var events []ical.Event
+	for _, cal := range cals {
+		cal, err := cal.Expand(now, now.Add(im.cfg.Window))
+		if err != nil {
+			// only the first occurrence of these events is imported
+			wl.WithErr(err).Warn("error expanding recurring calendar events")
+		}
+
+		for _, ev := range cal.Events {
+			if ev.Status == "CANCELLED" || !ev.End.After(now) || !im.matches(cal.Name, ev) {
+				continue
+			}
+			events = append(events, ev)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+
+	im.mu.Lock()
+	im.events = events
+	im.mu.Unlock()
+
+	wl.Debugf("imported %d calendar events", len(events))
+
+	return nil
+}
+
+// Apply claims the status on air when an imported event is happening and
+// releases the claim once none are. The claim expires with the event, so the
+// status goes off air even if the importer stalls. The claim only changes
+// when an event starts or ends, a change refused by a lock is retried quietly
+// until the status is unlocked. Apply isn't safe for concurrent use.
+func (im *Importer) Apply(ctx context.Context, wl wlog.Logger, onAirService onair.SVC, now time.Time) error {
+	current, ok := im.current(now)
+
+	key := ""
+	if ok {
+		key = eventKey(current)
+	}
+	if key == im.active {
+		return nil
+	}
+
+	ctx = onair.WithSource(ctx, entities.SourceCalendar)
+	var err error
+	if ok {
+		_, err = onAirService.Claim(ctx, wl, entities.Claim{
+			Source:    entities.SourceCalendar,
+			IsOnAir:   true,
+			Message:   current.Summary,
+			ExpiresAt: null.TimeFrom(current.End),
+		})
+	} else {
+		_, err = onAirService.Release(ctx, wl, entities.SourceCalendar)
+	}
+
+	switch {
+	case errors.Is(err, onair.ErrLocked):
+		if im.locked != key {
+			wl.Infof("calendar change not applied, %s", err)
+			im.locked = key
+		}
+		return nil
+	case err != nil:
+		return err
+	case ok:
+		wl.Infof("calendar event %q started", current.Summary)
+	default:
+		wl.Info("calendar event ended")
+	}
+	im.active = key
+	im.locked = ""
+
+	return nil
+}
+
+// current returns the imported event happening at now, if any.
+func (im *Importer) current(now time.Time) (ical.Event, bool) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	for _, ev := range im.events {
+		if !now.Before(ev.Start) && now.Before(ev.End) {
+			return ev, true
+		}
+	}
+	return ical.Event{}, false
+}
+
+func (im *Importer) matches(calendar string, ev ical.Event) bool {
+	if len(im.rules) == 0 {
+		return true
+	}
+	for _, r := range im.rules {
+		if r.Matches(calendar, ev) {
+			return true
+		}
+	}
+	return false
+}
+
+func (im *Importer) fetchICS(ctx context.Context) (ical.Calendar, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, im.cfg.URL, nil)
+	if err != nil {
+		return ical.Calendar{}, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	resp, err := im.do(req)
+	if err != nil {
+		return ical.Calendar{}, err
+	}
+	defer resp.Body.Close()
+
+	return ical.Parse(resp.Body, im.loc)
+}
+
+func (im *Importer) do(req *http.Request) (*http.Response, error) {
+	if im.cfg.Username != "" {
+		req.SetBasicAuth(im.cfg.Username, im.cfg.Password)
+	}
+	return im.client.Do(req)
+}
+
+// eventKey identifies an occurrence, recurring events share their UID.
+func eventKey(ev ical.Event) string {
+	return ev.UID + "/" + ev.Start.UTC().Format(time.RFC3339)
+}
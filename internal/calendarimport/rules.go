@@ -0,0 +1,73 @@
+package calendarimport
+
+import (
+	"fmt"
+	"on-air/internal/ical"
+	"strings"
+)
+
+// Rule fields.
+const (
+	fieldCalendar = "calendar"
+	fieldTitle    = "title"
+	fieldCategory = "category"
+)
+
+// Rule selects the events that set the status on air. Empty fields match
+// everything and all the others have to match, ignoring case.
+type Rule struct {
+	// Calendar is the exact name of the calendar
+	Calendar string
+	// Title is a keyword the event summary has to contain
+	Title string
+	// Category is one of the event categories
+	Category string
+}
+
+// ParseRule reads a rule written as semicolon separated field=value pairs,
+// e.g. calendar=Studio A;title=recording;category=podcast.
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+	for _, cond := range strings.Split(s, ";") {
+		field, value, ok := strings.Cut(cond, "=")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("%w: %q must be formatted as field=value", ErrInvalidRule, cond)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case fieldCalendar:
+			r.Calendar = value
+		case fieldTitle:
+			r.Title = value
+		case fieldCategory:
+			r.Category = value
+		default:
+			return Rule{}, fmt.Errorf("%w: unknown field %q, expected calendar, title or category", ErrInvalidRule, field)
+		}
+	}
+	return r, nil
+}
+
+// Matches reports whether an event of the named calendar matches the rule.
+func (r Rule) Matches(calendar string, ev ical.Event) bool {
+	if r.Calendar != "" && !strings.EqualFold(r.Calendar, calendar) {
+		return false
+	}
+	if r.Title != "" && !strings.Contains(strings.ToLower(ev.Summary), strings.ToLower(r.Title)) {
+		return false
+	}
+	if r.Category != "" && !hasCategory(ev.Categories, r.Category) {
+		return false
+	}
+	return true
+}
+
+func hasCategory(categories []string, category string) bool {
+	for _, c := range categories {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
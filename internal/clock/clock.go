@@ -0,0 +1,30 @@
+// Package clock abstracts the passing of time so the services relying on it
+// can be tested deterministically, see Fake.
+package clock
+
+import "time"
+
+// Clock tells the time and schedules functions.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once d has elapsed.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a function scheduled by Clock.AfterFunc.
+type Timer interface {
+	// Stop prevents the function from being called. It returns false when
+	// the function was already called or stopped.
+	Stop() bool
+}
+
+// Real is the system clock.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
@@ -0,0 +1,119 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when advanced, for tests. The scheduled
+// functions are called by Advance, in the order they're due, from the
+// goroutine advancing the clock.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// seq orders the functions due at the same time by scheduling order
+	seq uint64
+}
+
+type fakeTimer struct {
+	clock *Fake
+	at    time.Time
+	seq   uint64
+	f     func()
+}
+
+// NewFake returns a fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// AfterFunc schedules f to be called once the clock is advanced by d. A
+// function scheduled with a negative or zero d is called by the next
+// Advance, even Advance(0), never right away.
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	t := &fakeTimer{clock: c, at: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+
+	return t
+}
+
+// Advance moves the clock forward by d, calling every function due in the
+// meantime with the clock set to when it was due. The functions may
+// schedule others, which are called too when due before the end of d.
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		t := c.next(end)
+		if t == nil {
+			break
+		}
+		if t.at.After(c.now) {
+			c.now = t.at
+		}
+
+		// the function may use the clock
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	if end.After(c.now) {
+		c.now = end
+	}
+	c.mu.Unlock()
+}
+
+// Pending returns the number of scheduled functions not called yet.
+func (c *Fake) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// next removes and returns the first function due by end, nil if none is.
+// It must be called with the lock held.
+func (c *Fake) next(end time.Time) *fakeTimer {
+	i := -1
+	for j, t := range c.timers {
+		if t.at.After(end) {
+			continue
+		}
+		if i < 0 || t.at.Before(c.timers[i].at) || (t.at.Equal(c.timers[i].at) && t.seq < c.timers[i].seq) {
+			i = j
+		}
+	}
+	if i < 0 {
+		return nil
+	}
+
+	t := c.timers[i]
+	c.timers = append(c.timers[:i], c.timers[i+1:]...)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
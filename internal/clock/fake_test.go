@@ -0,0 +1,46 @@
+package clock_test
+
+import (
+	"on-air/internal/clock"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFake(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(t0)
+
+	var calls []string
+	record := func(name string) func() {
+		return func() { calls = append(calls, name+" at "+clk.Now().Sub(t0).String()) }
+	}
+
+	clk.AfterFunc(2*time.Minute, record("second"))
+	clk.AfterFunc(time.Minute, record("first"))
+	stopped := clk.AfterFunc(90*time.Second, record("stopped"))
+	clk.AfterFunc(time.Minute, func() {
+		record("rescheduled")()
+		clk.AfterFunc(30*time.Second, record("nested"))
+	})
+	assert.Equal(t, clk.Pending(), 4)
+
+	assert.Assert(t, stopped.Stop())
+	assert.Assert(t, !stopped.Stop())
+
+	clk.Advance(time.Minute - time.Second)
+	assert.Equal(t, len(calls), 0)
+	assert.Equal(t, clk.Now(), t0.Add(time.Minute-time.Second))
+
+	clk.Advance(time.Hour)
+	assert.DeepEqual(t, calls, []string{"first at 1m0s", "rescheduled at 1m0s", "nested at 1m30s", "second at 2m0s"})
+	assert.Equal(t, clk.Now(), t0.Add(time.Hour+time.Minute-time.Second))
+	assert.Equal(t, clk.Pending(), 0)
+
+	// due functions wait for the clock to be advanced
+	clk.AfterFunc(0, record("now"))
+	assert.Equal(t, len(calls), 4)
+	clk.Advance(0)
+	assert.Equal(t, len(calls), 5)
+}
@@ -1,9 +1,200 @@
 package entities
 
-import "github.com/guregu/null"
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
 
 type OnAirStatus struct {
 	IsOnAir     bool
+	Message     string
 	LastUpdated null.Time
 	LastOnAir   null.Time
+	// Revision is incremented on every change of the status
+	Revision uint64
+	// Lock is set while the status is locked
+	Lock *Lock
+	// Source is the source of the claim the status comes from, empty when
+	// nothing claims it
+	Source Source
+	// Claims are the active claims, by decreasing priority
+	Claims []Claim
+}
+
+// Source is what drives a status change.
+type Source string
+
+const (
+	SourceManual        Source = "manual"
+	SourcePubSub        Source = "pubsub"
+	SourceHomeAssistant Source = "homeassistant"
+	SourceSchedule      Source = "schedule"
+	SourceCalendar      Source = "calendar"
+)
+
+// Sources lists every source, by decreasing default priority.
+var Sources = []Source{SourceManual, SourcePubSub, SourceHomeAssistant, SourceSchedule, SourceCalendar}
+
+// Claim is the status wanted by a source. The status is the one of the
+// active claim of the source with the highest priority.
+type Claim struct {
+	Source    Source
+	IsOnAir   bool
+	Message   string
+	ClaimedAt time.Time
+	// ExpiresAt is null when the claim lasts until it's released
+	ExpiresAt null.Time
+}
+
+// Active reports whether the claim holds at the given time.
+func (c Claim) Active(now time.Time) bool {
+	return !c.ExpiresAt.Valid || now.Before(c.ExpiresAt.Time)
+}
+
+// Lock keeps anyone but its holder and the admins from changing the status.
+type Lock struct {
+	// Holder is the ID of the user who locked the status, empty when
+	// authentication is disabled
+	Holder   string
+	Reason   string
+	LockedAt time.Time
+	// ExpiresAt is null when the lock lasts until it's released
+	ExpiresAt null.Time
+}
+
+// Active reports whether the lock is held at the given time.
+func (l *Lock) Active(now time.Time) bool {
+	return l != nil && (!l.ExpiresAt.Valid || now.Before(l.ExpiresAt.Time))
+}
+
+// Transition is a recorded change of the on air status.
+type Transition struct {
+	Revision uint64
+	IsOnAir  bool
+	Message  string
+	At       time.Time
+	// Lock is the lock held after the change
+	Lock *Lock
+	// Source is the source of the status after the change
+	Source Source
+}
+
+// Session is a period spent on air, from an off to on transition
+// to the following on to off one.
+type Session struct {
+	ID    string
+	Start time.Time
+	// End is null while the session is running
+	End   null.Time
+	Notes string
+	Tags  []string
+}
+
+// Duration returns the length of the session, up to now if it's still running.
+func (s Session) Duration(now time.Time) time.Duration {
+	if s.End.Valid {
+		return s.End.Time.Sub(s.Start)
+	}
+	return now.Sub(s.Start)
+}
+
+// SessionFilter selects sessions. Zero values match everything.
+type SessionFilter struct {
+	Tag string
+	// From and To select the sessions overlapping the range
+	From null.Time
+	To   null.Time
+}
+
+// SessionUpdate holds the editable fields of a session.
+// Nil fields are left untouched.
+type SessionUpdate struct {
+	Notes *string
+	Tags  *[]string
+}
+
+// Role is the set of actions granted to a user.
+type Role string
+
+const (
+	// RoleViewer can read everything.
+	RoleViewer Role = "viewer"
+	// RoleOperator can also set the status of its channels and edit their sessions.
+	RoleOperator Role = "operator"
+	// RoleAdmin can do everything on every channel.
+	RoleAdmin Role = "admin"
+)
+
+// Action is something a user can be allowed to do.
+type Action string
+
+const (
+	ActionRead            Action = "read"
+	ActionSetStatus       Action = "set_status"
+	ActionEditSessions    Action = "edit_sessions"
+	ActionManageSchedules Action = "manage_schedules"
+)
+
+// User is an authenticated caller of the API.
+type User struct {
+	ID   string
+	Role Role
+	// Channels limits the channel actions of the role to these channels,
+	// the role applies to every channel when empty.
+	Channels []string
+}
+
+// Permissions are the effective permissions of a user.
+type Permissions struct {
+	UserID string
+	Role   Role
+	// Actions are allowed on every channel
+	Actions []Action
+	// Channels maps the granted channels to the actions only allowed on them
+	Channels map[string][]Action
+}
+
+// Stats aggregates the on air time over a period.
+type Stats struct {
+	From           time.Time
+	To             time.Time
+	Location       *time.Location
+	TotalOnAir     time.Duration
+	Sessions       int
+	LongestSession time.Duration
+	AverageSession time.Duration
+	Buckets        []StatsBucket
+	// CoveredFrom is set when the sessions before it have been dropped from
+	// memory and are missing from the statistics.
+	CoveredFrom null.Time
+}
+
+// Truncated reports whether sessions of the range are missing.
+func (s Stats) Truncated() bool {
+	return s.CoveredFrom.Valid && s.CoveredFrom.Time.After(s.From)
+}
+
+// StatsBucket is the on air time of a single day, week or month.
+type StatsBucket struct {
+	Start    time.Time
+	End      time.Time
+	OnAir    time.Duration
+	Sessions int
+}
+
+// Schedule is a window during which the status is set on air.
+type Schedule struct {
+	ID        string
+	Start     time.Time
+	End       time.Time
+	Message   string
+	CreatedAt time.Time
+}
+
+// ScheduleFilter selects the schedules overlapping a range.
+// Zero values match everything.
+type ScheduleFilter struct {
+	From null.Time
+	To   null.Time
 }
@@ -0,0 +1,268 @@
+// Package export streams sessions and transitions as CSV, JSON or NDJSON.
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"on-air/internal/entities"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is an export file format.
+type Format string
+
+// Supported formats.
+const (
+	FormatCSV    Format = "csv"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+)
+
+// ContentType returns the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/json"
+	}
+}
+
+// Column is a named field extracted from an exported item.
+type Column[T any] struct {
+	Name  string
+	Value func(item T, loc *time.Location) interface{}
+}
+
+// SessionColumns are the columns available when exporting sessions.
+var SessionColumns = []Column[entities.Session]{
+	{"id", func(s entities.Session, _ *time.Location) interface{} { return s.ID }},
+	{"start", func(s entities.Session, loc *time.Location) interface{} { return timestamp(s.Start, loc) }},
+	{"end", func(s entities.Session, loc *time.Location) interface{} {
+		if !s.End.Valid {
+			return nil
+		}
+		return timestamp(s.End.Time, loc)
+	}},
+	{"duration_seconds", func(s entities.Session, _ *time.Location) interface{} {
+		if !s.End.Valid {
+			return nil
+		}
+		return int64(s.Duration(s.End.Time).Seconds())
+	}},
+	{"notes", func(s entities.Session, _ *time.Location) interface{} { return s.Notes }},
+	{"tags", func(s entities.Session, _ *time.Location) interface{} { return s.Tags }},
+}
+
+// TransitionColumns are the columns available when exporting transitions.
+var TransitionColumns = []Column[entities.Transition]{
+	{"revision", func(t entities.Transition, _ *time.Location) interface{} { return t.Revision }},
+	{"at", func(t entities.Transition, loc *time.Location) interface{} { return timestamp(t.At, loc) }},
+	{"is_on_air", func(t entities.Transition, _ *time.Location) interface{} { return t.IsOnAir }},
+	{"message", func(t entities.Transition, _ *time.Location) interface{} { return t.Message }},
+}
+
+// SelectColumns returns the named columns in the given order,
+// or every column when names is empty.
+func SelectColumns[T any](all []Column[T], names []string) ([]Column[T], error) {
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	selected := make([]Column[T], 0, len(names))
+	for _, n := range names {
+		found := false
+		for _, c := range all {
+			if c.Name == n {
+				selected = append(selected, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, n)
+		}
+	}
+
+	return selected, nil
+}
+
+// Writer encodes items one at a time so exports can be streamed.
+type Writer[T any] struct {
+	enc     encoder
+	columns []Column[T]
+	loc     *time.Location
+	values  []interface{}
+}
+
+// NewWriter returns a Writer of the columns in the format, with timestamps in loc.
+func NewWriter[T any](w io.Writer, f Format, columns []Column[T], loc *time.Location) (*Writer[T], error) {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+
+	var enc encoder
+	switch f {
+	case FormatCSV:
+		enc = &csvEncoder{w: csv.NewWriter(w), names: names}
+	case FormatJSON:
+		enc = &jsonEncoder{w: w, names: names, array: true}
+	case FormatNDJSON:
+		enc = &jsonEncoder{w: w, names: names}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, f)
+	}
+
+	return &Writer[T]{
+		enc:     enc,
+		columns: columns,
+		loc:     loc,
+		values:  make([]interface{}, len(columns)),
+	}, nil
+}
+
+// Begin writes the header of the export, if any.
+func (w *Writer[T]) Begin() error {
+	return w.enc.begin()
+}
+
+// Write encodes a single item.
+func (w *Writer[T]) Write(item T) error {
+	for i, c := range w.columns {
+		w.values[i] = c.Value(item, w.loc)
+	}
+	return w.enc.row(w.values)
+}
+
+// End writes the footer of the export, if any, and flushes it.
+func (w *Writer[T]) End() error {
+	return w.enc.end()
+}
+
+type encoder interface {
+	begin() error
+	row(values []interface{}) error
+	end() error
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	names  []string
+	record []string
+}
+
+func (e *csvEncoder) begin() error {
+	e.record = make([]string, len(e.names))
+	return e.w.Write(e.names)
+}
+
+func (e *csvEncoder) row(values []interface{}) error {
+	for i, v := range values {
+		e.record[i] = csvValue(v)
+	}
+	if err := e.w.Write(e.record); err != nil {
+		return err
+	}
+	// flush every row so the rows are streamed instead of buffered
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonEncoder struct {
+	w     io.Writer
+	names []string
+	array bool
+	count int
+	buf   bytes.Buffer
+}
+
+func (e *jsonEncoder) begin() error {
+	if !e.array {
+		return nil
+	}
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonEncoder) row(values []interface{}) error {
+	e.buf.Reset()
+	if e.array && e.count > 0 {
+		e.buf.WriteByte(',')
+	}
+
+	// objects are built by hand to keep the keys in column order
+	e.buf.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(e.names[i])
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		e.buf.Write(k)
+		e.buf.WriteByte(':')
+		e.buf.Write(b)
+	}
+	e.buf.WriteByte('}')
+	if !e.array {
+		e.buf.WriteByte('\n')
+	}
+
+	e.count++
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
+
+func (e *jsonEncoder) end() error {
+	if !e.array {
+		return nil
+	}
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return escapeFormula(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case []string:
+		return escapeFormula(strings.Join(v, ";"))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formulaPrefixes start a formula in spreadsheet apps.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes the text cells that would be read as a formula with
+// a quote, so a note like =HYPERLINK(...) is displayed as is.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func timestamp(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.RFC3339)
+}
@@ -0,0 +1,79 @@
+package export_test
+
+import (
+	"bytes"
+	"errors"
+	"on-air/internal/entities"
+	"on-air/internal/export"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"gotest.tools/v3/assert"
+)
+
+func TestWriter(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	sessions := []entities.Session{
+		{ID: "a", Start: start, End: null.TimeFrom(start.Add(time.Hour)), Notes: "ep 1, take \"2\"", Tags: []string{"podcast", "live"}},
+		{ID: "b", Start: start.Add(2 * time.Hour), Notes: "=1+1", Tags: []string{}},
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.NilError(t, err)
+
+	testData := []struct {
+		name     string
+		format   export.Format
+		columns  []string
+		expected string
+	}{
+		{
+			"csv",
+			export.FormatCSV,
+			[]string{"id", "start", "duration_seconds", "notes", "tags"},
+			"id,start,duration_seconds,notes,tags\n" +
+				"a,2024-01-03T00:00:00+09:00,3600,\"ep 1, take \"\"2\"\"\",podcast;live\n" +
+				"b,2024-01-03T02:00:00+09:00,,'=1+1,\n",
+		},
+		{
+			"json",
+			export.FormatJSON,
+			[]string{"tags", "end", "id"},
+			`[{"tags":["podcast","live"],"end":"2024-01-03T01:00:00+09:00","id":"a"},` +
+				`{"tags":[],"end":null,"id":"b"}]` + "\n",
+		},
+		{
+			"ndjson",
+			export.FormatNDJSON,
+			[]string{"id"},
+			"{\"id\":\"a\"}\n{\"id\":\"b\"}\n",
+		},
+	}
+
+	for _, tc := range testData {
+		columns, err := export.SelectColumns(export.SessionColumns, tc.columns)
+		assert.NilError(t, err, tc.name)
+
+		var buf bytes.Buffer
+		w, err := export.NewWriter(&buf, tc.format, columns, tokyo)
+		assert.NilError(t, err, tc.name)
+
+		assert.NilError(t, w.Begin(), tc.name)
+		for _, s := range sessions {
+			assert.NilError(t, w.Write(s), tc.name)
+		}
+		assert.NilError(t, w.End(), tc.name)
+
+		assert.Equal(t, buf.String(), tc.expected, tc.name)
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	all, err := export.SelectColumns(export.TransitionColumns, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(all), len(export.TransitionColumns))
+
+	_, err = export.SelectColumns(export.TransitionColumns, []string{"revision", "tags"})
+	assert.Assert(t, errors.Is(err, export.ErrUnknownColumn))
+}
@@ -0,0 +1,259 @@
+// Package homeassistant publishes the on air status to MQTT and announces it
+// to Home Assistant through its MQTT discovery protocol.
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	publishTimeout = 5 * time.Second
+	// Home Assistant publishes its birth message here when it (re)starts
+	haStatusSuffix = "/status"
+	qosAtLeastOnce = 1
+	// commandBacklog is the number of commands waiting to be handled
+	commandBacklog = 16
+)
+
+// ErrPublishTimeout is returned when the broker does not acknowledge a message in time.
+var ErrPublishTimeout = errors.New("timed out waiting for mqtt broker")
+
+// Bridge keeps Home Assistant in sync with the on air service. The messages
+// received and the statuses to publish are handled by a single worker, so
+// neither the MQTT client nor the status changes wait on the broker.
+type Bridge struct {
+	cfg    *Config
+	topics Topics
+	client mqtt.Client
+
+	// commands holds the commands received, in order
+	commands chan string
+	// reannounce asks the worker to announce the entities again
+	reannounce chan struct{}
+	// publish wakes up the worker to publish the latest status
+	publish chan struct{}
+
+	mu sync.Mutex
+	// latest is the most recent status not published yet, if any
+	latest *entities.OnAirStatus
+	// published is the revision of the last status taken by the worker,
+	// older ones are never published over it
+	published uint64
+}
+
+// New creates a Bridge from the given configuration.
+// Call Start to connect it to the broker.
+func New(cfg *Config) (*Bridge, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Bridge{
+		cfg:        cfg,
+		topics:     TopicsFor(cfg.TopicPrefix, cfg.Channel),
+		commands:   make(chan string, commandBacklog),
+		reannounce: make(chan struct{}, 1),
+		publish:    make(chan struct{}, 1),
+	}, nil
+}
+
+// Start connects to the broker, announces the entities, publishes the current
+// status and listens for commands sent from Home Assistant.
+func (b *Bridge) Start(ctx context.Context, wl wlog.Logger, onAirService onair.SVC) error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.cfg.BrokerURL).
+		SetClientID(b.cfg.ClientID).
+		SetUsername(b.cfg.Username).
+		SetPassword(b.cfg.Password).
+		SetAutoReconnect(true).
+		SetWill(b.topics.Availability, PayloadOffline, qosAtLeastOnce, true).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			// (re)subscribe and (re)announce on every connection since
+			// subscriptions are not kept across reconnects
+			if err := b.onConnect(ctx, wl, onAirService); err != nil {
+				wl.Error(fmt.Errorf("error syncing home assistant: %w", err))
+			}
+		})
+
+	b.client = mqtt.NewClient(opts)
+	go b.run(ctx, wl, onAirService)
+
+	if err := wait(b.client.Connect()); err != nil {
+		return fmt.Errorf("error connecting to mqtt broker: %w", err)
+	}
+
+	wl.Infof("home assistant bridge connected to %s", b.cfg.BrokerURL)
+	return nil
+}
+
+// Close marks the entities as unavailable and disconnects from the broker.
+func (b *Bridge) Close() error {
+	if b.client == nil || !b.client.IsConnected() {
+		return nil
+	}
+
+	err := wait(b.client.Publish(b.topics.Availability, qosAtLeastOnce, true, PayloadOffline))
+	b.client.Disconnect(uint(publishTimeout / time.Millisecond))
+	return err
+}
+
+// PublishStatus queues the status to be published to the state topic, only
+// the latest status is published when they come faster than the broker
+// acknowledges them and a status older than the published one is dropped.
+// It satisfies onair.Listener.
+func (b *Bridge) PublishStatus(ctx context.Context, wl wlog.Logger, onAir entities.OnAirStatus) {
+	b.mu.Lock()
+	if onAir.Revision >= b.published && (b.latest == nil || b.latest.Revision < onAir.Revision) {
+		b.latest = &onAir
+	}
+	b.mu.Unlock()
+
+	signal(b.publish)
+}
+
+// run handles the commands and publishes the statuses until ctx is done.
+func (b *Bridge) run(ctx context.Context, wl wlog.Logger, onAirService onair.SVC) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-b.commands:
+			b.handleCommand(ctx, wl, onAirService, payload)
+		case <-b.reannounce:
+			if err := b.announce(wl); err != nil {
+				wl.Error(err)
+			}
+		case <-b.publish:
+			b.mu.Lock()
+			onAir := b.latest
+			b.latest = nil
+			if onAir != nil {
+				b.published = onAir.Revision
+			}
+			b.mu.Unlock()
+			if onAir == nil {
+				continue
+			}
+
+			if !b.client.IsConnected() {
+				wl.Debug("mqtt client not connected, skipping status publish")
+				continue
+			}
+			if err := b.publishStatus(*onAir); err != nil {
+				wl.Error(fmt.Errorf("error publishing status to mqtt: %w", err))
+			}
+		}
+	}
+}
+
+// signal wakes up the worker without blocking, a pending signal is enough.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+func (b *Bridge) onConnect(ctx context.Context, wl wlog.Logger, onAirService onair.SVC) error {
+	if err := b.announce(wl); err != nil {
+		return err
+	}
+
+	if err := wait(b.client.Publish(b.topics.Availability, qosAtLeastOnce, true, PayloadOnline)); err != nil {
+		return err
+	}
+
+	// the retained state is republished by the worker, which keeps the
+	// statuses in order
+	onAir, err := onAirService.GetOnAirStatus(ctx, wl)
+	if err != nil {
+		return err
+	}
+	b.PublishStatus(ctx, wl, onAir)
+
+	if err := wait(b.client.Subscribe(b.topics.Command, qosAtLeastOnce,
+		func(_ mqtt.Client, m mqtt.Message) {
+			// the client waits for the callbacks, which must not wait on it
+			select {
+			case b.commands <- string(m.Payload()):
+			default:
+				wl.Warnf("dropping home assistant command %s, too many are pending", m.Payload())
+			}
+		})); err != nil {
+		return err
+	}
+
+	// re-announce whenever home assistant comes back online
+	return wait(b.client.Subscribe(b.cfg.DiscoveryPrefix+haStatusSuffix, qosAtLeastOnce,
+		func(_ mqtt.Client, m mqtt.Message) {
+			if string(m.Payload()) == PayloadOnline {
+				signal(b.reannounce)
+			}
+		}))
+}
+
+func (b *Bridge) announce(wl wlog.Logger) error {
+	for _, a := range Announcements(b.cfg.DiscoveryPrefix, b.cfg.TopicPrefix, b.cfg.Channel) {
+		payload, err := json.Marshal(a.Config)
+		if err != nil {
+			return err
+		}
+
+		wl.Debugf("announcing %s", a.Topic)
+		if err := wait(b.client.Publish(a.Topic, qosAtLeastOnce, true, payload)); err != nil {
+			return fmt.Errorf("error announcing %s: %w", a.Topic, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *Bridge) handleCommand(ctx context.Context, wl wlog.Logger, onAirService onair.SVC, payload string) {
+	wl.Debugf("received home assistant command: %s", payload)
+
+	onAir, err := onAirService.GetOnAirStatus(ctx, wl)
+	if err != nil {
+		wl.Error(err)
+		return
+	}
+
+	switch payload {
+	case PayloadOn:
+		onAir.IsOnAir = true
+	case PayloadOff:
+		onAir.IsOnAir = false
+	default:
+		wl.Warnf("ignoring unknown home assistant command: %s", payload)
+		return
+	}
+
+	if _, err := onAirService.SetOnAirStatus(onair.WithSource(ctx, entities.SourceHomeAssistant), wl, onAir); err != nil {
+		wl.Error(err)
+	}
+}
+
+func (b *Bridge) publishStatus(onAir entities.OnAirStatus) error {
+	payload, err := NewStatePayload(onAir)
+	if err != nil {
+		return err
+	}
+
+	return wait(b.client.Publish(b.topics.State, qosAtLeastOnce, true, payload))
+}
+
+func wait(t mqtt.Token) error {
+	if !t.WaitTimeout(publishTimeout) {
+		return ErrPublishTimeout
+	}
+	return t.Error()
+}
@@ -0,0 +1,38 @@
+package homeassistant
+
+import validation "github.com/go-ozzo/ozzo-validation/v4"
+
+// Config holds the configuration options for the Home Assistant MQTT bridge.
+type Config struct {
+	// The MQTT broker to connect to, e.g. tcp://localhost:1883.
+	// The bridge is disabled when empty.
+	BrokerURL string `env:"MQTT_BROKER_URL"`
+	// Credentials used to authenticate with the broker
+	Username string `env:"MQTT_USERNAME"`
+	Password string `env:"MQTT_PASSWORD"`
+	// The MQTT client ID
+	ClientID string `env:"MQTT_CLIENT_ID" envDefault:"on-air"`
+	// The root of the topics the status is published to
+	TopicPrefix string `env:"MQTT_TOPIC_PREFIX" envDefault:"on-air"`
+	// The Home Assistant discovery prefix
+	DiscoveryPrefix string `env:"HA_DISCOVERY_PREFIX" envDefault:"homeassistant"`
+	// The channel (room) name, entity IDs are derived from it
+	Channel string `env:"ONAIR_CHANNEL" envDefault:"On Air"`
+}
+
+// Enabled reports whether a broker has been configured.
+func (c *Config) Enabled() bool {
+	return c.BrokerURL != ""
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.ClientID, validation.Required),
+		validation.Field(&c.TopicPrefix, validation.Required),
+		validation.Field(&c.DiscoveryPrefix, validation.Required),
+		validation.Field(&c.Channel, validation.Required),
+	)
+}
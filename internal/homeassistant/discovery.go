@@ -0,0 +1,188 @@
+package homeassistant
+
+import (
+	"encoding/json"
+	"on-air/internal/entities"
+	"strings"
+	"time"
+)
+
+// Payloads used by the switch entity.
+const (
+	PayloadOn          = "ON"
+	PayloadOff         = "OFF"
+	PayloadOnline      = "online"
+	PayloadOffline     = "offline"
+	nodeID             = "on_air"
+	timestampClass     = "timestamp"
+	switchComponent    = "switch"
+	sensorComponent    = "sensor"
+	manufacturer       = "on-air"
+	deviceModel        = "On-Air API"
+	statusTopicSuffix  = "state"
+	commandTopicSuffix = "set"
+	availTopicSuffix   = "availability"
+)
+
+// Topics holds every MQTT topic used for a single channel.
+type Topics struct {
+	State        string
+	Command      string
+	Availability string
+}
+
+// Device groups all the entities of a channel in Home Assistant.
+type Device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// EntityConfig is a Home Assistant MQTT discovery config payload.
+type EntityConfig struct {
+	Name              string `json:"name"`
+	UniqueID          string `json:"unique_id"`
+	ObjectID          string `json:"object_id"`
+	StateTopic        string `json:"state_topic"`
+	ValueTemplate     string `json:"value_template"`
+	CommandTopic      string `json:"command_topic,omitempty"`
+	PayloadOn         string `json:"payload_on,omitempty"`
+	PayloadOff        string `json:"payload_off,omitempty"`
+	StateOn           string `json:"state_on,omitempty"`
+	StateOff          string `json:"state_off,omitempty"`
+	DeviceClass       string `json:"device_class,omitempty"`
+	Icon              string `json:"icon,omitempty"`
+	AvailabilityTopic string `json:"availability_topic"`
+	Device            Device `json:"device"`
+}
+
+// Announcement is a discovery config together with the topic it is published to.
+type Announcement struct {
+	Topic  string
+	Config EntityConfig
+}
+
+// StatePayload is the JSON document published to the state topic.
+type StatePayload struct {
+	IsOnAir     bool    `json:"is_on_air"`
+	State       string  `json:"state"`
+	Message     string  `json:"message"`
+	LastUpdated *string `json:"last_updated"`
+	LastOnAir   *string `json:"last_on_air"`
+}
+
+// Slug turns a channel name into an identifier suitable for a Home Assistant
+// entity ID: lowercase ascii letters and digits separated by underscores.
+func Slug(name string) string {
+	var b strings.Builder
+	sep := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if sep && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			sep = false
+			continue
+		}
+		sep = true
+	}
+
+	if b.Len() == 0 {
+		return nodeID
+	}
+	return b.String()
+}
+
+// TopicsFor returns the topics used for the given channel.
+func TopicsFor(topicPrefix string, channel string) Topics {
+	base := topicPrefix + "/" + Slug(channel) + "/"
+	return Topics{
+		State:        base + statusTopicSuffix,
+		Command:      base + commandTopicSuffix,
+		Availability: base + availTopicSuffix,
+	}
+}
+
+// Announcements builds the discovery configs for the channel's switch and sensors.
+func Announcements(discoveryPrefix string, topicPrefix string, channel string) []Announcement {
+	slug := Slug(channel)
+	topics := TopicsFor(topicPrefix, channel)
+	device := Device{
+		Identifiers:  []string{nodeID + "_" + slug},
+		Name:         channel,
+		Manufacturer: manufacturer,
+		Model:        deviceModel,
+	}
+
+	configTopic := func(component string, objectID string) string {
+		return discoveryPrefix + "/" + component + "/" + nodeID + "/" + objectID + "/config"
+	}
+
+	onAirSwitch := EntityConfig{
+		Name:              channel,
+		UniqueID:          nodeID + "_" + slug + "_switch",
+		ObjectID:          slug,
+		StateTopic:        topics.State,
+		ValueTemplate:     "{{ value_json.state }}",
+		CommandTopic:      topics.Command,
+		PayloadOn:         PayloadOn,
+		PayloadOff:        PayloadOff,
+		StateOn:           PayloadOn,
+		StateOff:          PayloadOff,
+		Icon:              "mdi:broadcast",
+		AvailabilityTopic: topics.Availability,
+		Device:            device,
+	}
+
+	lastOnAir := EntityConfig{
+		Name:              channel + " Last On Air",
+		UniqueID:          nodeID + "_" + slug + "_last_on_air",
+		ObjectID:          slug + "_last_on_air",
+		StateTopic:        topics.State,
+		ValueTemplate:     "{{ value_json.last_on_air }}",
+		DeviceClass:       timestampClass,
+		AvailabilityTopic: topics.Availability,
+		Device:            device,
+	}
+
+	message := EntityConfig{
+		Name:              channel + " Message",
+		UniqueID:          nodeID + "_" + slug + "_message",
+		ObjectID:          slug + "_message",
+		StateTopic:        topics.State,
+		ValueTemplate:     "{{ value_json.message }}",
+		Icon:              "mdi:message-text",
+		AvailabilityTopic: topics.Availability,
+		Device:            device,
+	}
+
+	return []Announcement{
+		{Topic: configTopic(switchComponent, onAirSwitch.ObjectID), Config: onAirSwitch},
+		{Topic: configTopic(sensorComponent, lastOnAir.ObjectID), Config: lastOnAir},
+		{Topic: configTopic(sensorComponent, message.ObjectID), Config: message},
+	}
+}
+
+// NewStatePayload encodes the on air status for the state topic.
+func NewStatePayload(onAir entities.OnAirStatus) ([]byte, error) {
+	p := StatePayload{
+		IsOnAir: onAir.IsOnAir,
+		State:   PayloadOff,
+		Message: onAir.Message,
+	}
+	if onAir.IsOnAir {
+		p.State = PayloadOn
+	}
+	if onAir.LastUpdated.Valid {
+		s := onAir.LastUpdated.Time.Format(time.RFC3339)
+		p.LastUpdated = &s
+	}
+	if onAir.LastOnAir.Valid {
+		s := onAir.LastOnAir.Time.Format(time.RFC3339)
+		p.LastOnAir = &s
+	}
+
+	return json.Marshal(p)
+}
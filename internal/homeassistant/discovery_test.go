@@ -0,0 +1,67 @@
+package homeassistant_test
+
+import (
+	"encoding/json"
+	"on-air/internal/entities"
+	"on-air/internal/homeassistant"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"gotest.tools/v3/assert"
+)
+
+func TestSlug(t *testing.T) {
+	testData := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"simple", "Studio", "studio"},
+		{"spaces and case", "Studio B Booth", "studio_b_booth"},
+		{"punctuation", "  Room #2 -- Podcast! ", "room_2_podcast"},
+		{"empty", "!!!", "on_air"},
+	}
+
+	for _, tc := range testData {
+		assert.Equal(t, homeassistant.Slug(tc.input), tc.expected, tc.name)
+	}
+}
+
+func TestAnnouncements(t *testing.T) {
+	as := homeassistant.Announcements("homeassistant", "on-air", "Studio B")
+
+	assert.Equal(t, len(as), 3)
+
+	assert.Equal(t, as[0].Topic, "homeassistant/switch/on_air/studio_b/config")
+	assert.Equal(t, as[0].Config.ObjectID, "studio_b")
+	assert.Equal(t, as[0].Config.StateTopic, "on-air/studio_b/state")
+	assert.Equal(t, as[0].Config.CommandTopic, "on-air/studio_b/set")
+
+	assert.Equal(t, as[1].Topic, "homeassistant/sensor/on_air/studio_b_last_on_air/config")
+	assert.Equal(t, as[1].Config.DeviceClass, "timestamp")
+
+	assert.Equal(t, as[2].Topic, "homeassistant/sensor/on_air/studio_b_message/config")
+
+	for _, a := range as {
+		assert.DeepEqual(t, a.Config.Device.Identifiers, []string{"on_air_studio_b"})
+	}
+}
+
+func TestNewStatePayload(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	b, err := homeassistant.NewStatePayload(entities.OnAirStatus{
+		IsOnAir:     true,
+		Message:     "recording",
+		LastUpdated: null.TimeFrom(ts),
+	})
+	assert.NilError(t, err)
+
+	var got map[string]interface{}
+	assert.NilError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, got["state"], "ON")
+	assert.Equal(t, got["message"], "recording")
+	assert.Equal(t, got["last_updated"], "2024-01-02T03:04:05Z")
+	assert.Equal(t, got["last_on_air"], nil)
+}
@@ -0,0 +1,157 @@
+// Package ical writes iCalendar feeds as described in RFC 5545.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// ContentType is the media type of an iCalendar feed.
+	ContentType = "text/calendar; charset=utf-8"
+
+	// lines longer than this many octets, without the line break, are folded
+	maxLineLen = 75
+	utcLayout  = "20060102T150405Z"
+)
+
+// StatusConfirmed marks an event as definite.
+const StatusConfirmed = "CONFIRMED"
+
+// Calendar is a feed of events.
+type Calendar struct {
+	// ProdID identifies the product that created the feed
+	ProdID string
+	// Name is shown by clients as the calendar name
+	Name string
+	// TimeZone is the IANA name clients should display the events in.
+	// Event times are always written in UTC so they are unambiguous.
+	TimeZone string
+	Events   []Event
+}
+
+// Event is a single VEVENT.
+type Event struct {
+	// UID has to stay the same across feeds for clients to update the
+	// event instead of duplicating it
+	UID         string
+	Stamp       time.Time
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Categories  []string
+	Status      string
+	// RRule, RDates and ExDates are the recurrence of a recurring event, read
+	// from a feed and expanded by Calendar.Expand
+	RRule   string
+	RDates  []time.Time
+	ExDates []time.Time
+	// RecurrenceID identifies the occurrence of a recurring event this event
+	// is, or overrides when read from a feed
+	RecurrenceID time.Time
+}
+
+// Encode writes the calendar to w.
+func (c Calendar) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw}
+
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", c.ProdID)
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		e.line("X-WR-CALNAME", EscapeText(c.Name))
+	}
+	if c.TimeZone != "" {
+		e.line("X-WR-TIMEZONE", c.TimeZone)
+	}
+
+	for _, ev := range c.Events {
+		e.line("BEGIN", "VEVENT")
+		e.line("UID", ev.UID)
+		e.line("DTSTAMP", FormatTime(ev.Stamp))
+		e.line("DTSTART", FormatTime(ev.Start))
+		e.line("DTEND", FormatTime(ev.End))
+		e.line("SUMMARY", EscapeText(ev.Summary))
+		if ev.Description != "" {
+			e.line("DESCRIPTION", EscapeText(ev.Description))
+		}
+		if len(ev.Categories) > 0 {
+			categories := make([]string, len(ev.Categories))
+			for i, c := range ev.Categories {
+				categories[i] = EscapeText(c)
+			}
+			e.line("CATEGORIES", strings.Join(categories, ","))
+		}
+		if ev.Status != "" {
+			e.line("STATUS", ev.Status)
+		}
+		e.line("TRANSP", "OPAQUE")
+		e.line("END", "VEVENT")
+	}
+
+	e.line("END", "VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.WriteString(Fold(name + ":" + value))
+}
+
+// FormatTime formats t as a UTC date-time.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(utcLayout)
+}
+
+// EscapeText escapes a TEXT value.
+func EscapeText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return r.Replace(s)
+}
+
+// Fold splits a content line into lines of at most 75 octets, continued
+// with a leading space, and terminates it with CRLF. Multi-byte characters
+// are never split.
+func Fold(line string) string {
+	var b strings.Builder
+	limit := maxLineLen
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space counts towards the length of continuation lines
+		limit = maxLineLen - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
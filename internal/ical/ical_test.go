@@ -0,0 +1,386 @@
+package ical_test
+
+import (
+	"bytes"
+	"on-air/internal/ical"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFold(t *testing.T) {
+	testData := []struct {
+		name     string
+		line     string
+		expected string
+	}{
+		{"short", "SUMMARY:On air", "SUMMARY:On air\r\n"},
+		{"exactly 75 octets", strings.Repeat("a", 75), strings.Repeat("a", 75) + "\r\n"},
+		{
+			"long",
+			strings.Repeat("a", 160),
+			strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n " + strings.Repeat("a", 11) + "\r\n",
+		},
+		{
+			"multi-byte characters aren't split",
+			strings.Repeat("a", 74) + "é",
+			strings.Repeat("a", 74) + "\r\n é\r\n",
+		},
+	}
+
+	for _, tc := range testData {
+		got := ical.Fold(tc.line)
+		assert.Equal(t, got, tc.expected, tc.name)
+
+		for _, l := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+			assert.Assert(t, len(l) <= 75, tc.name)
+			assert.Assert(t, utf8.ValidString(l), tc.name)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	testData := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"plain", "On air", "On air"},
+		{"separators", "ep 1, part 2; live", `ep 1\, part 2\; live`},
+		{"backslash", `C:\shows`, `C:\\shows`},
+		{"newlines", "line 1\nline 2\r\nline 3", `line 1\nline 2\nline 3`},
+	}
+
+	for _, tc := range testData {
+		assert.Equal(t, ical.EscapeText(tc.text), tc.expected, tc.name)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	montreal, err := time.LoadLocation("America/Montreal")
+	assert.NilError(t, err)
+	start := time.Date(2024, 3, 10, 1, 30, 0, 0, montreal)
+
+	cal := ical.Calendar{
+		ProdID:   "-//on-air//on-air//EN",
+		Name:     "On Air",
+		TimeZone: "America/Montreal",
+		Events: []ical.Event{
+			{
+				UID:         "session-a@on-air",
+				Stamp:       start.Add(time.Hour),
+				Start:       start,
+				End:         start.Add(time.Hour),
+				Summary:     "On air: ep 1, live",
+				Description: "ep 1, live",
+				Categories:  []string{"podcast", "a,b"},
+				Status:      ical.StatusConfirmed,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NilError(t, cal.Encode(&buf))
+
+	expected := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//on-air//on-air//EN\r\n" +
+		"CALSCALE:GREGORIAN\r\n" +
+		"METHOD:PUBLISH\r\n" +
+		"X-WR-CALNAME:On Air\r\n" +
+		"X-WR-TIMEZONE:America/Montreal\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:session-a@on-air\r\n" +
+		// the event spans the daylight saving time change
+		"DTSTAMP:20240310T073000Z\r\n" +
+		"DTSTART:20240310T063000Z\r\n" +
+		"DTEND:20240310T073000Z\r\n" +
+		"SUMMARY:On air: ep 1\\, live\r\n" +
+		"DESCRIPTION:ep 1\\, live\r\n" +
+		"CATEGORIES:podcast,a\\,b\r\n" +
+		"STATUS:CONFIRMED\r\n" +
+		"TRANSP:OPAQUE\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	assert.Equal(t, buf.String(), expected)
+}
+
+func TestParse(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	assert.NilError(t, err)
+	montreal, err := time.LoadLocation("America/Montreal")
+	assert.NilError(t, err)
+
+	feed := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//test//EN\r\n" +
+		"X-WR-CALNAME:Studio A\\, bookings\r\n" +
+		"BEGIN:VTIMEZONE\r\n" +
+		"TZID:Europe/Paris\r\n" +
+		"END:VTIMEZONE\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:utc@test\r\n" +
+		"DTSTART:20240102T150000Z\r\n" +
+		"DTEND:20240102T160000Z\r\n" +
+		"SUMMARY:Podcast recording\\, ep 1\r\n" +
+		"DESCRIPTION:line 1\\nline 2 is folded \r\n" +
+		" over two lines\r\n" +
+		"CATEGORIES:podcast,a\\,b\r\n" +
+		"CATEGORIES:live\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"DESCRIPTION:ignored\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:tzid@test\r\n" +
+		"DTSTART;TZID=\"Europe/Paris\":20240102T100000\r\n" +
+		"DURATION:PT1H30M\r\n" +
+		"STATUS:cancelled\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:floating@test\r\n" +
+		"DTSTART:20240102T100000\r\n" +
+		"SUMMARY:Floating\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:all-day@test\r\n" +
+		"DTSTART;VALUE=DATE:20240103\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	cal, err := ical.Parse(strings.NewReader(feed), montreal)
+	assert.NilError(t, err)
+
+	assert.Equal(t, cal.Name, "Studio A, bookings")
+	assert.Equal(t, len(cal.Events), 4)
+
+	testData := []struct {
+		name       string
+		event      ical.Event
+		uid        string
+		start      time.Time
+		end        time.Time
+		summary    string
+		categories []string
+		status     string
+	}{
+		{
+			"utc", cal.Events[0], "utc@test",
+			time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 16, 0, 0, 0, time.UTC),
+			"Podcast recording, ep 1", []string{"podcast", "a,b", "live"}, "",
+		},
+		{
+			"tzid and duration", cal.Events[1], "tzid@test",
+			time.Date(2024, 1, 2, 10, 0, 0, 0, paris), time.Date(2024, 1, 2, 11, 30, 0, 0, paris),
+			"", nil, "CANCELLED",
+		},
+		{
+			"floating time without end", cal.Events[2], "floating@test",
+			time.Date(2024, 1, 2, 10, 0, 0, 0, montreal), time.Date(2024, 1, 2, 10, 0, 0, 0, montreal),
+			"Floating", nil, "",
+		},
+		{
+			"all day", cal.Events[3], "all-day@test",
+			time.Date(2024, 1, 3, 0, 0, 0, 0, montreal), time.Date(2024, 1, 4, 0, 0, 0, 0, montreal),
+			"", nil, "",
+		},
+	}
+
+	for _, tc := range testData {
+		assert.Equal(t, tc.event.UID, tc.uid, tc.name)
+		assert.Assert(t, tc.event.Start.Equal(tc.start), tc.name)
+		assert.Assert(t, tc.event.End.Equal(tc.end), tc.name)
+		assert.Equal(t, tc.event.Summary, tc.summary, tc.name)
+		assert.DeepEqual(t, tc.event.Categories, tc.categories)
+		assert.Equal(t, tc.event.Status, tc.status, tc.name)
+	}
+	assert.Equal(t, cal.Events[0].Description, "line 1\nline 2 is folded over two lines")
+
+	_, err = ical.Parse(strings.NewReader("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n"), time.UTC)
+	assert.ErrorIs(t, err, ical.ErrInvalidCalendar)
+
+}
+
+func TestParseTimezones(t *testing.T) {
+	montreal, err := time.LoadLocation("America/Montreal")
+	assert.NilError(t, err)
+	la, err := time.LoadLocation("America/Los_Angeles")
+	assert.NilError(t, err)
+
+	feed := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTIMEZONE\r\n" +
+		"TZID:/example.com/Pacific\r\n" +
+		"X-LIC-LOCATION:America/Los_Angeles\r\n" +
+		"BEGIN:STANDARD\r\n" +
+		"TZOFFSETFROM:-0700\r\n" +
+		"TZOFFSETTO:-0800\r\n" +
+		"END:STANDARD\r\n" +
+		"END:VTIMEZONE\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:alias@test\r\n" +
+		"DTSTART;TZID=/example.com/Pacific:20240102T100000\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:windows@test\r\n" +
+		"DTSTART;TZID=Pacific Standard Time:20240102T100000\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	cal, err := ical.Parse(strings.NewReader(feed), montreal)
+	assert.NilError(t, err)
+	assert.Equal(t, len(cal.Events), 2)
+
+	// the VTIMEZONE gives the IANA name of its TZID
+	assert.Assert(t, cal.Events[0].Start.Equal(time.Date(2024, 1, 2, 10, 0, 0, 0, la)))
+	// unknown TZIDs are read in the given location
+	assert.Assert(t, cal.Events[1].Start.Equal(time.Date(2024, 1, 2, 10, 0, 0, 0, montreal)))
+}
+
+func TestExpand(t *testing.T) {
+	montreal, err := time.LoadLocation("America/Montreal")
+	assert.NilError(t, err)
+
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, montreal)
+	}
+
+	testData := []struct {
+		name     string
+		event    string
+		from, to time.Time
+		expected []time.Time
+		err      error
+	}{
+		{
+			name: "weekly keeps the local time across daylight saving time",
+			event: "DTSTART;TZID=America/Montreal:20240301T100000\r\n" +
+				"DTEND;TZID=America/Montreal:20240301T110000\r\n" +
+				"RRULE:FREQ=WEEKLY\r\n",
+			from:     at(time.March, 5, 0),
+			to:       at(time.March, 23, 0),
+			expected: []time.Time{at(time.March, 8, 10), at(time.March, 15, 10), at(time.March, 22, 10)},
+		},
+		{
+			name: "weekly on several days with an interval and a count",
+			event: "DTSTART;TZID=America/Montreal:20240101T100000\r\n" +
+				"DURATION:PT1H\r\n" +
+				"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=5\r\n",
+			from: at(time.January, 1, 0),
+			to:   at(time.December, 31, 0),
+			expected: []time.Time{
+				at(time.January, 1, 10), at(time.January, 3, 10),
+				at(time.January, 15, 10), at(time.January, 17, 10),
+				at(time.January, 29, 10),
+			},
+		},
+		{
+			name: "daily until a date, with exceptions and overrides",
+			event: "DTSTART;TZID=America/Montreal:20240102T100000\r\n" +
+				"DTEND;TZID=America/Montreal:20240102T110000\r\n" +
+				"RRULE:FREQ=DAILY;UNTIL=20240106\r\n" +
+				"EXDATE;TZID=America/Montreal:20240103T100000,20240104T100000\r\n" +
+				"RDATE;TZID=America/Montreal:20240110T100000\r\n" +
+				"END:VEVENT\r\n" +
+				"BEGIN:VEVENT\r\n" +
+				"UID:recurring@test\r\n" +
+				"RECURRENCE-ID;TZID=America/Montreal:20240105T100000\r\n" +
+				"DTSTART;TZID=America/Montreal:20240105T140000\r\n" +
+				"DTEND;TZID=America/Montreal:20240105T150000\r\n",
+			from: at(time.January, 1, 0),
+			to:   at(time.January, 31, 0),
+			expected: []time.Time{
+				at(time.January, 2, 10), at(time.January, 5, 14), at(time.January, 6, 10), at(time.January, 10, 10),
+			},
+		},
+		{
+			name: "monthly on the last friday, ongoing occurrences included",
+			event: "DTSTART;TZID=America/Montreal:20240126T100000\r\n" +
+				"DTEND;TZID=America/Montreal:20240126T120000\r\n" +
+				"RRULE:FREQ=MONTHLY;BYDAY=-1FR\r\n",
+			from:     at(time.February, 23, 11),
+			to:       at(time.May, 1, 0),
+			expected: []time.Time{at(time.February, 23, 10), at(time.March, 29, 10), at(time.April, 26, 10)},
+		},
+		{
+			name: "monthly skips the months without the day",
+			event: "DTSTART;TZID=America/Montreal:20240131T100000\r\n" +
+				"RRULE:FREQ=MONTHLY;COUNT=3\r\n",
+			from:     at(time.January, 1, 0),
+			to:       at(time.December, 31, 0),
+			expected: []time.Time{at(time.January, 31, 10), at(time.March, 31, 10), at(time.May, 31, 10)},
+		},
+		{
+			name: "yearly all day",
+			event: "DTSTART;VALUE=DATE:20231225\r\n" +
+				"RRULE:FREQ=YEARLY\r\n",
+			from:     at(time.January, 1, 0),
+			to:       at(time.December, 31, 0),
+			expected: []time.Time{at(time.December, 25, 0)},
+		},
+		{
+			name: "unsupported rules keep the first occurrence",
+			event: "DTSTART;TZID=America/Montreal:20240102T100000\r\n" +
+				"RRULE:FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=-1\r\n",
+			from:     at(time.January, 1, 0),
+			to:       at(time.December, 31, 0),
+			expected: []time.Time{at(time.January, 2, 10)},
+			err:      ical.ErrUnsupportedRecurrence,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			feed := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:recurring@test\r\n" + tc.event + "END:VEVENT\r\nEND:VCALENDAR\r\n"
+			cal, err := ical.Parse(strings.NewReader(feed), montreal)
+			assert.NilError(t, err)
+
+			cal, err = cal.Expand(tc.from, tc.to)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NilError(t, err)
+			}
+
+			var starts []time.Time
+			for _, ev := range cal.Events {
+				assert.Equal(t, ev.UID, "recurring@test")
+				starts = append(starts, ev.Start)
+			}
+			// the overrides are kept where they are in the feed
+			sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+			assert.Equal(t, len(starts), len(tc.expected), "%v", starts)
+			for i := range starts {
+				assert.Assert(t, starts[i].Equal(tc.expected[i]), "%d: %s", i, starts[i])
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	in := ical.Calendar{
+		ProdID: "-//on-air//on-air//EN",
+		Name:   "On Air",
+		Events: []ical.Event{{
+			UID:         "session-a@on-air",
+			Stamp:       start,
+			Start:       start,
+			End:         start.Add(time.Hour),
+			Summary:     strings.Repeat("long summary; with, separators ", 5),
+			Description: "line 1\nline 2",
+			Categories:  []string{"podcast", "a,b"},
+			Status:      ical.StatusConfirmed,
+		}},
+	}
+
+	var buf bytes.Buffer
+	assert.NilError(t, in.Encode(&buf))
+
+	out, err := ical.Parse(&buf, time.UTC)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, out, in)
+}
@@ -0,0 +1,360 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	localLayout = "20060102T150405"
+	dateLayout  = "20060102"
+)
+
+// ErrInvalidCalendar is returned when a feed can't be parsed.
+var ErrInvalidCalendar = errors.New("invalid calendar")
+
+var durationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// property is a parsed content line.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads the events of a feed. Floating times and all-day dates are
+// interpreted in loc, as well as the times of a TZID that is neither an IANA
+// name nor a VTIMEZONE of the feed with an X-LIC-LOCATION. Recurring events
+// are read once, see Calendar.Expand.
+func Parse(r io.Reader, loc *time.Location) (Calendar, error) {
+	var cal Calendar
+	var ev *eventBuilder
+	var stack []string
+
+	lines, err := unfold(r)
+	if err != nil {
+		return Calendar{}, err
+	}
+	z := zones{aliases: timezoneAliases(lines), fallback: loc}
+
+	for i, line := range lines {
+		p, err := parseProperty(line)
+		if err != nil {
+			return Calendar{}, fmt.Errorf("%w: line %d: %s", ErrInvalidCalendar, i+1, err)
+		}
+
+		switch p.name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(p.value))
+			if len(stack) == 2 && stack[1] == "VEVENT" {
+				ev = &eventBuilder{}
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(p.value) {
+				return Calendar{}, fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalidCalendar, i+1, p.value)
+			}
+			if len(stack) == 2 && ev != nil {
+				if err := ev.finish(); err != nil {
+					return Calendar{}, err
+				}
+				cal.Events = append(cal.Events, ev.Event)
+				ev = nil
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		switch {
+		case len(stack) == 1 && stack[0] == "VCALENDAR":
+			switch p.name {
+			case "PRODID":
+				cal.ProdID = p.value
+			case "X-WR-CALNAME":
+				cal.Name = unescapeText(p.value)
+			case "X-WR-TIMEZONE":
+				cal.TimeZone = p.value
+			}
+		case len(stack) == 2 && ev != nil:
+			if err := ev.set(p, z); err != nil {
+				return Calendar{}, fmt.Errorf("%w: line %d: %s", ErrInvalidCalendar, i+1, err)
+			}
+		}
+	}
+
+	if len(stack) != 0 {
+		return Calendar{}, fmt.Errorf("%w: missing END:%s", ErrInvalidCalendar, stack[len(stack)-1])
+	}
+
+	return cal, nil
+}
+
+// eventBuilder holds the properties that only make sense once the whole
+// event has been read.
+type eventBuilder struct {
+	Event
+	allDay   bool
+	duration *time.Duration
+}
+
+func (ev *eventBuilder) set(p property, z zones) error {
+	var err error
+
+	switch p.name {
+	case "UID":
+		ev.UID = p.value
+	case "DTSTAMP":
+		ev.Stamp, err = parseDateTime(p, z)
+	case "DTSTART":
+		ev.Start, err = parseDateTime(p, z)
+		ev.allDay = p.params["VALUE"] == "DATE"
+	case "DTEND":
+		ev.End, err = parseDateTime(p, z)
+	case "DURATION":
+		var d time.Duration
+		d, err = parseDuration(p.value)
+		ev.duration = &d
+	case "SUMMARY":
+		ev.Summary = unescapeText(p.value)
+	case "DESCRIPTION":
+		ev.Description = unescapeText(p.value)
+	case "CATEGORIES":
+		for _, c := range splitText(p.value) {
+			if c = strings.TrimSpace(c); c != "" {
+				ev.Categories = append(ev.Categories, c)
+			}
+		}
+	case "STATUS":
+		ev.Status = strings.ToUpper(p.value)
+	case "RRULE":
+		ev.RRule = p.value
+	case "RDATE":
+		ev.RDates, err = appendDateTimes(ev.RDates, p, z)
+	case "EXDATE":
+		ev.ExDates, err = appendDateTimes(ev.ExDates, p, z)
+	case "RECURRENCE-ID":
+		ev.RecurrenceID, err = parseDateTime(p, z)
+	}
+
+	return err
+}
+
+// finish derives the end of events without DTEND.
+func (ev *eventBuilder) finish() error {
+	if ev.Start.IsZero() {
+		return fmt.Errorf("%w: event %q has no DTSTART", ErrInvalidCalendar, ev.UID)
+	}
+
+	switch {
+	case !ev.End.IsZero():
+	case ev.duration != nil:
+		ev.End = ev.Start.Add(*ev.duration)
+	case ev.allDay:
+		ev.End = ev.Start.AddDate(0, 0, 1)
+	default:
+		ev.End = ev.Start
+	}
+
+	return nil
+}
+
+func parseDateTime(p property, z zones) (time.Time, error) {
+	loc := z.fallback
+	if tzid, ok := p.params["TZID"]; ok {
+		loc = z.location(tzid)
+	}
+
+	switch {
+	case p.params["VALUE"] == "DATE":
+		return time.ParseInLocation(dateLayout, p.value, loc)
+	case strings.HasSuffix(p.value, "Z"):
+		return time.Parse(utcLayout, p.value)
+	default:
+		return time.ParseInLocation(localLayout, p.value, loc)
+	}
+}
+
+// appendDateTimes appends the comma separated values of p, periods are read
+// as their start.
+func appendDateTimes(ts []time.Time, p property, z zones) ([]time.Time, error) {
+	for _, v := range strings.Split(p.value, ",") {
+		v, _, _ = strings.Cut(v, "/")
+		t, err := parseDateTime(property{name: p.name, params: p.params, value: v}, z)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// zones resolves the TZID parameters of a feed.
+type zones struct {
+	// aliases maps TZIDs to the IANA names given by their VTIMEZONE
+	aliases map[string]string
+	// fallback is the location of the floating times and unknown TZIDs
+	fallback *time.Location
+}
+
+func (z zones) location(tzid string) *time.Location {
+	for _, name := range []string{tzid, z.aliases[tzid]} {
+		if name == "" {
+			continue
+		}
+		if tz, err := time.LoadLocation(name); err == nil {
+			return tz
+		}
+	}
+	return z.fallback
+}
+
+// timezoneAliases reads the X-LIC-LOCATION of the VTIMEZONE components,
+// which some clients set to the IANA name of their custom TZIDs.
+func timezoneAliases(lines []string) map[string]string {
+	aliases := map[string]string{}
+
+	var inTimezone bool
+	var tzid, location string
+	for _, line := range lines {
+		p, err := parseProperty(line)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VTIMEZONE"):
+			inTimezone, tzid, location = true, "", ""
+		case p.name == "END" && strings.EqualFold(p.value, "VTIMEZONE"):
+			if tzid != "" && location != "" {
+				aliases[tzid] = location
+			}
+			inTimezone = false
+		case inTimezone && p.name == "TZID":
+			tzid = p.value
+		case inTimezone && p.name == "X-LIC-LOCATION":
+			location = p.value
+		}
+	}
+
+	return aliases
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	m := durationRe.FindStringSubmatch(s)
+	if m == nil || s == "P" || s == "PT" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// unfold joins the folded lines of a feed.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, sc.Err()
+}
+
+// parseProperty splits a content line into its name, parameters and value.
+// Parameter values can be quoted and contain colons and semicolons.
+func parseProperty(line string) (property, error) {
+	p := property{params: map[string]string{}}
+
+	var quoted bool
+	var fields []string
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			fields = append(fields, line[start:i])
+			start = i + 1
+		case c == ':' && !quoted:
+			fields = append(fields, line[start:i])
+			p.value = line[i+1:]
+			p.name = strings.ToUpper(fields[0])
+			for _, f := range fields[1:] {
+				k, v, _ := strings.Cut(f, "=")
+				p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+			}
+			if p.name == "" {
+				return property{}, errors.New("missing property name")
+			}
+			return p, nil
+		}
+	}
+
+	return property{}, fmt.Errorf("malformed content line %q", line)
+}
+
+// unescapeText reverses EscapeText.
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// splitText splits a list of TEXT values on unescaped commas.
+func splitText(s string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, unescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, unescapeText(s[start:]))
+}
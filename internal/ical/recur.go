@@ -0,0 +1,459 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies
+const (
+	freqDaily   = "DAILY"
+	freqWeekly  = "WEEKLY"
+	freqMonthly = "MONTHLY"
+	freqYearly  = "YEARLY"
+)
+
+// maxPeriods bounds the days, weeks, months or years a rule is iterated
+// over, so that a rule matching no date can't loop forever.
+const maxPeriods = 100000
+
+// ErrUnsupportedRecurrence is returned for the recurrence rules that can't
+// be expanded.
+var ErrUnsupportedRecurrence = errors.New("unsupported recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// weekdayNum is a BYDAY value, e.g. -1FR for the last Friday.
+type weekdayNum struct {
+	// n is the nth weekday of the month, counted from the end when negative,
+	// or 0 for every one of them
+	n   int
+	day time.Weekday
+}
+
+// rrule is a parsed RRULE. Only the FREQ, INTERVAL, COUNT, UNTIL, WKST,
+// BYDAY, BYMONTHDAY and BYMONTH parts are supported.
+type rrule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	wkst       time.Weekday
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []time.Month
+}
+
+// Expand replaces the recurring events with their occurrences overlapping
+// from and to. The occurrences keep the UID of their event and have their
+// start as RecurrenceID; the ones excluded by an EXDATE or overridden by an
+// event with the same UID and RecurrenceID are left out. Events that don't
+// recur are kept as they are.
+//
+// Events whose rule can't be expanded only keep their first occurrence and
+// are reported in an ErrUnsupportedRecurrence error, returned along with the
+// expanded calendar.
+func (c Calendar) Expand(from, to time.Time) (Calendar, error) {
+	overridden := map[string]bool{}
+	for _, ev := range c.Events {
+		if !ev.RecurrenceID.IsZero() {
+			overridden[eventKey(ev.UID, ev.RecurrenceID)] = true
+		}
+	}
+
+	var errs []error
+	expanded := c
+	expanded.Events = nil
+	for _, ev := range c.Events {
+		if ev.RRule == "" && len(ev.RDates) == 0 {
+			expanded.Events = append(expanded.Events, ev)
+			continue
+		}
+
+		starts, err := ev.occurrences(to)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("event %q: %w", ev.UID, err))
+			expanded.Events = append(expanded.Events, ev)
+			continue
+		}
+
+		for _, start := range starts {
+			occ := ev.at(start)
+			if !occ.End.After(from) || overridden[eventKey(ev.UID, start)] {
+				continue
+			}
+			expanded.Events = append(expanded.Events, occ)
+		}
+	}
+
+	return expanded, errors.Join(errs...)
+}
+
+// occurrences returns the starts of the occurrences before to.
+func (ev Event) occurrences(to time.Time) ([]time.Time, error) {
+	starts := []time.Time{ev.Start}
+	if ev.RRule != "" {
+		r, err := parseRRule(ev.RRule, ev.Start.Location())
+		if err != nil {
+			return nil, err
+		}
+		starts = r.expand(ev.Start, to)
+	}
+	starts = append(starts, ev.RDates...)
+
+	var kept []time.Time
+	for _, start := range starts {
+		if start.Before(to) && !containsTime(ev.ExDates, start) && !containsTime(kept, start) {
+			kept = append(kept, start)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].Before(kept[j])
+	})
+
+	return kept, nil
+}
+
+// at returns the occurrence of the event starting at start. Events lasting
+// whole days keep lasting as many days across daylight saving time changes.
+func (ev Event) at(start time.Time) Event {
+	d := ev.End.Sub(ev.Start)
+
+	occ := ev
+	occ.Start = start
+	if day := 24 * time.Hour; d > 0 && d%day == 0 {
+		occ.End = start.AddDate(0, 0, int(d/day))
+	} else {
+		occ.End = start.Add(d)
+	}
+	occ.RecurrenceID = start
+	occ.RRule = ""
+	occ.RDates = nil
+	occ.ExDates = nil
+
+	return occ
+}
+
+func parseRRule(s string, loc *time.Location) (rrule, error) {
+	r := rrule{interval: 1, wkst: time.Monday}
+
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return rrule{}, fmt.Errorf("%w: malformed part %q", ErrUnsupportedRecurrence, part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.freq = strings.ToUpper(value)
+			switch r.freq {
+			case freqDaily, freqWeekly, freqMonthly, freqYearly:
+			default:
+				err = fmt.Errorf("%w: FREQ=%s", ErrUnsupportedRecurrence, value)
+			}
+		case "INTERVAL":
+			r.interval, err = parseRulePart(name, value, 1, maxPeriods)
+		case "COUNT":
+			r.count, err = parseRulePart(name, value, 1, maxPeriods)
+		case "UNTIL":
+			r.until, err = parseUntil(value, loc)
+		case "WKST":
+			var wkst weekdayNum
+			wkst, err = parseWeekdayNum(value)
+			r.wkst = wkst.day
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				var wd weekdayNum
+				if wd, err = parseWeekdayNum(v); err != nil {
+					break
+				}
+				r.byDay = append(r.byDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				var md int
+				if md, err = parseRulePart(name, v, -31, 31); err != nil {
+					break
+				}
+				r.byMonthDay = append(r.byMonthDay, md)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				var m int
+				if m, err = parseRulePart(name, v, 1, 12); err != nil {
+					break
+				}
+				r.byMonth = append(r.byMonth, time.Month(m))
+			}
+		default:
+			err = fmt.Errorf("%w: %s", ErrUnsupportedRecurrence, name)
+		}
+		if err != nil {
+			return rrule{}, err
+		}
+	}
+
+	if r.freq == "" {
+		return rrule{}, fmt.Errorf("%w: missing FREQ", ErrUnsupportedRecurrence)
+	}
+	for _, wd := range r.byDay {
+		// the nth weekday of the year isn't supported
+		if wd.n != 0 && (r.freq == freqDaily || r.freq == freqWeekly || (r.freq == freqYearly && len(r.byMonth) == 0)) {
+			return rrule{}, fmt.Errorf("%w: BYDAY=%d%s with FREQ=%s", ErrUnsupportedRecurrence, wd.n, wd.day, r.freq)
+		}
+	}
+
+	return r, nil
+}
+
+func parseRulePart(name, value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max || n == 0 {
+		return 0, fmt.Errorf("%w: %s=%s", ErrUnsupportedRecurrence, name, value)
+	}
+	return n, nil
+}
+
+func parseWeekdayNum(s string) (weekdayNum, error) {
+	if len(s) < 2 {
+		return weekdayNum{}, fmt.Errorf("%w: weekday %q", ErrUnsupportedRecurrence, s)
+	}
+
+	day, ok := weekdays[strings.ToUpper(s[len(s)-2:])]
+	if !ok {
+		return weekdayNum{}, fmt.Errorf("%w: weekday %q", ErrUnsupportedRecurrence, s)
+	}
+
+	wd := weekdayNum{day: day}
+	if n := s[:len(s)-2]; n != "" {
+		var err error
+		if wd.n, err = parseRulePart("BYDAY", n, -5, 5); err != nil {
+			return weekdayNum{}, err
+		}
+	}
+
+	return wd, nil
+}
+
+// parseUntil reads an UNTIL date or date-time, floating ones are in loc. A
+// date includes the occurrences of that whole day.
+func parseUntil(s string, loc *time.Location) (time.Time, error) {
+	var t time.Time
+	var err error
+	switch {
+	case len(s) == len(dateLayout):
+		t, err = time.ParseInLocation(dateLayout, s, loc)
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	case strings.HasSuffix(s, "Z"):
+		t, err = time.Parse(utcLayout, s)
+	default:
+		t, err = time.ParseInLocation(localLayout, s, loc)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: UNTIL=%s", ErrUnsupportedRecurrence, s)
+	}
+	return t, nil
+}
+
+// expand returns the occurrences of the rule starting before to, dtstart
+// included. They keep the time of day of dtstart in its location.
+func (r rrule) expand(dtstart, to time.Time) []time.Time {
+	starts := []time.Time{dtstart}
+
+	for i := 0; i < maxPeriods; i++ {
+		begin, candidates := r.period(dtstart, i*r.interval)
+		if !begin.Before(to) || (!r.until.IsZero() && begin.After(r.until)) {
+			break
+		}
+
+		for _, t := range candidates {
+			if !t.After(dtstart) {
+				continue
+			}
+			if !t.Before(to) || (!r.until.IsZero() && t.After(r.until)) || (r.count > 0 && len(starts) >= r.count) {
+				return starts
+			}
+			starts = append(starts, t)
+		}
+	}
+
+	return starts
+}
+
+// period returns the beginning of the nth day, week, month or year after
+// the one of dtstart, depending on the frequency, and the occurrences it
+// holds in order.
+func (r rrule) period(dtstart time.Time, n int) (time.Time, []time.Time) {
+	y, m, d := dtstart.Date()
+	hh, mm, ss := dtstart.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, 0, dtstart.Location())
+	}
+
+	var begin time.Time
+	var candidates []time.Time
+	switch r.freq {
+	case freqDaily:
+		begin = at(y, m, d+n)
+		if r.matchesDay(begin) {
+			candidates = append(candidates, begin)
+		}
+	case freqWeekly:
+		offset := (int(dtstart.Weekday()) - int(r.wkst) + 7) % 7
+		begin = at(y, m, d-offset+7*n)
+		for k := 0; k < 7; k++ {
+			t := at(y, m, d-offset+7*n+k)
+			if r.matchesWeekday(t.Weekday(), dtstart.Weekday()) {
+				candidates = append(candidates, t)
+			}
+		}
+	case freqMonthly:
+		begin = at(y, m+time.Month(n), 1)
+		candidates = r.monthDays(begin, d)
+	case freqYearly:
+		begin = at(y+n, time.January, 1)
+		months := r.byMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+			if len(r.byMonthDay) > 0 || len(r.byDay) > 0 {
+				months = []time.Month{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			}
+		}
+		months = append([]time.Month(nil), months...)
+		sort.Slice(months, func(i, j int) bool { return months[i] < months[j] })
+		for _, month := range months {
+			candidates = append(candidates, r.monthDays(at(y+n, month, 1), d)...)
+		}
+		return begin, candidates
+	}
+
+	var kept []time.Time
+	for _, t := range candidates {
+		if r.matchesMonth(t.Month()) {
+			kept = append(kept, t)
+		}
+	}
+	return begin, kept
+}
+
+// monthDays returns the occurrences in the month starting at first, on the
+// day of dtstart when no day is set by the rule.
+func (r rrule) monthDays(first time.Time, dtstartDay int) []time.Time {
+	last := first.AddDate(0, 1, -1).Day()
+	firstWeekday := first.Weekday()
+	lastWeekday := first.AddDate(0, 0, last-1).Weekday()
+
+	var days []int
+	switch {
+	case len(r.byMonthDay) > 0:
+		for _, md := range r.byMonthDay {
+			if md < 0 {
+				md = last + 1 + md
+			}
+			if md < 1 || md > last {
+				continue
+			}
+			// BYDAY then only limits the days set
+			if t := first.AddDate(0, 0, md-1); len(r.byDay) == 0 || r.matchesWeekday(t.Weekday(), t.Weekday()) {
+				days = append(days, md)
+			}
+		}
+	case len(r.byDay) > 0:
+		for _, wd := range r.byDay {
+			switch {
+			case wd.n > 0:
+				days = append(days, 1+(int(wd.day)-int(firstWeekday)+7)%7+7*(wd.n-1))
+			case wd.n < 0:
+				days = append(days, last-(int(lastWeekday)-int(wd.day)+7)%7+7*(wd.n+1))
+			default:
+				for day := 1 + (int(wd.day)-int(firstWeekday)+7)%7; day <= last; day += 7 {
+					days = append(days, day)
+				}
+			}
+		}
+	default:
+		days = append(days, dtstartDay)
+	}
+	sort.Ints(days)
+
+	var occurrences []time.Time
+	for i, day := range days {
+		if day < 1 || day > last || (i > 0 && day == days[i-1]) {
+			continue
+		}
+		occurrences = append(occurrences, first.AddDate(0, 0, day-1))
+	}
+	return occurrences
+}
+
+// matchesDay reports whether a daily occurrence falls on the days and
+// months of the rule.
+func (r rrule) matchesDay(t time.Time) bool {
+	if len(r.byDay) > 0 && !r.matchesWeekday(t.Weekday(), t.Weekday()) {
+		return false
+	}
+	if len(r.byMonthDay) == 0 {
+		return true
+	}
+
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, md := range r.byMonthDay {
+		if md == t.Day() || last+1+md == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday reports whether day is one of the BYDAY weekdays, or
+// fallback when none is set.
+func (r rrule) matchesWeekday(day, fallback time.Weekday) bool {
+	if len(r.byDay) == 0 {
+		return day == fallback
+	}
+	for _, wd := range r.byDay {
+		if wd.day == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (r rrule) matchesMonth(m time.Month) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, month := range r.byMonth {
+		if month == m {
+			return true
+		}
+	}
+	return false
+}
+
+// eventKey identifies an occurrence, recurring events share their UID.
+func eventKey(uid string, start time.Time) string {
+	return uid + "/" + start.UTC().Format(time.RFC3339)
+}
+
+func containsTime(ts []time.Time, t time.Time) bool {
+	for _, other := range ts {
+		if other.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package idempotency
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	// StoreMemory keeps the responses in memory, they're lost on restart
+	StoreMemory = "memory"
+	// StorePostgres keeps the responses in the idempotency_keys table
+	StorePostgres = "postgres"
+)
+
+// Config holds the configuration options for the idempotency keys.
+type Config struct {
+	// How long a response is replayed for
+	TTL time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h"`
+	// Where the responses are kept, memory or postgres
+	Store string `env:"IDEMPOTENCY_STORE" envDefault:"memory"`
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.TTL, validation.Min(time.Second)),
+		validation.Field(&c.Store, validation.In(StoreMemory, StorePostgres)),
+	)
+}
@@ -0,0 +1,8 @@
+package idempotency
+
+import "errors"
+
+var (
+	ErrInFlight  = errors.New("a request with this idempotency key is in progress")
+	ErrKeyReused = errors.New("idempotency key reused with a different request")
+)
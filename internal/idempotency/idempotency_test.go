@@ -0,0 +1,90 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"on-air/internal/idempotency"
+	"on-air/migrations"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"gotest.tools/v3/assert"
+)
+
+const ttl = time.Hour
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, idempotency.NewMemoryStore(ttl))
+}
+
+// TestPostgresStore needs a Postgres database in TEST_DATABASE_URL. The
+// table is created as a temporary table so it doesn't touch the schema.
+func TestPostgresStore(t *testing.T) {
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL isn't set")
+	}
+
+	db, err := sqlx.Open("postgres", dbURL)
+	assert.NilError(t, err)
+	defer db.Close()
+	// temporary tables only exist on the connection creating them
+	db.SetMaxOpenConns(1)
+
+	up, err := migrations.FS.ReadFile("0001_create_idempotency_keys.up.sql")
+	assert.NilError(t, err)
+	_, err = db.Exec(strings.Replace(string(up), "CREATE TABLE", "CREATE TEMP TABLE", 1))
+	assert.NilError(t, err)
+
+	testStore(t, idempotency.NewPostgresStore(db, ttl))
+}
+
+func testStore(t *testing.T, store idempotency.Store) {
+	t.Helper()
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	resp := idempotency.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       []byte(`{"is_on_air":true}`),
+	}
+
+	// the first request claims the key
+	got, err := store.Begin(ctx, "k1", "a", now)
+	assert.NilError(t, err)
+	assert.Assert(t, got == nil)
+
+	// retries wait for it to complete
+	_, err = store.Begin(ctx, "k1", "a", now)
+	assert.ErrorIs(t, err, idempotency.ErrInFlight)
+	_, err = store.Begin(ctx, "k1", "b", now)
+	assert.ErrorIs(t, err, idempotency.ErrKeyReused)
+
+	// then get the response
+	assert.NilError(t, store.Complete(ctx, "k1", resp, now))
+	got, err = store.Begin(ctx, "k1", "a", now.Add(time.Minute))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, *got, resp)
+
+	_, err = store.Begin(ctx, "k1", "b", now.Add(time.Minute))
+	assert.ErrorIs(t, err, idempotency.ErrKeyReused)
+
+	// until it expires
+	got, err = store.Begin(ctx, "k1", "b", now.Add(ttl))
+	assert.NilError(t, err)
+	assert.Assert(t, got == nil)
+
+	// released keys can be claimed again
+	got, err = store.Begin(ctx, "k2", "a", now)
+	assert.NilError(t, err)
+	assert.Assert(t, got == nil)
+	assert.NilError(t, store.Release(ctx, "k2"))
+	got, err = store.Begin(ctx, "k2", "b", now)
+	assert.NilError(t, err)
+	assert.Assert(t, got == nil)
+}
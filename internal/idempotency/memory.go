@@ -0,0 +1,122 @@
+package idempotency
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	fingerprint string
+	// resp is nil while the request is in flight
+	resp      *Response
+	expiresAt time.Time
+}
+
+// expiry is when a key expires. A key gets a new expiry every time it's
+// claimed or completed, the older ones are skipped when they come up.
+type expiry struct {
+	key string
+	at  time.Time
+}
+
+// expiryQueue orders the expiries, the earliest first.
+type expiryQueue []expiry
+
+func (q expiryQueue) Len() int            { return len(q) }
+func (q expiryQueue) Less(i, j int) bool  { return q[i].at.Before(q[j].at) }
+func (q expiryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *expiryQueue) Push(x interface{}) { *q = append(*q, x.(expiry)) }
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// MemoryStore is a Store kept in memory. It doesn't survive restarts and
+// isn't shared between instances.
+type MemoryStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	// expiries indexes the entries by expiry so they're purged without
+	// going through all of them
+	expiries expiryQueue
+}
+
+// NewMemoryStore returns a Store replaying responses for ttl.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:     ttl,
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+func (s *MemoryStore) Begin(ctx context.Context, key string, fingerprint string, now time.Time) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge(now)
+
+	e, ok := s.entries[key]
+	switch {
+	case !ok:
+		s.set(key, memoryEntry{fingerprint: fingerprint, expiresAt: now.Add(claimTTL)})
+		return nil, nil
+	case e.fingerprint != fingerprint:
+		return nil, ErrKeyReused
+	case e.resp == nil:
+		return nil, ErrInFlight
+	}
+
+	return copyResponse(e.resp), nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, resp Response, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entries[key]
+	e.resp = copyResponse(&resp)
+	e.expiresAt = now.Add(s.ttl)
+	s.set(key, e)
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// set stores the entry and indexes its expiry.
+// It must be called with the lock held.
+func (s *MemoryStore) set(key string, e memoryEntry) {
+	s.entries[key] = e
+	heap.Push(&s.expiries, expiry{key: key, at: e.expiresAt})
+}
+
+// purge drops the entries expired by now.
+// It must be called with the lock held.
+func (s *MemoryStore) purge(now time.Time) {
+	for len(s.expiries) > 0 && !now.Before(s.expiries[0].at) {
+		exp := heap.Pop(&s.expiries).(expiry)
+		// the entry may have been released, or given a later expiry
+		if e, ok := s.entries[exp.key]; ok && e.expiresAt.Equal(exp.at) {
+			delete(s.entries, exp.key)
+		}
+	}
+}
+
+func copyResponse(r *Response) *Response {
+	return &Response{
+		StatusCode: r.StatusCode,
+		Header:     r.Header.Clone(),
+		Body:       append([]byte(nil), r.Body...),
+	}
+}
@@ -0,0 +1,99 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStore is a Store kept in the idempotency_keys table, shared by
+// every instance and surviving restarts.
+type PostgresStore struct {
+	db  *sqlx.DB
+	ttl time.Duration
+}
+
+// NewPostgresStore returns a Store replaying responses for ttl.
+func NewPostgresStore(db *sqlx.DB, ttl time.Duration) *PostgresStore {
+	return &PostgresStore{db: db, ttl: ttl}
+}
+
+type keyRow struct {
+	Fingerprint string        `db:"fingerprint"`
+	StatusCode  sql.NullInt64 `db:"status_code"`
+	Header      []byte        `db:"header"`
+	Body        []byte        `db:"body"`
+}
+
+func (s *PostgresStore) Begin(ctx context.Context, key string, fingerprint string, now time.Time) (*Response, error) {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now); err != nil {
+		return nil, fmt.Errorf("error purging idempotency keys: %w", err)
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (key, fingerprint, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING`,
+		key, fingerprint, now.Add(claimTTL))
+	if err != nil {
+		return nil, fmt.Errorf("error claiming idempotency key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 1 {
+		return nil, nil
+	}
+
+	var row keyRow
+	err = s.db.GetContext(ctx, &row,
+		`SELECT fingerprint, status_code, header, body FROM idempotency_keys WHERE key = $1`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		// released or expired since the insert, the retry will claim it
+		return nil, ErrInFlight
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading idempotency key: %w", err)
+	}
+
+	switch {
+	case row.Fingerprint != fingerprint:
+		return nil, ErrKeyReused
+	case !row.StatusCode.Valid:
+		return nil, ErrInFlight
+	}
+
+	resp := &Response{StatusCode: int(row.StatusCode.Int64), Body: row.Body}
+	if err := json.Unmarshal(row.Header, &resp.Header); err != nil {
+		return nil, fmt.Errorf("error decoding stored response headers: %w", err)
+	}
+	return resp, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, key string, resp Response, now time.Time) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET status_code = $2, header = $3, body = $4, expires_at = $5
+		WHERE key = $1`,
+		key, resp.StatusCode, header, resp.Body, now.Add(s.ttl))
+	if err != nil {
+		return fmt.Errorf("error storing response: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL`, key)
+	if err != nil {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
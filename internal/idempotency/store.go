@@ -0,0 +1,66 @@
+// Package idempotency remembers the responses to requests carrying an
+// Idempotency-Key header so retries are answered without being applied again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MaxKeyLen is the longest idempotency key accepted.
+const MaxKeyLen = 255
+
+// claimTTL is how long a claimed key stays in flight, in case the instance
+// handling the request dies before completing it.
+const claimTTL = time.Minute
+
+// Response is a stored response, replayed as is.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Store keeps the responses of the idempotent requests.
+type Store interface {
+	// Begin claims a key for the request with the fingerprint. It returns
+	// the stored response when the key has already been used by the same
+	// request, nil when the key has been claimed, ErrKeyReused when the key
+	// has been used by a different request and ErrInFlight when the request
+	// is still being handled.
+	Begin(ctx context.Context, key string, fingerprint string, now time.Time) (*Response, error)
+	// Complete stores the response of a claimed key.
+	Complete(ctx context.Context, key string, resp Response, now time.Time) error
+	// Release forgets a claimed key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// Fingerprint identifies a request by its method, URL and body.
+func Fingerprint(method string, url string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + url + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewStore returns the Store configured, db is only used by the postgres store.
+func NewStore(cfg *Config, db *sqlx.DB) (Store, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Store == StorePostgres {
+		if db == nil {
+			return nil, errors.New("the postgres idempotency store needs a database")
+		}
+		return NewPostgresStore(db, cfg.TTL), nil
+	}
+
+	return NewMemoryStore(cfg.TTL), nil
+}
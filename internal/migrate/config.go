@@ -0,0 +1,24 @@
+package migrate
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Config holds the configuration options for the migrations.
+type Config struct {
+	// Whether the pending migrations are applied at startup
+	AutoMigrate bool `env:"DB_AUTO_MIGRATE" envDefault:"false"`
+	// How long to wait for another instance to finish migrating
+	LockTimeout time.Duration `env:"DB_MIGRATE_LOCK_TIMEOUT" envDefault:"1m"`
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.LockTimeout, validation.Min(time.Second)),
+	)
+}
@@ -0,0 +1,9 @@
+package migrate
+
+import "errors"
+
+var (
+	ErrInvalidMigration = errors.New("invalid migration")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrLockTimeout      = errors.New("timed out waiting for the migration lock")
+)
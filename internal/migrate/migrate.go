@@ -0,0 +1,247 @@
+// Package migrate applies and reverts the schema migrations.
+//
+// Applied versions are tracked in the schema_migrations table. Every command
+// holds a Postgres advisory lock, so instances starting together don't apply
+// the same migration twice, and every migration runs in its own transaction
+// along with its bookkeeping.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"on-air/internal/wlog"
+	"sort"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+)
+
+// lockKey identifies the advisory lock, it spells on-air in ASCII.
+const lockKey int64 = 0x6f6e2d616972
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    BIGINT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// Status is the state of a migration in the database.
+type Status struct {
+	Version int64
+	Name    string
+	// AppliedAt is null when the migration is pending
+	AppliedAt null.Time
+	// Unknown is set when the migration is applied but not part of this binary
+	Unknown bool
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db          *sqlx.DB
+	migrations  []Migration
+	lockTimeout time.Duration
+}
+
+// New creates a Migrator applying the migrations, ordered by version, to db.
+func New(db *sqlx.DB, migrations []Migration, cfg *Config) (*Migrator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		lockTimeout: cfg.LockTimeout,
+	}, nil
+}
+
+// Up applies the pending migrations and returns how many were applied.
+func (mg *Migrator) Up(ctx context.Context, wl wlog.Logger) (int, error) {
+	applied := 0
+	err := mg.withLock(ctx, func(conn *sqlx.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		known := make(map[int64]bool, len(mg.migrations))
+		for _, m := range mg.migrations {
+			known[m.Version] = true
+		}
+		for v, row := range versions {
+			if !known[v] {
+				wl.Infof("migration %d_%s was applied by a newer version, leaving it", v, row.Name)
+			}
+		}
+
+		for _, m := range mg.migrations {
+			if _, ok := versions[m.Version]; ok {
+				continue
+			}
+
+			if err := run(ctx, conn, m.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+				return fmt.Errorf("error applying migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			wl.Infof("applied migration %d_%s", m.Version, m.Name)
+			applied++
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts up to steps of the latest applied migrations and returns
+// how many were reverted. It fails when a migration to revert isn't part
+// of this binary.
+func (mg *Migrator) Down(ctx context.Context, wl wlog.Logger, steps int) (int, error) {
+	byVersion := make(map[int64]Migration, len(mg.migrations))
+	for _, m := range mg.migrations {
+		byVersion[m.Version] = m
+	}
+
+	reverted := 0
+	err := mg.withLock(ctx, func(conn *sqlx.Conn) error {
+		var versions []int64
+		err := conn.SelectContext(ctx, &versions, `SELECT version FROM schema_migrations ORDER BY version DESC LIMIT $1`, steps)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range versions {
+			m, ok := byVersion[v]
+			if !ok {
+				return fmt.Errorf("%w: %d was applied by a newer version", ErrUnknownVersion, v)
+			}
+
+			if err := run(ctx, conn, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return fmt.Errorf("error reverting migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			wl.Infof("reverted migration %d_%s", m.Version, m.Name)
+			reverted++
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status returns every known and applied migration, ordered by version.
+func (mg *Migrator) Status(ctx context.Context, wl wlog.Logger) ([]Status, error) {
+	var statuses []Status
+	err := mg.withLock(ctx, func(conn *sqlx.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		known := make(map[int64]bool, len(mg.migrations))
+		for _, m := range mg.migrations {
+			known[m.Version] = true
+			statuses = append(statuses, Status{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: versions[m.Version].AppliedAt,
+			})
+		}
+
+		for v, row := range versions {
+			if !known[v] {
+				statuses = append(statuses, Status{
+					Version:   v,
+					Name:      row.Name,
+					AppliedAt: row.AppliedAt,
+					Unknown:   true,
+				})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// withLock runs fn on a single connection holding the advisory lock,
+// after making sure the schema_migrations table exists.
+func (mg *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := mg.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lockCtx, cancel := context.WithTimeout(ctx, mg.lockTimeout)
+	defer cancel()
+	if _, err := conn.ExecContext(lockCtx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		if errors.Is(lockCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w after %s", ErrLockTimeout, mg.lockTimeout)
+		}
+		return err
+	}
+	defer func() {
+		// the lock is released with the session anyway, don't let a
+		// cancelled context keep it around on a pooled connection
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+	}()
+
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+type appliedRow struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	AppliedAt null.Time `db:"applied_at"`
+}
+
+// appliedVersions returns the applied migrations by version.
+func appliedVersions(ctx context.Context, conn *sqlx.Conn) (map[int64]appliedRow, error) {
+	var rows []appliedRow
+	if err := conn.SelectContext(ctx, &rows, `SELECT version, name, applied_at FROM schema_migrations`); err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int64]appliedRow, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = row
+	}
+
+	return versions, nil
+}
+
+// run executes the statements of a migration and its bookkeeping query
+// in a single transaction.
+func run(ctx context.Context, conn *sqlx.Conn, statements string, query string, args ...interface{}) error {
+	tx, err := conn.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// without arguments the statements are sent as a simple query,
+	// which allows several of them
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,173 @@
+package migrate_test
+
+import (
+	"context"
+	"on-air/internal/migrate"
+	"on-air/internal/wlog"
+	"on-air/migrations"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"gotest.tools/v3/assert"
+)
+
+func TestLoad(t *testing.T) {
+	file := func(s string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(s)}
+	}
+
+	testData := []struct {
+		name     string
+		fsys     fstest.MapFS
+		expected []migrate.Migration
+		err      error
+	}{
+		{
+			name: "ordered by version",
+			fsys: fstest.MapFS{
+				"10_b.up.sql":   file("up b"),
+				"10_b.down.sql": file("down b"),
+				"2_a.up.sql":    file("up a"),
+				"2_a.down.sql":  file("down a"),
+				"README.md":     file("ignored"),
+			},
+			expected: []migrate.Migration{
+				{Version: 2, Name: "a", Up: "up a", Down: "down a"},
+				{Version: 10, Name: "b", Up: "up b", Down: "down b"},
+			},
+		},
+		{
+			name:     "empty",
+			fsys:     fstest.MapFS{},
+			expected: []migrate.Migration{},
+		},
+		{
+			name: "missing down",
+			fsys: fstest.MapFS{"1_a.up.sql": file("up")},
+			err:  migrate.ErrInvalidMigration,
+		},
+		{
+			name: "same version twice",
+			fsys: fstest.MapFS{
+				"1_a.up.sql":   file("up"),
+				"1_a.down.sql": file("down"),
+				"1_b.up.sql":   file("up"),
+				"1_b.down.sql": file("down"),
+			},
+			err: migrate.ErrInvalidMigration,
+		},
+		{
+			name: "invalid name",
+			fsys: fstest.MapFS{"create_table.sql": file("up")},
+			err:  migrate.ErrInvalidMigration,
+		},
+		{
+			name: "version zero",
+			fsys: fstest.MapFS{
+				"0_a.up.sql":   file("up"),
+				"0_a.down.sql": file("down"),
+			},
+			err: migrate.ErrInvalidMigration,
+		},
+	}
+
+	for _, tc := range testData {
+		got, err := migrate.Load(tc.fsys)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err, tc.name)
+			continue
+		}
+		assert.NilError(t, err, tc.name)
+		assert.DeepEqual(t, got, tc.expected)
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	got, err := migrate.Load(migrations.FS)
+	assert.NilError(t, err)
+	assert.Assert(t, len(got) > 0)
+
+	// versions are sequential so a missing file is noticed
+	for i, m := range got {
+		assert.Equal(t, m.Version, int64(i+1), m.Name)
+	}
+}
+
+// TestMigrations applies and reverts every migration one at a time. It needs
+// a throwaway Postgres database in TEST_DATABASE_URL.
+func TestMigrations(t *testing.T) {
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL isn't set")
+	}
+
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	db, err := sqlx.Open("postgres", dbURL)
+	assert.NilError(t, err)
+	defer db.Close()
+
+	all, err := migrate.Load(migrations.FS)
+	assert.NilError(t, err)
+
+	// start from a clean slate in case a previous run failed halfway
+	cleanup, err := migrate.New(db, all, &migrate.Config{LockTimeout: time.Minute})
+	assert.NilError(t, err)
+	_, err = cleanup.Down(ctx, wl, len(all))
+	assert.NilError(t, err)
+
+	// up and down one migration at a time, then up again to make sure
+	// the down migration fully reverted the up one
+	for i := range all {
+		mg, err := migrate.New(db, all[:i+1], &migrate.Config{LockTimeout: time.Minute})
+		assert.NilError(t, err)
+
+		n, err := mg.Up(ctx, wl)
+		assert.NilError(t, err, all[i].Name)
+		assert.Equal(t, n, 1, all[i].Name)
+
+		n, err = mg.Down(ctx, wl, 1)
+		assert.NilError(t, err, all[i].Name)
+		assert.Equal(t, n, 1, all[i].Name)
+
+		n, err = mg.Up(ctx, wl)
+		assert.NilError(t, err, all[i].Name)
+		assert.Equal(t, n, 1, all[i].Name)
+	}
+
+	mg, err := migrate.New(db, all, &migrate.Config{LockTimeout: time.Minute})
+	assert.NilError(t, err)
+
+	statuses, err := mg.Status(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, len(statuses), len(all))
+	for _, s := range statuses {
+		assert.Assert(t, s.AppliedAt.Valid, s.Name)
+	}
+
+	// nothing left to apply
+	n, err := mg.Up(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	// an older binary can't revert what it doesn't know
+	older, err := migrate.New(db, all[:len(all)-1], &migrate.Config{LockTimeout: time.Minute})
+	assert.NilError(t, err)
+	_, err = older.Down(ctx, wl, 1)
+	assert.ErrorIs(t, err, migrate.ErrUnknownVersion)
+
+	n, err = mg.Down(ctx, wl, len(all))
+	assert.NilError(t, err)
+	assert.Equal(t, n, len(all))
+
+	statuses, err = mg.Status(ctx, wl)
+	assert.NilError(t, err)
+	for _, s := range statuses {
+		assert.Assert(t, !s.AppliedAt.Valid, s.Name)
+	}
+}
@@ -0,0 +1,69 @@
+package pubsub
+
+import (
+	"on-air/internal/entities"
+	"on-air/internal/randid"
+	"time"
+)
+
+const (
+	// CloudEventsVersion is the version of the CloudEvents spec events follow.
+	CloudEventsVersion = "1.0"
+	// CloudEventsContentType is the content type of a structured mode event.
+	CloudEventsContentType = "application/cloudevents+json"
+	// StatusChangedType is the type of the events emitted on every transition.
+	StatusChangedType = "dev.on-air.status.changed"
+
+	eventIDLen = 16
+)
+
+// CloudEvent is a CloudEvents 1.0 event in its JSON format.
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            StatusEvent `json:"data"`
+}
+
+// StatusEvent is the data of a status changed event.
+type StatusEvent struct {
+	IsOnAir   bool       `json:"is_on_air"`
+	Message   string     `json:"message"`
+	Revision  uint64     `json:"revision"`
+	At        time.Time  `json:"at"`
+	LastOnAir *time.Time `json:"last_on_air"`
+}
+
+// NewStatusChanged returns the event of a transition. Its ID is random so
+// it stays unique across restarts, and is kept across publish retries so
+// consumers can deduplicate.
+func NewStatusChanged(source string, onAir entities.OnAirStatus) CloudEvent {
+	at := onAir.LastUpdated.Time.UTC()
+	if !onAir.LastUpdated.Valid {
+		at = time.Now().UTC()
+	}
+
+	data := StatusEvent{
+		IsOnAir:  onAir.IsOnAir,
+		Message:  onAir.Message,
+		Revision: onAir.Revision,
+		At:       at,
+	}
+	if onAir.LastOnAir.Valid {
+		t := onAir.LastOnAir.Time.UTC()
+		data.LastOnAir = &t
+	}
+
+	return CloudEvent{
+		SpecVersion:     CloudEventsVersion,
+		ID:              randid.New(eventIDLen),
+		Source:          source,
+		Type:            StatusChangedType,
+		Time:            at,
+		DataContentType: "application/json",
+		Data:            data,
+	}
+}
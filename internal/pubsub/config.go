@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// GoogleCertsURL serves the keys Google signs push tokens with.
+const GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// DefaultEndpoint is the Pub/Sub API.
+const DefaultEndpoint = "https://pubsub.googleapis.com"
+
+// Config holds the configuration options for Pub/Sub push subscriptions.
+type Config struct {
+	// The audience of the push tokens, as set on the subscription.
+	// Push tokens are not verified when empty.
+	Audience string `env:"PUBSUB_PUSH_AUDIENCE"`
+	// The service account the subscription pushes as
+	ServiceAccount string `env:"PUBSUB_PUSH_SERVICE_ACCOUNT"`
+	// Where the token signing keys are fetched from
+	CertsURL string `env:"PUBSUB_PUSH_CERTS_URL" envDefault:"https://www.googleapis.com/oauth2/v3/certs"`
+	// How long message IDs are remembered to skip redeliveries
+	DedupeTTL time.Duration `env:"PUBSUB_DEDUPE_TTL" envDefault:"24h"`
+}
+
+// VerifyEnabled reports whether push tokens have to be verified.
+func (c *Config) VerifyEnabled() bool {
+	return c.Audience != ""
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.CertsURL, validation.When(c.VerifyEnabled(), validation.Required)),
+		validation.Field(&c.DedupeTTL, validation.Min(time.Minute)),
+	)
+}
+
+// PublishConfig holds the configuration options for publishing status events.
+type PublishConfig struct {
+	// The project of the topic
+	ProjectID string `env:"PUBSUB_PROJECT_ID"`
+	// The topic events are published to. Publishing is disabled when empty.
+	Topic string `env:"PUBSUB_EVENTS_TOPIC"`
+	// The host:port of the Pub/Sub emulator. Requests are sent to the
+	// emulator without credentials when set.
+	EmulatorHost string `env:"PUBSUB_EMULATOR_HOST"`
+	// The Pub/Sub API, when not using the emulator
+	Endpoint string `env:"PUBSUB_ENDPOINT" envDefault:"https://pubsub.googleapis.com"`
+	// The CloudEvents source of the events
+	Source string `env:"PUBSUB_EVENTS_SOURCE" envDefault:"/on-air"`
+	// How often unpublished events are retried
+	RetryInterval time.Duration `env:"PUBSUB_EVENTS_RETRY_INTERVAL" envDefault:"10s"`
+	// How many unpublished events are kept, new events are dropped past it
+	OutboxSize int `env:"PUBSUB_EVENTS_OUTBOX_SIZE" envDefault:"10000"`
+}
+
+// Enabled reports whether a topic has been configured.
+func (c *PublishConfig) Enabled() bool {
+	return c.Topic != ""
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *PublishConfig) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.ProjectID, validation.Required),
+		validation.Field(&c.Topic, validation.Required),
+		validation.Field(&c.Endpoint, validation.When(c.EmulatorHost == "", validation.Required)),
+		validation.Field(&c.Source, validation.Required),
+		validation.Field(&c.RetryInterval, validation.Min(time.Second)),
+		validation.Field(&c.OutboxSize, validation.Min(1)),
+	)
+}
@@ -0,0 +1,60 @@
+package pubsub
+
+import (
+	"sync"
+	"time"
+)
+
+// Deduper remembers the processed message IDs so redeliveries are only
+// acknowledged.
+type Deduper struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	// done maps the processed message IDs to when they expire
+	done     map[string]time.Time
+	inFlight map[string]bool
+}
+
+// NewDeduper returns a Deduper remembering message IDs for ttl.
+func NewDeduper(ttl time.Duration) *Deduper {
+	return &Deduper{
+		ttl:      ttl,
+		done:     make(map[string]time.Time),
+		inFlight: make(map[string]bool),
+	}
+}
+
+// Begin claims a message. It returns false when the message has already been
+// processed and ErrInFlight when another delivery is processing it.
+func (d *Deduper) Begin(id string, now time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for k, exp := range d.done {
+		if now.After(exp) {
+			delete(d.done, k)
+		}
+	}
+
+	if _, ok := d.done[id]; ok {
+		return false, nil
+	}
+	if d.inFlight[id] {
+		return false, ErrInFlight
+	}
+
+	d.inFlight[id] = true
+	return true, nil
+}
+
+// End releases a claimed message, remembering it when it was processed.
+func (d *Deduper) End(id string, processed bool, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inFlight, id)
+	if processed {
+		d.done[id] = now.Add(d.ttl)
+	}
+}
@@ -0,0 +1,11 @@
+package pubsub
+
+import "errors"
+
+var (
+	ErrInvalidEnvelope = errors.New("invalid push envelope")
+	ErrInvalidCommand  = errors.New("invalid command")
+	ErrInvalidToken    = errors.New("invalid push token")
+	ErrInFlight        = errors.New("message is already being processed")
+	ErrOutboxFull      = errors.New("outbox is full")
+)
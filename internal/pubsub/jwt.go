@@ -0,0 +1,192 @@
+package pubsub
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"on-air/pkg/client"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tolerated clock skew when checking the token expiry
+	clockSkew = time.Minute
+	// unknown key IDs trigger a refresh at most this often
+	minCertsRefresh = time.Minute
+)
+
+var googleIssuers = map[string]bool{
+	"accounts.google.com":         true,
+	"https://accounts.google.com": true,
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Aud           string `json:"aud"`
+	Iss           string `json:"iss"`
+	Exp           int64  `json:"exp"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// Verifier checks the OIDC tokens Pub/Sub signs push requests with.
+type Verifier struct {
+	audience       string
+	serviceAccount string
+	certsURL       string
+	client         client.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// NewVerifier returns a Verifier accepting the tokens issued by Google for
+// the audience, and for the service account when not empty.
+func NewVerifier(audience, serviceAccount, certsURL string, opts ...client.Option) *Verifier {
+	return &Verifier{
+		audience:       audience,
+		serviceAccount: serviceAccount,
+		certsURL:       certsURL,
+		client:         client.NewHTTPClient(opts...),
+		keys:           make(map[string]*rsa.PublicKey),
+	}
+}
+
+// Verify checks the signature and claims of a token.
+func (v *Verifier) Verify(ctx context.Context, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "RS256" {
+		return fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	key, err := v.key(ctx, header.Kid, now)
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return err
+	}
+
+	switch {
+	case claims.Aud != v.audience:
+		return fmt.Errorf("%w: unexpected audience %q", ErrInvalidToken, claims.Aud)
+	case !googleIssuers[claims.Iss]:
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Iss)
+	case now.After(time.Unix(claims.Exp, 0).Add(clockSkew)):
+		return fmt.Errorf("%w: expired", ErrInvalidToken)
+	case v.serviceAccount != "" && (claims.Email != v.serviceAccount || !claims.EmailVerified):
+		return fmt.Errorf("%w: unexpected service account %q", ErrInvalidToken, claims.Email)
+	}
+
+	return nil
+}
+
+// key returns the signing key, refreshing the keys when it's unknown since
+// Google rotates them.
+func (v *Verifier) key(ctx context.Context, kid string, now time.Time) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	if now.Sub(v.fetched) >= minCertsRefresh {
+		if err := v.refresh(ctx); err != nil {
+			return nil, err
+		}
+		v.fetched = now
+	}
+
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+func (v *Verifier) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.certsURL, nil)
+	if err != nil {
+		return err
+	}
+
+	var set jwks
+	if err := v.client.DoJSON(req, &set); err != nil {
+		return fmt.Errorf("error fetching token signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	v.keys = keys
+
+	return nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Outbox holds the events until they've been published.
+type Outbox interface {
+	// Add stores an event to publish.
+	Add(ctx context.Context, ev CloudEvent) error
+	// Pending returns up to limit unpublished events, oldest first.
+	Pending(ctx context.Context, limit int) ([]CloudEvent, error)
+	// Ack removes published events.
+	Ack(ctx context.Context, ids []string) error
+}
+
+// MemoryOutbox is an Outbox kept in memory. It survives publish failures but
+// not restarts: the events still pending when the process exits are lost.
+// Once full, new events are dropped with ErrOutboxFull until the pending ones
+// are published.
+type MemoryOutbox struct {
+	size int
+
+	mu     sync.Mutex
+	events []CloudEvent
+}
+
+// NewMemoryOutbox returns an Outbox holding up to size events.
+func NewMemoryOutbox(size int) *MemoryOutbox {
+	return &MemoryOutbox{size: size}
+}
+
+func (o *MemoryOutbox) Add(ctx context.Context, ev CloudEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.events) >= o.size {
+		return ErrOutboxFull
+	}
+	o.events = append(o.events, ev)
+	return nil
+}
+
+func (o *MemoryOutbox) Pending(ctx context.Context, limit int) ([]CloudEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if limit > len(o.events) {
+		limit = len(o.events)
+	}
+	return append([]CloudEvent(nil), o.events[:limit]...), nil
+}
+
+func (o *MemoryOutbox) Ack(ctx context.Context, ids []string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	acked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		acked[id] = true
+	}
+
+	kept := o.events[:0]
+	for _, ev := range o.events {
+		if !acked[ev.ID] {
+			kept = append(kept, ev)
+		}
+	}
+	o.events = kept
+	return nil
+}
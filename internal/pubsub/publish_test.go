@@ -0,0 +1,168 @@
+package pubsub_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"on-air/internal/entities"
+	"on-air/internal/pubsub"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakePubSub stands in for the Pub/Sub emulator's publish endpoint.
+type fakePubSub struct {
+	mu       sync.Mutex
+	failing  bool
+	messages []struct {
+		Data       []byte            `json:"data"`
+		Attributes map[string]string `json:"attributes"`
+	}
+}
+
+func (f *fakePubSub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path != "/v1/projects/test/topics/on-air:publish" || r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if f.failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Messages []struct {
+			Data       []byte            `json:"data"`
+			Attributes map[string]string `json:"attributes"`
+		} `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ids := []string{}
+	for _, m := range req.Messages {
+		ids = append(ids, fmt.Sprint(len(f.messages)))
+		f.messages = append(f.messages, m)
+	}
+	json.NewEncoder(w).Encode(map[string][]string{"messageIds": ids})
+}
+
+func (f *fakePubSub) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func TestRelay(t *testing.T) {
+	fake := &fakePubSub{failing: true}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	cfg := &pubsub.PublishConfig{
+		ProjectID:     "test",
+		Topic:         "on-air",
+		EmulatorHost:  strings.TrimPrefix(srv.URL, "http://"),
+		Source:        "/on-air",
+		RetryInterval: time.Second,
+		OutboxSize:    10,
+	}
+	outbox := pubsub.NewMemoryOutbox(cfg.OutboxSize)
+	relay, err := pubsub.NewRelay(cfg, outbox, pubsub.NewPublisher(cfg))
+	assert.NilError(t, err)
+
+	onAirService, err := onair.New(onair.WithListener(relay.Record))
+	assert.NilError(t, err)
+
+	_, err = onAirService.ToggleOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	_, err = onAirService.ToggleOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+
+	// failed publishes keep the events in the outbox
+	assert.Assert(t, relay.Flush(ctx, wl) != nil)
+	pending, err := outbox.Pending(ctx, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 2)
+
+	fake.setFailing(false)
+	assert.NilError(t, relay.Flush(ctx, wl))
+	pending, err = outbox.Pending(ctx, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 0)
+
+	// published events aren't published again
+	assert.NilError(t, relay.Flush(ctx, wl))
+	assert.Equal(t, len(fake.messages), 2)
+
+	for i, m := range fake.messages {
+		assert.Equal(t, m.Attributes["content-type"], pubsub.CloudEventsContentType)
+
+		var ev map[string]interface{}
+		assert.NilError(t, json.Unmarshal(m.Data, &ev))
+		assert.Equal(t, ev["specversion"], "1.0")
+		assert.Equal(t, ev["type"], pubsub.StatusChangedType)
+		assert.Equal(t, ev["source"], "/on-air")
+		assert.Equal(t, ev["datacontenttype"], "application/json")
+		assert.Equal(t, ev["id"], m.Attributes["ce-id"])
+		_, err := time.Parse(time.RFC3339, ev["time"].(string))
+		assert.NilError(t, err)
+
+		data := ev["data"].(map[string]interface{})
+		assert.Equal(t, data["is_on_air"], i == 0)
+		assert.Equal(t, data["revision"], float64(i+2))
+	}
+}
+
+func TestRelayFlushesOnShutdown(t *testing.T) {
+	fake := &fakePubSub{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	wl := wlog.NewNopLogger()
+	cfg := &pubsub.PublishConfig{
+		ProjectID:     "test",
+		Topic:         "on-air",
+		EmulatorHost:  strings.TrimPrefix(srv.URL, "http://"),
+		Source:        "/on-air",
+		RetryInterval: time.Hour,
+		OutboxSize:    10,
+	}
+	relay, err := pubsub.NewRelay(cfg, pubsub.NewMemoryOutbox(cfg.OutboxSize), pubsub.NewPublisher(cfg))
+	assert.NilError(t, err)
+
+	// the event is recorded as the service shuts down, only the final flush
+	// can publish it
+	ctx, cancel := context.WithCancel(context.Background())
+	relay.Record(ctx, wl, entities.OnAirStatus{IsOnAir: true, Revision: 2})
+	cancel()
+
+	<-relay.Start(ctx, wl)
+	assert.Equal(t, len(fake.messages), 1)
+}
+
+func TestMemoryOutboxIsBounded(t *testing.T) {
+	ctx := context.Background()
+	outbox := pubsub.NewMemoryOutbox(1)
+
+	assert.NilError(t, outbox.Add(ctx, pubsub.CloudEvent{ID: "a"}))
+	assert.ErrorIs(t, outbox.Add(ctx, pubsub.CloudEvent{ID: "b"}), pubsub.ErrOutboxFull)
+
+	assert.NilError(t, outbox.Ack(ctx, []string{"a"}))
+	assert.NilError(t, outbox.Add(ctx, pubsub.CloudEvent{ID: "b"}))
+}
@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"on-air/pkg/client"
+)
+
+type publishRequest struct {
+	Messages []publishMessage `json:"messages"`
+}
+
+type publishMessage struct {
+	Data       []byte            `json:"data"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type publishResponse struct {
+	MessageIDs []string `json:"messageIds"`
+}
+
+// Publisher publishes messages to a topic through the Pub/Sub REST API.
+type Publisher struct {
+	url    string
+	client client.Client
+	// tokens is nil when publishing to the emulator
+	tokens client.TokenSource
+}
+
+// NewPublisher returns a Publisher for the configured topic.
+func NewPublisher(cfg *PublishConfig, opts ...client.Option) *Publisher {
+	p := &Publisher{client: client.NewHTTPClient(opts...)}
+
+	endpoint := cfg.Endpoint
+	if cfg.EmulatorHost != "" {
+		endpoint = "http://" + cfg.EmulatorHost
+	} else {
+		p.tokens = client.NewMetadataTokenSource(opts...)
+	}
+	p.url = fmt.Sprintf("%s/v1/projects/%s/topics/%s:publish", endpoint, cfg.ProjectID, cfg.Topic)
+
+	return p
+}
+
+// Publish publishes the events in structured mode: the message data is the
+// JSON event.
+func (p *Publisher) Publish(ctx context.Context, events []CloudEvent) error {
+	body := publishRequest{Messages: make([]publishMessage, 0, len(events))}
+	for _, ev := range events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		body.Messages = append(body.Messages, publishMessage{
+			Data: data,
+			Attributes: map[string]string{
+				"content-type": CloudEventsContentType,
+				"ce-type":      ev.Type,
+				"ce-id":        ev.ID,
+			},
+		})
+	}
+
+	req, err := client.NewJSONRequest(ctx, http.MethodPost, p.url, body)
+	if err != nil {
+		return err
+	}
+
+	if p.tokens != nil {
+		token, err := p.tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	var resp publishResponse
+	if err := p.client.DoJSON(req, &resp); err != nil {
+		return fmt.Errorf("error publishing events: %w", err)
+	}
+	if len(resp.MessageIDs) != len(events) {
+		return fmt.Errorf("error publishing events: %d of %d published", len(resp.MessageIDs), len(events))
+	}
+
+	return nil
+}
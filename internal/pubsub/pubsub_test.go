@@ -0,0 +1,239 @@
+package pubsub_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"on-air/internal/pubsub"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseCommand(t *testing.T) {
+	on := true
+
+	testData := []struct {
+		name     string
+		msg      pubsub.Message
+		expected pubsub.Command
+		err      error
+	}{
+		{
+			"set from data",
+			pubsub.Message{Data: []byte(`{"action":"set","is_on_air":true,"message":"live"}`)},
+			pubsub.Command{Action: pubsub.ActionSet, IsOnAir: &on, Message: "live"},
+			nil,
+		},
+		{
+			"toggle from attributes",
+			pubsub.Message{Attributes: map[string]string{"action": "TOGGLE"}},
+			pubsub.Command{Action: pubsub.ActionToggle},
+			nil,
+		},
+		{
+			"data overrides attributes",
+			pubsub.Message{
+				Attributes: map[string]string{"action": "set", "is_on_air": "false", "message": "attr"},
+				Data:       []byte(`{"is_on_air":true}`),
+			},
+			pubsub.Command{Action: pubsub.ActionSet, IsOnAir: &on, Message: "attr"},
+			nil,
+		},
+		{
+			"set without status",
+			pubsub.Message{Data: []byte(`{"action":"set"}`)},
+			pubsub.Command{},
+			pubsub.ErrInvalidCommand,
+		},
+		{
+			"unknown action",
+			pubsub.Message{Data: []byte(`{"action":"explode"}`)},
+			pubsub.Command{},
+			pubsub.ErrInvalidCommand,
+		},
+		{
+			"invalid data",
+			pubsub.Message{Data: []byte(`not json`)},
+			pubsub.Command{},
+			pubsub.ErrInvalidCommand,
+		},
+		{
+			"invalid attribute",
+			pubsub.Message{Attributes: map[string]string{"action": "set", "is_on_air": "maybe"}},
+			pubsub.Command{},
+			pubsub.ErrInvalidCommand,
+		},
+	}
+
+	for _, tc := range testData {
+		got, err := pubsub.ParseCommand(tc.msg)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err, tc.name)
+			continue
+		}
+		assert.NilError(t, err, tc.name)
+		assert.DeepEqual(t, got, tc.expected)
+	}
+}
+
+func TestDecodePush(t *testing.T) {
+	body := `{"message":{"data":"eyJhY3Rpb24iOiJ0b2dnbGUifQ==","attributes":{"k":"v"},"messageId":"42"},"subscription":"projects/p/subscriptions/s"}`
+
+	msg, err := pubsub.DecodePush(strings.NewReader(body))
+	assert.NilError(t, err)
+	assert.Equal(t, string(msg.Data), `{"action":"toggle"}`)
+	assert.Equal(t, msg.MessageID, "42")
+	assert.Equal(t, msg.Attributes["k"], "v")
+
+	_, err = pubsub.DecodePush(strings.NewReader(`{"message":{"data":"e30="}}`))
+	assert.ErrorIs(t, err, pubsub.ErrInvalidEnvelope)
+}
+
+func TestReceiveIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	onAirService, err := onair.New()
+	assert.NilError(t, err)
+
+	rc, err := pubsub.NewReceiver(&pubsub.Config{DedupeTTL: time.Hour}, onAirService)
+	assert.NilError(t, err)
+
+	toggle := pubsub.Message{MessageID: "1", Data: []byte(`{"action":"toggle"}`)}
+	for i := 0; i < 3; i++ {
+		assert.NilError(t, rc.Receive(ctx, wl, toggle))
+	}
+
+	status, err := onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, status.IsOnAir, true)
+
+	assert.NilError(t, rc.Receive(ctx, wl, pubsub.Message{MessageID: "2", Data: []byte(`{"action":"toggle"}`)}))
+	status, err = onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, status.IsOnAir, false)
+}
+
+func TestDeduper(t *testing.T) {
+	now := time.Now()
+	d := pubsub.NewDeduper(time.Minute)
+
+	first, err := d.Begin("a", now)
+	assert.NilError(t, err)
+	assert.Assert(t, first)
+
+	_, err = d.Begin("a", now)
+	assert.ErrorIs(t, err, pubsub.ErrInFlight)
+
+	// failed messages can be retried
+	d.End("a", false, now)
+	first, err = d.Begin("a", now)
+	assert.NilError(t, err)
+	assert.Assert(t, first)
+
+	d.End("a", true, now)
+	first, err = d.Begin("a", now)
+	assert.NilError(t, err)
+	assert.Assert(t, !first)
+
+	// and are forgotten after the ttl
+	first, err = d.Begin("a", now.Add(2*time.Minute))
+	assert.NilError(t, err)
+	assert.Assert(t, first)
+}
+
+func TestVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+
+	certs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer certs.Close()
+
+	now := time.Now()
+	valid := map[string]interface{}{
+		"aud":            "https://on-air.example.com/pubsub/push",
+		"iss":            "https://accounts.google.com",
+		"exp":            now.Add(time.Hour).Unix(),
+		"email":          "push@project.iam.gserviceaccount.com",
+		"email_verified": true,
+	}
+	with := func(k string, v interface{}) map[string]interface{} {
+		c := map[string]interface{}{}
+		for kk, vv := range valid {
+			c[kk] = vv
+		}
+		c[k] = v
+		return c
+	}
+
+	testData := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", sign(t, key, "k1", valid), true},
+		{"wrong audience", sign(t, key, "k1", with("aud", "https://elsewhere")), false},
+		{"wrong issuer", sign(t, key, "k1", with("iss", "https://evil.example.com")), false},
+		{"expired", sign(t, key, "k1", with("exp", now.Add(-time.Hour).Unix())), false},
+		{"wrong service account", sign(t, key, "k1", with("email", "other@project.iam.gserviceaccount.com")), false},
+		{"unverified email", sign(t, key, "k1", with("email_verified", false)), false},
+		{"wrong key", sign(t, other, "k1", valid), false},
+		{"unknown key", sign(t, key, "k2", valid), false},
+		{"malformed", "not.a-token", false},
+	}
+
+	v := pubsub.NewVerifier(
+		"https://on-air.example.com/pubsub/push",
+		"push@project.iam.gserviceaccount.com",
+		certs.URL,
+	)
+
+	for _, tc := range testData {
+		err := v.Verify(context.Background(), tc.token, now)
+		if tc.valid {
+			assert.NilError(t, err, tc.name)
+		} else {
+			assert.ErrorIs(t, err, pubsub.ErrInvalidToken, tc.name)
+		}
+	}
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	assert.NilError(t, err)
+	payload, err := json.Marshal(claims)
+	assert.NilError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.NilError(t, err)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
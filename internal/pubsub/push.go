@@ -0,0 +1,85 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Command actions.
+const (
+	ActionSet    = "set"
+	ActionToggle = "toggle"
+)
+
+// PushEnvelope is the body of a push request.
+type PushEnvelope struct {
+	Message      Message `json:"message"`
+	Subscription string  `json:"subscription"`
+}
+
+// Message is a Pub/Sub message. Data is base64 encoded in the envelope.
+type Message struct {
+	Data       []byte            `json:"data"`
+	Attributes map[string]string `json:"attributes"`
+	MessageID  string            `json:"messageId"`
+}
+
+// Command changes the status.
+type Command struct {
+	Action  string `json:"action"`
+	IsOnAir *bool  `json:"is_on_air"`
+	Message string `json:"message"`
+}
+
+// DecodePush reads a push envelope.
+func DecodePush(r io.Reader) (Message, error) {
+	var env PushEnvelope
+	if err := json.NewDecoder(r).Decode(&env); err != nil {
+		return Message{}, fmt.Errorf("%w: %s", ErrInvalidEnvelope, err)
+	}
+	if env.Message.MessageID == "" {
+		return Message{}, fmt.Errorf("%w: missing message ID", ErrInvalidEnvelope)
+	}
+	return env.Message, nil
+}
+
+// ParseCommand reads the command from the message attributes (action,
+// is_on_air and message) and its JSON data, the data taking precedence.
+func ParseCommand(msg Message) (Command, error) {
+	var cmd Command
+
+	if a, ok := msg.Attributes["action"]; ok {
+		cmd.Action = a
+	}
+	if v, ok := msg.Attributes["is_on_air"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Command{}, fmt.Errorf("%w: is_on_air attribute must be a boolean", ErrInvalidCommand)
+		}
+		cmd.IsOnAir = &b
+	}
+	if m, ok := msg.Attributes["message"]; ok {
+		cmd.Message = m
+	}
+
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+			return Command{}, fmt.Errorf("%w: %s", ErrInvalidCommand, err)
+		}
+	}
+
+	cmd.Action = strings.ToLower(cmd.Action)
+	switch {
+	case cmd.Action == ActionToggle:
+	case cmd.Action == ActionSet && cmd.IsOnAir == nil:
+		return Command{}, fmt.Errorf("%w: set requires is_on_air", ErrInvalidCommand)
+	case cmd.Action == ActionSet:
+	default:
+		return Command{}, fmt.Errorf("%w: unknown action %q, expected set or toggle", ErrInvalidCommand, cmd.Action)
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,99 @@
+// Package pubsub applies the status commands pushed by Pub/Sub subscriptions.
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"on-air/pkg/client"
+	"strings"
+	"time"
+)
+
+const bearerPrefix = "Bearer "
+
+// Receiver verifies pushed messages and applies their commands once.
+type Receiver struct {
+	verifier     *Verifier
+	seen         *Deduper
+	onAirService onair.SVC
+}
+
+// NewReceiver creates a Receiver from the given configuration.
+func NewReceiver(cfg *Config, onAirService onair.SVC, opts ...client.Option) (*Receiver, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	rc := &Receiver{
+		seen:         NewDeduper(cfg.DedupeTTL),
+		onAirService: onAirService,
+	}
+	if cfg.VerifyEnabled() {
+		rc.verifier = NewVerifier(cfg.Audience, cfg.ServiceAccount, cfg.CertsURL, opts...)
+	}
+
+	return rc, nil
+}
+
+// VerifyEnabled reports whether push tokens are verified.
+func (rc *Receiver) VerifyEnabled() bool {
+	return rc.verifier != nil
+}
+
+// Authenticate verifies the push token of the Authorization header. Every
+// request is accepted when verification is disabled.
+func (rc *Receiver) Authenticate(ctx context.Context, wl wlog.Logger, authorization string) error {
+	if rc.verifier == nil {
+		return nil
+	}
+	if !strings.HasPrefix(authorization, bearerPrefix) {
+		return fmt.Errorf("%w: missing bearer token", ErrInvalidToken)
+	}
+	return rc.verifier.Verify(ctx, strings.TrimPrefix(authorization, bearerPrefix), time.Now())
+}
+
+// Receive applies the command of a message unless it has already been
+// applied. ErrInvalidCommand means retrying won't help.
+func (rc *Receiver) Receive(ctx context.Context, wl wlog.Logger, msg Message) error {
+	cmd, err := ParseCommand(msg)
+	if err != nil {
+		return err
+	}
+
+	first, err := rc.seen.Begin(msg.MessageID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !first {
+		wl.Debugf("skipping redelivered message %s", msg.MessageID)
+		return nil
+	}
+
+	err = rc.apply(ctx, wl, cmd)
+	rc.seen.End(msg.MessageID, err == nil, time.Now())
+	if err != nil {
+		return fmt.Errorf("error applying message %s: %w", msg.MessageID, err)
+	}
+
+	wl.Infof("applied %s command from message %s", cmd.Action, msg.MessageID)
+	return nil
+}
+
+func (rc *Receiver) apply(ctx context.Context, wl wlog.Logger, cmd Command) error {
+	ctx = onair.WithSource(ctx, entities.SourcePubSub)
+
+	var err error
+	switch cmd.Action {
+	case ActionToggle:
+		_, err = rc.onAirService.ToggleOnAirStatus(ctx, wl)
+	case ActionSet:
+		_, err = rc.onAirService.SetOnAirStatus(ctx, wl, entities.OnAirStatus{
+			IsOnAir: *cmd.IsOnAir,
+			Message: cmd.Message,
+		})
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"time"
+)
+
+// publishBatch is how many events are published per request.
+const publishBatch = 100
+
+// EventPublisher publishes events to a topic.
+type EventPublisher interface {
+	Publish(ctx context.Context, events []CloudEvent) error
+}
+
+// Relay records every status change in an outbox and publishes the pending
+// events until they're acknowledged by Pub/Sub, so an event is delivered at
+// least once even when publishing fails.
+type Relay struct {
+	source    string
+	outbox    Outbox
+	publisher EventPublisher
+	interval  time.Duration
+	// wake asks the relay to publish right away
+	wake chan struct{}
+}
+
+// NewRelay creates a Relay publishing the events of the outbox.
+func NewRelay(cfg *PublishConfig, outbox Outbox, publisher EventPublisher) (*Relay, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Relay{
+		source:    cfg.Source,
+		outbox:    outbox,
+		publisher: publisher,
+		interval:  cfg.RetryInterval,
+		wake:      make(chan struct{}, 1),
+	}, nil
+}
+
+// Record adds the transition to the outbox. It satisfies onair.Listener.
+func (rl *Relay) Record(ctx context.Context, wl wlog.Logger, onAir entities.OnAirStatus) {
+	ev := NewStatusChanged(rl.source, onAir)
+	if err := rl.outbox.Add(ctx, ev); err != nil {
+		wl.Error(fmt.Errorf("error adding event %s to the outbox: %w", ev.ID, err))
+		return
+	}
+
+	select {
+	case rl.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start publishes the pending events whenever a transition is recorded and
+// retries every interval until the context is done. The outbox is flushed
+// one last time on the way out, the returned channel is closed once it's
+// done.
+func (rl *Relay) Start(ctx context.Context, wl wlog.Logger) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(rl.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.Background(), rl.interval)
+				defer cancel()
+				if err := rl.Flush(flushCtx, wl); err != nil {
+					wl.Error(fmt.Errorf("error flushing the outbox: %w", err))
+				}
+				return
+			case <-rl.wake:
+			case <-ticker.C:
+			}
+
+			if err := rl.Flush(ctx, wl); err != nil {
+				wl.Error(fmt.Errorf("error publishing events, retrying in %s: %w", rl.interval, err))
+			}
+		}
+	}()
+
+	return done
+}
+
+// Flush publishes the pending events batch by batch. Events are only removed
+// from the outbox once published.
+func (rl *Relay) Flush(ctx context.Context, wl wlog.Logger) error {
+	for {
+		events, err := rl.outbox.Pending(ctx, publishBatch)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		if err := rl.publisher.Publish(ctx, events); err != nil {
+			return err
+		}
+
+		ids := make([]string, len(events))
+		for i, ev := range events {
+			ids[i] = ev.ID
+		}
+		if err := rl.outbox.Ack(ctx, ids); err != nil {
+			return err
+		}
+
+		wl.Debugf("published %d events", len(events))
+	}
+}
@@ -0,0 +1,17 @@
+// Package randid generates random identifiers.
+package randid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// New returns size random bytes, hex encoded.
+func New(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
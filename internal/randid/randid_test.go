@@ -0,0 +1,14 @@
+package randid_test
+
+import (
+	"on-air/internal/randid"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNew(t *testing.T) {
+	a := randid.New(8)
+	assert.Equal(t, len(a), 16)
+	assert.Assert(t, a != randid.New(8))
+}
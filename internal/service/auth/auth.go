@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (as *authService) Enabled() bool {
+	return len(as.keys) > 0
+}
+
+func (as *authService) Authenticate(
+	ctx context.Context,
+	wl wlog.Logger,
+	apiKey string,
+) (entities.User, error) {
+	// keys are looked up by hash so the comparison doesn't leak timing
+	// information about the stored keys
+	user, ok := as.keys[sha256.Sum256([]byte(apiKey))]
+	if !ok || apiKey == "" {
+		return entities.User{}, ErrInvalidAPIKey
+	}
+
+	wl.Debugf("authenticated user %s", user.ID)
+
+	return user, nil
+}
+
+func (as *authService) NewSession(
+	ctx context.Context,
+	wl wlog.Logger,
+	user entities.User,
+) (string, time.Time, error) {
+	expires := time.Now().Add(as.sessionTTL).Truncate(time.Second)
+	payload := base64.RawURLEncoding.EncodeToString(
+		[]byte(user.ID + "|" + strconv.FormatInt(expires.Unix(), 10)))
+
+	wl.Debugf("creating session for user %s", user.ID)
+
+	return payload + "." + as.sign(payload), expires, nil
+}
+
+func (as *authService) VerifySession(
+	ctx context.Context,
+	wl wlog.Logger,
+	token string,
+) (entities.User, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(as.sign(payload))) {
+		return entities.User{}, ErrInvalidSession
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return entities.User{}, ErrInvalidSession
+	}
+
+	id, exp, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return entities.User{}, ErrInvalidSession
+	}
+
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return entities.User{}, ErrInvalidSession
+	}
+	if time.Now().After(time.Unix(expUnix, 0)) {
+		return entities.User{}, ErrSessionExpired
+	}
+
+	// sessions of users whose key has been removed are no longer valid
+	user, ok := as.users[id]
+	if !ok {
+		return entities.User{}, ErrInvalidSession
+	}
+
+	return user, nil
+}
+
+// feed tokens are signed with a prefix so a session token can't be used as
+// a feed token and the other way around
+const feedPrefix = "feed:"
+
+func (as *authService) FeedEnabled() bool {
+	return as.feedEnabled
+}
+
+func (as *authService) NewFeedToken(
+	ctx context.Context,
+	wl wlog.Logger,
+	user entities.User,
+) (string, error) {
+	if !as.feedEnabled {
+		return "", ErrFeedDisabled
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(user.ID))
+
+	wl.Debugf("creating feed token for user %s", user.ID)
+
+	return payload + "." + as.signFeed(payload, user.ID), nil
+}
+
+func (as *authService) VerifyFeedToken(
+	ctx context.Context,
+	wl wlog.Logger,
+	token string,
+) (entities.User, error) {
+	if !as.feedEnabled {
+		return entities.User{}, ErrFeedDisabled
+	}
+
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return entities.User{}, ErrInvalidFeed
+	}
+
+	id, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return entities.User{}, ErrInvalidFeed
+	}
+
+	// feed tokens of users whose key has been changed or removed are no
+	// longer valid
+	user, ok := as.users[string(id)]
+	if !ok || !hmac.Equal([]byte(sig), []byte(as.signFeed(payload, user.ID))) {
+		return entities.User{}, ErrInvalidFeed
+	}
+
+	return user, nil
+}
+
+// signFeed signs the feed token payload along with the API keys of the user.
+func (as *authService) signFeed(payload, userID string) string {
+	return as.sign(feedPrefix + payload + "." + as.feedKeys[userID])
+}
+
+func (as *authService) sign(payload string) string {
+	mac := hmac.New(sha256.New, as.sessionSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
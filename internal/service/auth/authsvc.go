@@ -0,0 +1,97 @@
+// Package auth authenticates API callers with API keys and signed session
+// cookies, and authorizes them through their role and channel grants.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"sort"
+	"time"
+)
+
+const sessionSecretLen = 32
+
+type SVC interface {
+	// Enabled reports whether callers have to authenticate.
+	Enabled() bool
+	// Authenticate returns the user owning the API key.
+	Authenticate(ctx context.Context, wl wlog.Logger, apiKey string) (entities.User, error)
+	// NewSession returns a signed session token for the user and its expiry.
+	NewSession(ctx context.Context, wl wlog.Logger, user entities.User) (string, time.Time, error)
+	// VerifySession returns the user the session token was issued to.
+	VerifySession(ctx context.Context, wl wlog.Logger, token string) (entities.User, error)
+	// FeedEnabled reports whether the calendar feed is served.
+	FeedEnabled() bool
+	// NewFeedToken returns a token that doesn't expire, for clients like
+	// calendar apps that can only pass credentials in the URL. Changing the
+	// API keys of the user revokes it.
+	NewFeedToken(ctx context.Context, wl wlog.Logger, user entities.User) (string, error)
+	// VerifyFeedToken returns the user the feed token was issued to.
+	VerifyFeedToken(ctx context.Context, wl wlog.Logger, token string) (entities.User, error)
+	// Authorize returns ErrForbidden unless the user can perform the action
+	// on the channel. The channel is ignored by actions that don't apply to one.
+	Authorize(ctx context.Context, wl wlog.Logger, userID string, action entities.Action, channel string) error
+	// Permissions returns the effective permissions of the user.
+	Permissions(ctx context.Context, wl wlog.Logger, userID string) (entities.Permissions, error)
+}
+
+type authService struct {
+	// keys maps the sha256 of an API key to its user
+	keys  map[[sha256.Size]byte]entities.User
+	users map[string]entities.User
+	// feedKeys maps a user to the digest of their API keys, signed along
+	// with their feed tokens
+	feedKeys      map[string]string
+	feedEnabled   bool
+	sessionSecret []byte
+	sessionTTL    time.Duration
+}
+
+func New(cfg *Config) (SVC, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	as := &authService{
+		keys:          make(map[[sha256.Size]byte]entities.User),
+		users:         make(map[string]entities.User),
+		feedKeys:      make(map[string]string),
+		feedEnabled:   cfg.CalendarFeed,
+		sessionSecret: []byte(cfg.SessionSecret),
+		sessionTTL:    cfg.SessionTTL,
+	}
+
+	userKeys := make(map[string][]string)
+	for _, entry := range cfg.APIKeys {
+		user, key, err := parseAPIKey(entry)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256([]byte(key))
+		as.keys[hash] = user
+		as.users[user.ID] = user
+		userKeys[user.ID] = append(userKeys[user.ID], string(hash[:]))
+	}
+
+	for id, hashes := range userKeys {
+		sort.Strings(hashes)
+		digest := sha256.New()
+		for _, h := range hashes {
+			digest.Write([]byte(h))
+		}
+		as.feedKeys[id] = string(digest.Sum(nil))
+	}
+
+	if len(as.sessionSecret) == 0 {
+		as.sessionSecret = make([]byte, sessionSecretLen)
+		if _, err := rand.Read(as.sessionSecret); err != nil {
+			return nil, fmt.Errorf("error generating session secret: %w", err)
+		}
+	}
+
+	return as, nil
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"fmt"
+	"on-air/internal/entities"
+	"reflect"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// DefaultSessionTTL is how long a dashboard session lasts by default.
+const DefaultSessionTTL = 7 * 24 * time.Hour
+
+// Config holds the configuration options for authentication.
+type Config struct {
+	// API keys as user:key[:role[:channel|channel]] entries, see parseAPIKey.
+	// Authentication is disabled when empty.
+	APIKeys []string `env:"AUTH_API_KEYS" envSeparator:","`
+	// The secret used to sign session cookies and feed tokens. A random
+	// secret is generated when empty, which invalidates sessions on restart.
+	SessionSecret string `env:"SESSION_SECRET"`
+	// Serve the calendar feed. Its tokens don't expire, so SessionSecret is
+	// required when authentication is enabled.
+	CalendarFeed bool `env:"CALENDAR_FEED"`
+	// How long a dashboard session lasts
+	SessionTTL time.Duration `env:"SESSION_TTL" envDefault:"168h"`
+}
+
+// Enabled reports whether any API key has been configured.
+func (c *Config) Enabled() bool {
+	return len(c.APIKeys) > 0
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.APIKeys, validation.Each(validation.By(validateAPIKey)), validation.By(validateUsers)),
+		validation.Field(&c.SessionTTL, validation.Min(time.Minute)),
+		validation.Field(&c.SessionSecret, validation.When(c.Enabled() && c.CalendarFeed,
+			validation.Required.Error("is required by the calendar feed"))),
+	)
+}
+
+func validateAPIKey(value interface{}) error {
+	s, _ := value.(string)
+	_, _, err := parseAPIKey(s)
+	return err
+}
+
+// validateUsers makes sure the keys of a user all grant the same permissions.
+func validateUsers(value interface{}) error {
+	keys, _ := value.([]string)
+	users := make(map[string]entities.User, len(keys))
+	for _, s := range keys {
+		user, _, err := parseAPIKey(s)
+		if err != nil {
+			// reported by validateAPIKey
+			continue
+		}
+		if prev, ok := users[user.ID]; ok && !reflect.DeepEqual(prev, user) {
+			return fmt.Errorf("the keys of %s must have the same role and channels", user.ID)
+		}
+		users[user.ID] = user
+	}
+	return nil
+}
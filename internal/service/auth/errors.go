@@ -0,0 +1,12 @@
+package auth
+
+import "errors"
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrInvalidSession = errors.New("invalid session")
+	ErrSessionExpired = errors.New("session expired")
+	ErrInvalidFeed    = errors.New("invalid feed token")
+	ErrFeedDisabled   = errors.New("calendar feed disabled")
+	ErrForbidden      = errors.New("forbidden")
+)
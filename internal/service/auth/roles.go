@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"slices"
+	"strings"
+)
+
+// roleActions are the actions of each role, in the order they're reported.
+var roleActions = map[entities.Role][]entities.Action{
+	entities.RoleViewer: {
+		entities.ActionRead,
+	},
+	entities.RoleOperator: {
+		entities.ActionRead,
+		entities.ActionSetStatus,
+		entities.ActionEditSessions,
+	},
+	entities.RoleAdmin: {
+		entities.ActionRead,
+		entities.ActionSetStatus,
+		entities.ActionEditSessions,
+		entities.ActionManageSchedules,
+	},
+}
+
+// channelActions are the actions applying to a single channel, which can
+// be limited by the channels granted to a user.
+var channelActions = []entities.Action{
+	entities.ActionSetStatus,
+	entities.ActionEditSessions,
+}
+
+func (as *authService) Authorize(
+	ctx context.Context,
+	wl wlog.Logger,
+	userID string,
+	action entities.Action,
+	channel string,
+) error {
+	if !as.Enabled() {
+		return nil
+	}
+
+	user, ok := as.users[userID]
+	if !ok {
+		return fmt.Errorf("%w: unknown user %q", ErrForbidden, userID)
+	}
+
+	if !allowed(user, action, channel) {
+		return fmt.Errorf("%w: %s can't %s on channel %q", ErrForbidden, user.ID, action, channel)
+	}
+
+	return nil
+}
+
+func (as *authService) Permissions(
+	ctx context.Context,
+	wl wlog.Logger,
+	userID string,
+) (entities.Permissions, error) {
+	// everyone is an admin without authentication
+	if !as.Enabled() {
+		return entities.Permissions{
+			Role:    entities.RoleAdmin,
+			Actions: roleActions[entities.RoleAdmin],
+		}, nil
+	}
+
+	user, ok := as.users[userID]
+	if !ok {
+		return entities.Permissions{}, fmt.Errorf("%w: unknown user %q", ErrForbidden, userID)
+	}
+
+	perms := entities.Permissions{
+		UserID: user.ID,
+		Role:   user.Role,
+	}
+
+	if len(user.Channels) == 0 {
+		perms.Actions = roleActions[user.Role]
+		return perms, nil
+	}
+
+	var scoped []entities.Action
+	for _, a := range roleActions[user.Role] {
+		if slices.Contains(channelActions, a) {
+			scoped = append(scoped, a)
+		} else {
+			perms.Actions = append(perms.Actions, a)
+		}
+	}
+
+	if len(scoped) > 0 {
+		perms.Channels = make(map[string][]entities.Action, len(user.Channels))
+		for _, c := range user.Channels {
+			perms.Channels[c] = scoped
+		}
+	}
+
+	return perms, nil
+}
+
+// allowed reports whether the user can perform the action on the channel.
+func allowed(user entities.User, action entities.Action, channel string) bool {
+	if !slices.Contains(roleActions[user.Role], action) {
+		return false
+	}
+
+	if len(user.Channels) == 0 || !slices.Contains(channelActions, action) {
+		return true
+	}
+
+	return slices.Contains(user.Channels, channel)
+}
+
+// parseAPIKey parses a user:key[:role[:channel|channel...]] entry. Keys can
+// contain colons, as they could before roles existed: the role and channels
+// are only read from the end of the entry when the role is known. Users
+// without a role are admins.
+func parseAPIKey(s string) (entities.User, string, error) {
+	id, rest, _ := strings.Cut(s, ":")
+	if id == "" || rest == "" {
+		return entities.User{}, "", errors.New("must be formatted as user:key[:role[:channel|channel]]")
+	}
+
+	user := entities.User{ID: id, Role: entities.RoleAdmin}
+	key := rest
+	parts := strings.Split(rest, ":")
+	switch n := len(parts); {
+	case n >= 2 && isRole(parts[n-1]):
+		user.Role = entities.Role(parts[n-1])
+		key = strings.Join(parts[:n-1], ":")
+	case n >= 3 && isRole(parts[n-2]):
+		user.Role = entities.Role(parts[n-2])
+		key = strings.Join(parts[:n-2], ":")
+		for _, c := range strings.Split(parts[n-1], "|") {
+			if c == "" {
+				return entities.User{}, "", errors.New("empty channel")
+			}
+			user.Channels = append(user.Channels, c)
+		}
+	}
+	if key == "" {
+		return entities.User{}, "", fmt.Errorf("empty key for %s", id)
+	}
+
+	return user, key, nil
+}
+
+func isRole(s string) bool {
+	_, ok := roleActions[entities.Role(s)]
+	return ok
+}
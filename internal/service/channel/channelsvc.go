@@ -0,0 +1,63 @@
+// Package channel keeps the on air service of every channel. The default
+// channel is the one served by the unscoped routes and the integrations,
+// the other channels are only served by the channel routes.
+package channel
+
+import (
+	"context"
+	"fmt"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+)
+
+type SVC interface {
+	// Get returns the on air service of the channel.
+	Get(ctx context.Context, wl wlog.Logger, id string) (onair.SVC, error)
+	// List returns the IDs of the channels, the default channel first.
+	List(ctx context.Context, wl wlog.Logger) []string
+}
+
+type channelService struct {
+	ids      []string
+	channels map[string]onair.SVC
+}
+
+// New creates the configured channels next to the default one, with the
+// options given.
+func New(cfg *Config, defaultChannel onair.SVC, opts ...onair.Option) (SVC, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	cs := &channelService{
+		ids:      []string{onair.DefaultChannel},
+		channels: map[string]onair.SVC{onair.DefaultChannel: defaultChannel},
+	}
+
+	for _, id := range cfg.Channels {
+		onAirService, err := onair.New(opts...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating channel %s: %w", id, err)
+		}
+		cs.ids = append(cs.ids, id)
+		cs.channels[id] = onAirService
+	}
+
+	return cs, nil
+}
+
+func (cs *channelService) Get(
+	ctx context.Context,
+	wl wlog.Logger,
+	id string,
+) (onair.SVC, error) {
+	onAirService, ok := cs.channels[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrChannelNotFound, id)
+	}
+	return onAirService, nil
+}
+
+func (cs *channelService) List(ctx context.Context, wl wlog.Logger) []string {
+	return append([]string{}, cs.ids...)
+}
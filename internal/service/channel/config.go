@@ -0,0 +1,46 @@
+package channel
+
+import (
+	"errors"
+	"on-air/internal/service/onair"
+	"regexp"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// IDPattern is what channel and group IDs are made of, so they can be used
+// in paths and grants as is.
+var IDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Config holds the configuration options for the channels.
+type Config struct {
+	// The IDs of the channels besides the default one
+	Channels []string `env:"ONAIR_CHANNELS" envSeparator:","`
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.Channels,
+			validation.Each(
+				validation.Match(IDPattern).Error("must be lowercase letters, digits, dashes and underscores"),
+				validation.NotIn(onair.DefaultChannel).Error("is the default channel"),
+			),
+			validation.By(validateUnique),
+		),
+	)
+}
+
+func validateUnique(value interface{}) error {
+	ids, _ := value.([]string)
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return errors.New("must not have duplicates")
+		}
+		seen[id] = true
+	}
+	return nil
+}
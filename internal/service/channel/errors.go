@@ -0,0 +1,7 @@
+package channel
+
+import "errors"
+
+var (
+	ErrChannelNotFound = errors.New("channel not found")
+)
@@ -0,0 +1,26 @@
+package group
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Config holds the configuration options for the channel groups.
+type Config struct {
+	// The groups, see ParseDefinition
+	Groups []string `env:"CHANNEL_GROUPS" envSeparator:","`
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.Groups, validation.Each(validation.By(validateDefinition))),
+	)
+}
+
+func validateDefinition(value interface{}) error {
+	s, _ := value.(string)
+	_, err := ParseDefinition(s)
+	return err
+}
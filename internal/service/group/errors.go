@@ -0,0 +1,8 @@
+package group
+
+import "errors"
+
+var (
+	ErrInvalidGroup  = errors.New("invalid group")
+	ErrGroupNotFound = errors.New("group not found")
+)
@@ -0,0 +1,207 @@
+package group_test
+
+import (
+	"context"
+	"errors"
+	"on-air/internal/entities"
+	"on-air/internal/service/channel"
+	"on-air/internal/service/group"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseDefinition(t *testing.T) {
+	testData := []struct {
+		name     string
+		s        string
+		expected group.Definition
+		valid    bool
+	}{
+		{
+			name:     "any",
+			s:        "id=hallway;policy=any;channels=a|b",
+			expected: group.Definition{ID: "hallway", Policy: group.PolicyAny, Channels: []string{"a", "b"}},
+			valid:    true,
+		},
+		{
+			name:     "count",
+			s:        "id=busy; policy=COUNT; min=2; channels=a|b|c",
+			expected: group.Definition{ID: "busy", Policy: group.PolicyCount, Channels: []string{"a", "b", "c"}, Min: 2},
+			valid:    true,
+		},
+		{name: "unknown policy", s: "id=x;policy=most;channels=a"},
+		{name: "min too large", s: "id=x;policy=count;min=3;channels=a|b"},
+		{name: "min without count", s: "id=x;policy=any;min=1;channels=a"},
+		{name: "duplicate channel", s: "id=x;policy=any;channels=a|a"},
+		{name: "missing channels", s: "id=x;policy=all"},
+		{name: "invalid id", s: "id=Hall Way;policy=any;channels=a"},
+		{name: "unknown field", s: "id=x;policy=any;channels=a;color=red"},
+	}
+
+	for _, tc := range testData {
+		got, err := group.ParseDefinition(tc.s)
+		if !tc.valid {
+			assert.ErrorIs(t, err, group.ErrInvalidGroup, tc.name)
+			continue
+		}
+		assert.NilError(t, err, tc.name)
+		assert.DeepEqual(t, got, tc.expected)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	on := func(msg string) entities.OnAirStatus { return entities.OnAirStatus{IsOnAir: true, Message: msg} }
+	off := entities.OnAirStatus{Message: "idle"}
+
+	testData := []struct {
+		name            string
+		def             group.Definition
+		statuses        []entities.OnAirStatus
+		expectedOnAir   bool
+		expectedMessage string
+	}{
+		{"any, none", group.Definition{Policy: group.PolicyAny}, []entities.OnAirStatus{off, off, off}, false, ""},
+		{"any, one", group.Definition{Policy: group.PolicyAny}, []entities.OnAirStatus{off, on("live"), off}, true, "b"},
+		{"all, some", group.Definition{Policy: group.PolicyAll}, []entities.OnAirStatus{on(""), on(""), off}, false, "a, b"},
+		{"all, every", group.Definition{Policy: group.PolicyAll}, []entities.OnAirStatus{on(""), on(""), on("")}, true, "a, b, c"},
+		{"count, below", group.Definition{Policy: group.PolicyCount, Min: 2}, []entities.OnAirStatus{off, off, on("")}, false, "c"},
+		{"count, reached", group.Definition{Policy: group.PolicyCount, Min: 2}, []entities.OnAirStatus{on(""), off, on("")}, true, "a, c"},
+		{"priority, highest", group.Definition{Policy: group.PolicyPriority}, []entities.OnAirStatus{off, on("b live"), on("c live")}, true, "b live"},
+		{"priority, none", group.Definition{Policy: group.PolicyPriority}, []entities.OnAirStatus{off, off, off}, false, ""},
+	}
+
+	for _, tc := range testData {
+		tc.def.Channels = []string{"a", "b", "c"}
+		onAir, message := tc.def.Aggregate(tc.statuses)
+		assert.Equal(t, onAir, tc.expectedOnAir, tc.name)
+		assert.Equal(t, message, tc.expectedMessage, tc.name)
+	}
+}
+
+func TestRun(t *testing.T) {
+	wl := wlog.NewNopLogger()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	defaultChannel, err := onair.New()
+	assert.NilError(t, err)
+
+	channels, err := channel.New(&channel.Config{Channels: []string{"studio-a", "studio-b"}}, defaultChannel)
+	assert.NilError(t, err)
+
+	groups, err := group.New(&group.Config{Groups: []string{
+		"id=floor;policy=all;channels=studio-a|studio-b",
+	}}, channels)
+	assert.NilError(t, err)
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		groups.Run(runCtx, wl)
+		close(done)
+	}()
+
+	floor, err := groups.Get(ctx, wl, "floor")
+	assert.NilError(t, err)
+	initial, err := floor.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+
+	for _, id := range []string{"studio-a", "studio-b"} {
+		studio, err := channels.Get(ctx, wl, id)
+		assert.NilError(t, err)
+		_, err = studio.SetOnAirStatus(ctx, wl, entities.OnAirStatus{IsOnAir: true})
+		assert.NilError(t, err)
+	}
+
+	// wait for both studios to be aggregated
+	got := initial
+	for !got.IsOnAir {
+		got, err = floor.WaitForChange(ctx, wl, got.Revision)
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, got.Message, "studio-a, studio-b")
+	assert.Assert(t, got.LastOnAir.Valid)
+
+	stop()
+	<-done
+
+	_, err = groups.Get(ctx, wl, "nope")
+	assert.ErrorIs(t, err, group.ErrGroupNotFound)
+}
+
+// flakyChannel fails the first wait for a change.
+type flakyChannel struct {
+	onair.SVC
+	failed atomic.Bool
+}
+
+func (c *flakyChannel) WaitForChange(ctx context.Context, wl wlog.Logger, since uint64) (entities.OnAirStatus, error) {
+	if c.failed.CompareAndSwap(false, true) {
+		return entities.OnAirStatus{}, errors.New("unavailable")
+	}
+	return c.SVC.WaitForChange(ctx, wl, since)
+}
+
+func TestRunRetriesFailedWaits(t *testing.T) {
+	wl := wlog.NewNopLogger()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	onAirService, err := onair.New()
+	assert.NilError(t, err)
+	defaultChannel := &flakyChannel{SVC: onAirService}
+
+	channels, err := channel.New(&channel.Config{}, defaultChannel)
+	assert.NilError(t, err)
+
+	groups, err := group.New(&group.Config{Groups: []string{"id=lobby;policy=any;channels=default"}}, channels)
+	assert.NilError(t, err)
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		groups.Run(runCtx, wl)
+		close(done)
+	}()
+	defer func() {
+		stop()
+		<-done
+	}()
+
+	lobby, err := groups.Get(ctx, wl, "lobby")
+	assert.NilError(t, err)
+	got, err := lobby.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+
+	// change the channel once the watcher failed so only a retry sees it
+	for !defaultChannel.failed.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	_, err = defaultChannel.SetOnAirStatus(ctx, wl, entities.OnAirStatus{IsOnAir: true})
+	assert.NilError(t, err)
+
+	for !got.IsOnAir {
+		assert.NilError(t, ctx.Err(), "the group stopped watching")
+		got, err = lobby.WaitForChange(ctx, wl, got.Revision)
+		assert.NilError(t, err)
+	}
+}
+
+func TestNewRejectsUnknownChannels(t *testing.T) {
+	defaultChannel, err := onair.New()
+	assert.NilError(t, err)
+
+	channels, err := channel.New(&channel.Config{}, defaultChannel)
+	assert.NilError(t, err)
+
+	_, err = group.New(&group.Config{Groups: []string{"id=floor;policy=any;channels=default|studio-z"}}, channels)
+	assert.ErrorIs(t, err, group.ErrInvalidGroup)
+
+	_, err = group.New(&group.Config{Groups: []string{"id=default;policy=any;channels=default"}}, channels)
+	assert.ErrorIs(t, err, group.ErrInvalidGroup)
+}
@@ -0,0 +1,256 @@
+// Package group aggregates the status of channel groups. A group is a
+// read-only virtual channel whose status is recomputed whenever the status
+// of one of its channels changes.
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/service/channel"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/guregu/null"
+)
+
+type SVC interface {
+	// Get returns the status of the group, which can be read like a channel.
+	Get(ctx context.Context, wl wlog.Logger, id string) (onair.Reader, error)
+	// List returns the definitions of the groups.
+	List(ctx context.Context, wl wlog.Logger) []Definition
+	// Run recomputes the groups whenever a channel changes, until the
+	// context is done.
+	Run(ctx context.Context, wl wlog.Logger)
+}
+
+type groupService struct {
+	channels    channel.SVC
+	definitions []Definition
+	groups      map[string]*group
+}
+
+// New creates the groups of the configuration. Every channel of a group
+// has to exist.
+func New(cfg *Config, channels channel.SVC) (SVC, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	gs := &groupService{
+		channels: channels,
+		groups:   make(map[string]*group, len(cfg.Groups)),
+	}
+
+	known := map[string]bool{}
+	for _, id := range channels.List(context.Background(), wlog.NewNopLogger()) {
+		known[id] = true
+	}
+
+	for _, s := range cfg.Groups {
+		def, err := ParseDefinition(s)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := gs.groups[def.ID]; ok || known[def.ID] {
+			return nil, fmt.Errorf("%w: %s is already a group or a channel", ErrInvalidGroup, def.ID)
+		}
+		for _, c := range def.Channels {
+			if !known[c] {
+				return nil, fmt.Errorf("%w: %s: unknown channel %s", ErrInvalidGroup, def.ID, c)
+			}
+		}
+
+		gs.definitions = append(gs.definitions, def)
+		gs.groups[def.ID] = &group{
+			def: def,
+			onAir: entities.OnAirStatus{
+				LastUpdated: null.TimeFrom(time.Now()),
+				Revision:    1,
+			},
+			changed: make(chan struct{}),
+		}
+	}
+
+	return gs, nil
+}
+
+func (gs *groupService) Get(
+	ctx context.Context,
+	wl wlog.Logger,
+	id string,
+) (onair.Reader, error) {
+	g, ok := gs.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, id)
+	}
+	return g, nil
+}
+
+func (gs *groupService) List(ctx context.Context, wl wlog.Logger) []Definition {
+	return append([]Definition{}, gs.definitions...)
+}
+
+func (gs *groupService) Run(ctx context.Context, wl wlog.Logger) {
+	// the groups of every channel, to recompute them on its changes
+	byChannel := map[string][]*group{}
+	for _, def := range gs.definitions {
+		for _, c := range def.Channels {
+			byChannel[c] = append(byChannel[c], gs.groups[def.ID])
+		}
+	}
+
+	// read the revisions before computing the groups so no change made in
+	// between is missed
+	watched := map[string]uint64{}
+	services := map[string]onair.SVC{}
+	for c := range byChannel {
+		onAirService, err := gs.channels.Get(ctx, wl, c)
+		if err != nil {
+			wl.Error(err)
+			continue
+		}
+		onAir, err := onAirService.GetOnAirStatus(ctx, wl)
+		if err != nil {
+			wl.Error(err)
+			continue
+		}
+		services[c] = onAirService
+		watched[c] = onAir.Revision
+	}
+
+	// catch up with the changes made before running, e.g. a restored snapshot
+	for _, def := range gs.definitions {
+		gs.recompute(ctx, wl, gs.groups[def.ID])
+	}
+
+	var wg sync.WaitGroup
+	for c, since := range watched {
+		wg.Add(1)
+		go func(onAirService onair.SVC, since uint64, groups []*group) {
+			defer wg.Done()
+			gs.watch(ctx, wl, onAirService, since, groups)
+		}(services[c], since, byChannel[c])
+	}
+	wg.Wait()
+}
+
+// watch recomputes the groups every time the channel changes past since.
+// A failed wait is retried with backoff until the context is done.
+func (gs *groupService) watch(ctx context.Context, wl wlog.Logger, onAirService onair.SVC, since uint64, groups []*group) {
+	retry := backoff.NewExponentialBackOff()
+	// never give up, the groups would silently stop changing
+	retry.MaxElapsedTime = 0
+
+	for {
+		onAir, err := onAirService.WaitForChange(ctx, wl, since)
+		if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			wait := retry.NextBackOff()
+			wl.Error(fmt.Errorf("error watching the channel, retrying in %s: %w", wait, err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+		retry.Reset()
+		since = onAir.Revision
+
+		for _, g := range groups {
+			gs.recompute(ctx, wl, g)
+		}
+	}
+}
+
+// recompute aggregates the current status of the channels of the group.
+func (gs *groupService) recompute(ctx context.Context, wl wlog.Logger, g *group) {
+	statuses := make([]entities.OnAirStatus, 0, len(g.def.Channels))
+	for _, c := range g.def.Channels {
+		onAirService, err := gs.channels.Get(ctx, wl, c)
+		if err != nil {
+			wl.Error(err)
+			return
+		}
+		onAir, err := onAirService.GetOnAirStatus(ctx, wl)
+		if err != nil {
+			wl.Error(fmt.Errorf("error reading channel %s of group %s: %w", c, g.def.ID, err))
+			return
+		}
+		statuses = append(statuses, onAir)
+	}
+
+	isOnAir, message := g.def.Aggregate(statuses)
+	if g.set(isOnAir, message, time.Now()) {
+		wl.Debugf("group %s changed, on air: %t", g.def.ID, isOnAir)
+	}
+}
+
+// group is the aggregate status of a group, it implements onair.Reader.
+type group struct {
+	def     Definition
+	mu      sync.RWMutex
+	onAir   entities.OnAirStatus
+	changed chan struct{}
+}
+
+// set updates the status and wakes up the waiters when it changed.
+func (g *group) set(isOnAir bool, message string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.onAir.IsOnAir == isOnAir && g.onAir.Message == message {
+		return false
+	}
+
+	// like the channels, the group was last on air until now when it's
+	// on air or going off air
+	if isOnAir || g.onAir.IsOnAir {
+		g.onAir.LastOnAir = null.TimeFrom(now)
+	}
+	g.onAir.IsOnAir = isOnAir
+	g.onAir.Message = message
+	g.onAir.LastUpdated = null.TimeFrom(now)
+	g.onAir.Revision++
+
+	close(g.changed)
+	g.changed = make(chan struct{})
+
+	return true
+}
+
+func (g *group) GetOnAirStatus(ctx context.Context, wl wlog.Logger) (entities.OnAirStatus, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.onAir, nil
+}
+
+func (g *group) WaitForChange(ctx context.Context, wl wlog.Logger, since uint64) (entities.OnAirStatus, error) {
+	for {
+		g.mu.RLock()
+		onAir, changed := g.onAir, g.changed
+		g.mu.RUnlock()
+
+		if onAir.Revision > since {
+			return onAir, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			// a deadline is the expected way for a wait to end
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return onAir, nil
+			}
+			return onAir, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package group
+
+import (
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/service/channel"
+	"strconv"
+	"strings"
+)
+
+// Policy is how the status of a group is derived from its channels.
+type Policy string
+
+const (
+	// PolicyAny is on air when any channel is.
+	PolicyAny Policy = "any"
+	// PolicyAll is on air when every channel is.
+	PolicyAll Policy = "all"
+	// PolicyCount is on air when at least Min channels are.
+	PolicyCount Policy = "count"
+	// PolicyPriority mirrors the first channel on air, channels being
+	// listed by decreasing priority.
+	PolicyPriority Policy = "priority"
+)
+
+// Group fields.
+const (
+	fieldID       = "id"
+	fieldPolicy   = "policy"
+	fieldChannels = "channels"
+	fieldMin      = "min"
+)
+
+// Definition is a group of channels and how their statuses are aggregated.
+type Definition struct {
+	ID     string
+	Policy Policy
+	// Channels are the IDs of the member channels
+	Channels []string
+	// Min is the number of channels on air the count policy needs
+	Min int
+}
+
+// ParseDefinition reads a group written as semicolon separated field=value
+// pairs, e.g. id=hallway;policy=count;min=2;channels=studio-a|studio-b|studio-c.
+func ParseDefinition(s string) (Definition, error) {
+	var d Definition
+	for _, field := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(field, "=")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return Definition{}, fmt.Errorf("%w: %q must be formatted as field=value", ErrInvalidGroup, field)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case fieldID:
+			d.ID = value
+		case fieldPolicy:
+			d.Policy = Policy(strings.ToLower(value))
+		case fieldChannels:
+			d.Channels = strings.Split(value, "|")
+		case fieldMin:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return Definition{}, fmt.Errorf("%w: min must be a number", ErrInvalidGroup)
+			}
+			d.Min = n
+		default:
+			return Definition{}, fmt.Errorf("%w: unknown field %q, expected id, policy, channels or min", ErrInvalidGroup, name)
+		}
+	}
+
+	if err := d.validate(); err != nil {
+		return Definition{}, err
+	}
+
+	return d, nil
+}
+
+func (d Definition) validate() error {
+	if !channel.IDPattern.MatchString(d.ID) {
+		return fmt.Errorf("%w: id %q must be lowercase letters, digits, dashes and underscores", ErrInvalidGroup, d.ID)
+	}
+
+	switch d.Policy {
+	case PolicyAny, PolicyAll, PolicyPriority:
+		if d.Min != 0 {
+			return fmt.Errorf("%w: %s: min only applies to the count policy", ErrInvalidGroup, d.ID)
+		}
+	case PolicyCount:
+		if d.Min < 1 || d.Min > len(d.Channels) {
+			return fmt.Errorf("%w: %s: min must be between 1 and the number of channels", ErrInvalidGroup, d.ID)
+		}
+	default:
+		return fmt.Errorf("%w: %s: policy must be any, all, count or priority", ErrInvalidGroup, d.ID)
+	}
+
+	if len(d.Channels) == 0 {
+		return fmt.Errorf("%w: %s: missing channels", ErrInvalidGroup, d.ID)
+	}
+	seen := make(map[string]bool, len(d.Channels))
+	for _, c := range d.Channels {
+		if c == "" || seen[c] {
+			return fmt.Errorf("%w: %s: empty or duplicate channel %q", ErrInvalidGroup, d.ID, c)
+		}
+		seen[c] = true
+	}
+
+	return nil
+}
+
+// Aggregate returns whether the group is on air and its message given the
+// statuses of its channels, in the order of Channels. The message of the
+// priority policy is the one of the channel it mirrors, the other policies
+// list the channels on air.
+func (d Definition) Aggregate(statuses []entities.OnAirStatus) (bool, string) {
+	var onAir []string
+	for i, s := range statuses {
+		if !s.IsOnAir {
+			continue
+		}
+		if d.Policy == PolicyPriority {
+			return true, s.Message
+		}
+		onAir = append(onAir, d.Channels[i])
+	}
+
+	message := strings.Join(onAir, ", ")
+	switch d.Policy {
+	case PolicyAny:
+		return len(onAir) > 0, message
+	case PolicyAll:
+		return len(onAir) == len(statuses), message
+	case PolicyCount:
+		return len(onAir) >= d.Min, message
+	}
+
+	return false, ""
+}
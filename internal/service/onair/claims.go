@@ -0,0 +1,261 @@
+package onair
+
+import (
+	"context"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"slices"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+type sourceKey struct{}
+
+// WithSource returns a context whose status changes are claimed by the source.
+func WithSource(ctx context.Context, source entities.Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFrom returns the source set by WithSource, SourceManual by default.
+func SourceFrom(ctx context.Context) entities.Source {
+	if source, ok := ctx.Value(sourceKey{}).(entities.Source); ok {
+		return source
+	}
+	return entities.SourceManual
+}
+
+func (oas *onAirService) Claim(
+	ctx context.Context,
+	wl wlog.Logger,
+	claim entities.Claim,
+) (entities.OnAirStatus, error) {
+	wl.Debugf("%s claims onAir %v until %v", claim.Source, claim.IsOnAir, claim.ExpiresAt)
+
+	// the claim is made on behalf of its source
+	ctx = WithSource(ctx, claim.Source)
+	return oas.update(ctx, wl, claim.Source, func(now time.Time) ([]entities.Claim, bool) {
+		claim.ClaimedAt = now
+		return withClaim(oas.onAir.Claims, claim), true
+	})
+}
+
+func (oas *onAirService) Release(
+	ctx context.Context,
+	wl wlog.Logger,
+	source entities.Source,
+) (entities.OnAirStatus, error) {
+	wl.Debugf("%s releases its onAir claim", source)
+
+	return oas.update(ctx, wl, source, func(now time.Time) ([]entities.Claim, bool) {
+		claims := withoutClaim(oas.onAir.Claims, source)
+		return claims, len(claims) != len(oas.onAir.Claims)
+	})
+}
+
+// update replaces the claims by the ones returned by change, which runs with
+// the lock held, and resolves the status. The listeners are only notified
+// when the status changes. The manual changes skip the hysteresis, and a
+// manual claim without expiry lasts until an integration changes its claim
+// or its claim expires.
+func (oas *onAirService) update(
+	ctx context.Context,
+	wl wlog.Logger,
+	source entities.Source,
+	change func(now time.Time) ([]entities.Claim, bool),
+) (entities.OnAirStatus, error) {
+	now := oas.clock.Now()
+	immediate := source == entities.SourceManual
+
+	oas.mu.Lock()
+
+	if err := oas.checkLock(ctx, now); err != nil {
+		oas.mu.Unlock()
+		return entities.OnAirStatus{}, err
+	}
+
+	claims, changed := change(now)
+	if !changed {
+		onAir := oas.onAir
+		oas.mu.Unlock()
+		return onAir, nil
+	}
+	if actor := SourceFrom(ctx); actor != entities.SourceManual && claimChanged(oas.onAir.Claims, claims, actor) {
+		claims = withoutOpenManualClaim(claims)
+	}
+
+	before := oas.onAir
+	oas.resolve(wl, claims, now, immediate)
+	oas.scheduleClaimExpiry(wl)
+	if !statusChanged(before, oas.onAir) {
+		onAir := oas.onAir
+		oas.mu.Unlock()
+		return onAir, nil
+	}
+	updated := oas.commit(before.IsOnAir, now)
+	oas.mu.Unlock()
+
+	oas.notify(ctx, wl, updated)
+
+	return updated, nil
+}
+
+// resolve keeps the active claims, ordered by priority, and settles the
+// status on the one of the first claim, or off air when there are none.
+// Unless immediate is set, going on or off air is subject to the hysteresis.
+// It must be called with the write lock held.
+func (oas *onAirService) resolve(wl wlog.Logger, claims []entities.Claim, now time.Time, immediate bool) {
+	active := make([]entities.Claim, 0, len(claims))
+	for _, c := range claims {
+		if c.Active(now) {
+			active = append(active, c)
+		}
+	}
+	slices.SortStableFunc(active, func(a, b entities.Claim) int {
+		return oas.rank(a.Source) - oas.rank(b.Source)
+	})
+
+	oas.onAir.Claims = active
+	oas.settle(wl, now, immediate)
+}
+
+// wanted returns the status claimed with the highest priority.
+// It must be called with the lock held.
+func (oas *onAirService) wanted() entities.Claim {
+	if len(oas.onAir.Claims) == 0 {
+		return entities.Claim{}
+	}
+	return oas.onAir.Claims[0]
+}
+
+// apply sets the status to the claim.
+// It must be called with the write lock held.
+func (oas *onAirService) apply(claim entities.Claim, now time.Time) {
+	wasOnAir := oas.onAir.IsOnAir
+	oas.onAir.IsOnAir = claim.IsOnAir
+	oas.onAir.Message = claim.Message
+	oas.onAir.Source = claim.Source
+
+	oas.onAir.LastUpdated = null.TimeFrom(now)
+	if wasOnAir != oas.onAir.IsOnAir {
+		oas.since = now
+	}
+	// the status was on air up to now
+	if wasOnAir || oas.onAir.IsOnAir {
+		oas.onAir.LastOnAir = null.TimeFrom(now)
+	}
+}
+
+// rank orders the sources by priority, unknown sources come last.
+func (oas *onAirService) rank(source entities.Source) int {
+	if r, ok := oas.priority[source]; ok {
+		return r
+	}
+	return len(oas.priority)
+}
+
+// scheduleClaimExpiry resolves the status again when the first claim
+// expires, replacing any previously scheduled resolution.
+// It must be called with the write lock held.
+func (oas *onAirService) scheduleClaimExpiry(wl wlog.Logger) {
+	if oas.claimTimer != nil {
+		oas.claimTimer.Stop()
+		oas.claimTimer = nil
+	}
+
+	var next null.Time
+	for _, c := range oas.onAir.Claims {
+		if c.ExpiresAt.Valid && (!next.Valid || c.ExpiresAt.Time.Before(next.Time)) {
+			next = c.ExpiresAt
+		}
+	}
+	if !next.Valid {
+		return
+	}
+
+	oas.claimTimer = oas.clock.AfterFunc(next.Time.Sub(oas.clock.Now()), func() {
+		now := oas.clock.Now()
+
+		oas.mu.Lock()
+		// the claims may have been replaced in the meantime
+		expired := slices.ContainsFunc(oas.onAir.Claims, func(c entities.Claim) bool {
+			return !c.Active(now)
+		})
+		if !expired {
+			oas.mu.Unlock()
+			return
+		}
+
+		// an integration claim expiring is a change of its claim, it ends
+		// the manual override like a release would
+		claims := oas.onAir.Claims
+		if slices.ContainsFunc(claims, func(c entities.Claim) bool {
+			return c.Source != entities.SourceManual && !c.Active(now)
+		}) {
+			claims = withoutOpenManualClaim(claims)
+		}
+
+		before := oas.onAir
+		oas.resolve(wl, claims, now, false)
+		oas.scheduleClaimExpiry(wl)
+		if !statusChanged(before, oas.onAir) {
+			oas.mu.Unlock()
+			wl.Debug("onAir claim expired")
+			return
+		}
+		updated := oas.commit(before.IsOnAir, now)
+		oas.mu.Unlock()
+
+		wl.Info("onAir claim expired")
+		oas.notify(context.Background(), wl, updated)
+	})
+}
+
+// statusChanged reports whether the status resolved from the claims differs.
+func statusChanged(before, after entities.OnAirStatus) bool {
+	return before.IsOnAir != after.IsOnAir || before.Message != after.Message || before.Source != after.Source
+}
+
+// claimChanged reports whether the claim of the source differs between the
+// claims, ignoring when it was made and until when.
+func claimChanged(before, after []entities.Claim, source entities.Source) bool {
+	find := func(claims []entities.Claim) (entities.Claim, bool) {
+		i := slices.IndexFunc(claims, func(c entities.Claim) bool { return c.Source == source })
+		if i < 0 {
+			return entities.Claim{}, false
+		}
+		return claims[i], true
+	}
+
+	b, hadClaim := find(before)
+	a, hasClaim := find(after)
+	return hadClaim != hasClaim || b.IsOnAir != a.IsOnAir || b.Message != a.Message
+}
+
+// withoutOpenManualClaim returns the claims without the manual one when it
+// has no expiry, e.g. from the legacy toggle.
+func withoutOpenManualClaim(claims []entities.Claim) []entities.Claim {
+	i := slices.IndexFunc(claims, func(c entities.Claim) bool { return c.Source == entities.SourceManual })
+	if i < 0 || claims[i].ExpiresAt.Valid {
+		return claims
+	}
+	return withoutClaim(claims, entities.SourceManual)
+}
+
+// withClaim returns a copy of the claims where claim replaces the one of
+// the same source. Claims are never modified in place, they're shared with
+// the statuses returned.
+func withClaim(claims []entities.Claim, claim entities.Claim) []entities.Claim {
+	return append(withoutClaim(claims, claim.Source), claim)
+}
+
+// withoutClaim returns a copy of the claims without the one of the source.
+func withoutClaim(claims []entities.Claim, source entities.Source) []entities.Claim {
+	kept := make([]entities.Claim, 0, len(claims)+1)
+	for _, c := range claims {
+		if c.Source != source {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
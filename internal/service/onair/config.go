@@ -0,0 +1,69 @@
+package onair
+
+import (
+	"errors"
+	"on-air/internal/entities"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Config holds the configuration options for the on air service.
+type Config struct {
+	// The sources by decreasing priority, the missing ones come last in
+	// their default order
+	SourcePriority []string `env:"ONAIR_SOURCE_PRIORITY" envSeparator:"," envDefault:"manual,pubsub,homeassistant,schedule,calendar"`
+	// How long the status stays on air before going off
+	MinOnAir time.Duration `env:"ONAIR_MIN_ON_AIR" envDefault:"0s"`
+	// How long the status stays off air before going on again
+	Cooldown time.Duration `env:"ONAIR_COOLDOWN" envDefault:"0s"`
+	// How long a transition waits, a transition reverted in the meantime
+	// doesn't happen
+	CoalesceWindow time.Duration `env:"ONAIR_COALESCE_WINDOW" envDefault:"0s"`
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	sources := make([]interface{}, 0, len(entities.Sources))
+	for _, s := range entities.Sources {
+		sources = append(sources, string(s))
+	}
+
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.SourcePriority,
+			validation.Each(validation.In(sources...).Error("must be a known source")),
+			validation.By(validateUnique),
+		),
+		validation.Field(&c.MinOnAir, validation.Min(time.Duration(0))),
+		validation.Field(&c.Cooldown, validation.Min(time.Duration(0))),
+		validation.Field(&c.CoalesceWindow, validation.Min(time.Duration(0))),
+	)
+}
+
+func validateUnique(value interface{}) error {
+	sources, _ := value.([]string)
+	seen := make(map[string]bool, len(sources))
+	for _, s := range sources {
+		if seen[s] {
+			return errors.New("must not have duplicates")
+		}
+		seen[s] = true
+	}
+	return nil
+}
+
+// priority ranks the sources, the lower the rank the higher the priority.
+func (c *Config) priority() map[entities.Source]int {
+	rank := make(map[entities.Source]int, len(entities.Sources))
+	for _, s := range c.SourcePriority {
+		rank[entities.Source(s)] = len(rank)
+	}
+	for _, s := range entities.Sources {
+		if _, ok := rank[s]; !ok {
+			rank[s] = len(rank)
+		}
+	}
+	return rank
+}
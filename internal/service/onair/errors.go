@@ -0,0 +1,9 @@
+package onair
+
+import "errors"
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrInvalidState    = errors.New("invalid state")
+	ErrLocked          = errors.New("status locked")
+)
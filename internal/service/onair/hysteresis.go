@@ -0,0 +1,117 @@
+package onair
+
+import (
+	"context"
+	"expvar"
+	"on-air/internal/clock"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"time"
+)
+
+// metrics counts the transitions held back by the hysteresis of every
+// channel, served at /debug/vars.
+var metrics = expvar.NewMap("onair")
+
+const (
+	metricDeferred   = "transitions_deferred"
+	metricSuppressed = "transitions_suppressed"
+)
+
+// settle applies the wanted status right away when it stays on or off air,
+// when immediate is set or when the hysteresis allows it. Otherwise the
+// transition is deferred until it's due, and suppressed when the wanted
+// status reverts before then.
+// It must be called with the write lock held.
+func (oas *onAirService) settle(wl wlog.Logger, now time.Time, immediate bool) {
+	want := oas.wanted()
+
+	if want.IsOnAir == oas.onAir.IsOnAir || immediate {
+		if oas.pendingTimer != nil && !immediate {
+			metrics.Add(metricSuppressed, 1)
+			wl.Debugf("suppressed the transition to onAir %v, %s reverted it", !want.IsOnAir, want.Source)
+		}
+		oas.cancelPending()
+		oas.apply(want, now)
+		return
+	}
+
+	// the claims are read again once the pending transition is due
+	if oas.pendingTimer != nil {
+		return
+	}
+
+	due := oas.dueAt(want.IsOnAir, now)
+	if !due.After(now) {
+		oas.apply(want, now)
+		return
+	}
+
+	metrics.Add(metricDeferred, 1)
+	wl.Debugf("deferring the transition to onAir %v claimed by %s until %s",
+		want.IsOnAir, want.Source, due.Format(time.RFC3339Nano))
+
+	var timer clock.Timer
+	timer = oas.clock.AfterFunc(due.Sub(now), func() {
+		now := oas.clock.Now()
+
+		oas.mu.Lock()
+		// the transition may have been suppressed in the meantime
+		if oas.pendingTimer != timer {
+			oas.mu.Unlock()
+			return
+		}
+		oas.pendingTimer = nil
+
+		before := oas.onAir
+		oas.apply(oas.wanted(), now)
+		if !statusChanged(before, oas.onAir) {
+			oas.mu.Unlock()
+			return
+		}
+		updated := oas.commit(before.IsOnAir, now)
+		oas.mu.Unlock()
+
+		wl.Debugf("applied the deferred transition to onAir %v", updated.IsOnAir)
+		oas.notify(context.Background(), wl, updated)
+	})
+	oas.pendingTimer = timer
+}
+
+// dueAt returns when going on or off air is allowed: once the coalesce
+// window has passed, and the minimum on air duration or the cool-down since
+// the last transition.
+// It must be called with the lock held.
+func (oas *onAirService) dueAt(isOnAir bool, now time.Time) time.Time {
+	due := now.Add(oas.cfg.CoalesceWindow)
+
+	hold := oas.cfg.MinOnAir
+	if isOnAir {
+		hold = oas.cfg.Cooldown
+	}
+	if t := oas.since.Add(hold); t.After(due) {
+		due = t
+	}
+
+	return due
+}
+
+// cancelPending drops the deferred transition, if any.
+// It must be called with the write lock held.
+func (oas *onAirService) cancelPending() {
+	if oas.pendingTimer != nil {
+		oas.pendingTimer.Stop()
+		oas.pendingTimer = nil
+	}
+}
+
+// lastFlip returns when the status last went on or off air according to
+// the history, zero when it never did.
+func lastFlip(history []entities.Transition) time.Time {
+	for i := len(history) - 1; i > 0; i-- {
+		if history[i].IsOnAir != history[i-1].IsOnAir {
+			return history[i].At
+		}
+	}
+	return time.Time{}
+}
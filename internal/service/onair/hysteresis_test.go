@@ -0,0 +1,101 @@
+package onair_test
+
+import (
+	"context"
+	"expvar"
+	"on-air/internal/clock"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// metric returns the value of an onair counter, shared by every test.
+func metric(name string) int64 {
+	v, ok := expvar.Get("onair").(*expvar.Map).Get(name).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return v.Value()
+}
+
+func TestHysteresis(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+	hold := time.Minute
+	clk := clock.NewFake(t0)
+
+	svc, err := onair.New(onair.WithClock(clk), onair.WithConfig(&onair.Config{MinOnAir: hold, Cooldown: hold}))
+	assert.NilError(t, err)
+
+	calendarOn := entities.Claim{Source: entities.SourceCalendar, IsOnAir: true, Message: "meeting"}
+	deferred, suppressed := metric("transitions_deferred"), metric("transitions_suppressed")
+
+	// the first transition isn't held
+	s, err := svc.Claim(ctx, wl, calendarOn)
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsOnAir, true)
+
+	// going off is deferred until the status has been on air long enough
+	s, err = svc.Release(ctx, wl, entities.SourceCalendar)
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsOnAir, true)
+	assert.Equal(t, len(s.Claims), 0)
+	assert.Equal(t, metric("transitions_deferred"), deferred+1)
+
+	// and suppressed when the claim comes back in the meantime
+	s, err = svc.Claim(ctx, wl, calendarOn)
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsOnAir, true)
+	assert.Equal(t, metric("transitions_suppressed"), suppressed+1)
+
+	s, err = svc.Release(ctx, wl, entities.SourceCalendar)
+	assert.NilError(t, err)
+	clk.Advance(hold)
+	s, err = svc.WaitForChange(ctx, wl, s.Revision)
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsOnAir, false)
+
+	// going on again waits for the cool-down
+	s, err = svc.Claim(ctx, wl, calendarOn)
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsOnAir, false)
+
+	// unless the change is manual
+	s, err = svc.SetOnAirStatus(ctx, wl, entities.OnAirStatus{IsOnAir: true, Message: "live"})
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsOnAir, true)
+	assert.Equal(t, s.Message, "live")
+}
+
+func TestCoalesceWindow(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	clk := clock.NewFake(t0)
+
+	svc, err := onair.New(onair.WithClock(clk), onair.WithConfig(&onair.Config{CoalesceWindow: time.Minute}))
+	assert.NilError(t, err)
+
+	// a flap within the window doesn't change the status
+	ctx = onair.WithSource(ctx, entities.SourcePubSub)
+	s, err := svc.SetOnAirStatus(ctx, wl, entities.OnAirStatus{IsOnAir: true})
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsOnAir, false)
+	assert.Equal(t, len(s.Claims), 1)
+
+	s, err = svc.SetOnAirStatus(ctx, wl, entities.OnAirStatus{IsOnAir: false})
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsOnAir, false)
+	clk.Advance(time.Hour)
+
+	// nor the history
+	history, err := svc.GetHistory(ctx, wl, 10)
+	assert.NilError(t, err)
+	for _, tr := range history {
+		assert.Equal(t, tr.IsOnAir, false)
+	}
+}
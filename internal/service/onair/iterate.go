@@ -0,0 +1,131 @@
+package onair
+
+import (
+	"context"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+
+	"github.com/guregu/null"
+)
+
+func (oas *onAirService) EachTransition(
+	ctx context.Context,
+	wl wlog.Logger,
+	from null.Time,
+	to null.Time,
+	fn func(entities.Transition) error,
+) error {
+	var cursor uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page := oas.transitionsAfter(cursor)
+		if len(page) == 0 {
+			return nil
+		}
+
+		for _, t := range page {
+			cursor = t.Revision
+			if from.Valid && t.At.Before(from.Time) {
+				continue
+			}
+			if to.Valid && !t.At.Before(to.Time) {
+				return nil
+			}
+			if err := fn(t); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// transitionsAfter copies a page of the transitions recorded after the revision.
+func (oas *onAirService) transitionsAfter(revision uint64) []entities.Transition {
+	oas.mu.RLock()
+	defer oas.mu.RUnlock()
+
+	var page []entities.Transition
+	for _, t := range oas.history {
+		if t.Revision <= revision {
+			continue
+		}
+		page = append(page, t)
+		if len(page) == pageSize {
+			break
+		}
+	}
+
+	return page
+}
+
+func (oas *onAirService) EachSession(
+	ctx context.Context,
+	wl wlog.Logger,
+	filter entities.SessionFilter,
+	fn func(entities.Session) error,
+) error {
+	var last *entities.Session
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page := oas.sessionsAfter(last)
+		if len(page) == 0 {
+			return nil
+		}
+
+		for i := range page {
+			s := page[i]
+			last = &page[i]
+			if filter.Tag != "" && !hasTag(s, normalizeTag(filter.Tag)) {
+				continue
+			}
+			if filter.From.Valid && s.End.Valid && s.End.Time.Before(filter.From.Time) {
+				continue
+			}
+			if filter.To.Valid && !s.Start.Before(filter.To.Time) {
+				return nil
+			}
+			if err := fn(s); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sessionsAfter copies a page of the sessions following the given one.
+// Sessions trimmed from memory in the meantime are resumed by start time.
+func (oas *onAirService) sessionsAfter(last *entities.Session) []entities.Session {
+	oas.mu.RLock()
+	defer oas.mu.RUnlock()
+
+	start := 0
+	if last != nil {
+		start = len(oas.sessions)
+		for i, s := range oas.sessions {
+			if s.ID == last.ID {
+				start = i + 1
+				break
+			}
+			if s.Start.After(last.Start) {
+				start = i
+				break
+			}
+		}
+	}
+
+	end := start + pageSize
+	if end > len(oas.sessions) {
+		end = len(oas.sessions)
+	}
+
+	page := make([]entities.Session, 0, end-start)
+	for _, s := range oas.sessions[start:end] {
+		page = append(page, copySession(s))
+	}
+
+	return page
+}
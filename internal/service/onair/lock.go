@@ -0,0 +1,138 @@
+package onair
+
+import (
+	"context"
+	"fmt"
+	"on-air/internal/acontext"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+func (oas *onAirService) Lock(
+	ctx context.Context,
+	wl wlog.Logger,
+	reason string,
+	expiresAt null.Time,
+) (entities.OnAirStatus, error) {
+	now := oas.clock.Now()
+	holder, _ := acontext.UserID(ctx)
+
+	oas.mu.Lock()
+
+	if err := oas.checkLock(ctx, now); err != nil {
+		oas.mu.Unlock()
+		return entities.OnAirStatus{}, err
+	}
+
+	// locks are never modified once set, the history shares them
+	oas.onAir.Lock = &entities.Lock{
+		Holder:    holder,
+		Reason:    reason,
+		LockedAt:  now,
+		ExpiresAt: expiresAt,
+	}
+	oas.scheduleUnlock(wl)
+	updated := oas.commit(oas.onAir.IsOnAir, now)
+	oas.mu.Unlock()
+
+	wl.Infof("status locked by %q until %v: %s", holder, expiresAt, reason)
+	oas.notify(ctx, wl, updated)
+
+	return updated, nil
+}
+
+func (oas *onAirService) Unlock(
+	ctx context.Context,
+	wl wlog.Logger,
+) (entities.OnAirStatus, error) {
+	now := oas.clock.Now()
+
+	oas.mu.Lock()
+
+	if oas.onAir.Lock == nil {
+		onAir := oas.onAir
+		oas.mu.Unlock()
+		return onAir, nil
+	}
+
+	if err := oas.checkLock(ctx, now); err != nil {
+		oas.mu.Unlock()
+		return entities.OnAirStatus{}, err
+	}
+
+	oas.onAir.Lock = nil
+	oas.scheduleUnlock(wl)
+	updated := oas.commit(oas.onAir.IsOnAir, now)
+	oas.mu.Unlock()
+
+	wl.Info("status unlocked")
+	oas.notify(ctx, wl, updated)
+
+	return updated, nil
+}
+
+// checkLock returns ErrLocked when the status is locked by someone else
+// than the caller, unless the caller is an admin. The integrations never
+// hold the lock, even when its holder is anonymous.
+// It must be called with the lock held.
+func (oas *onAirService) checkLock(ctx context.Context, now time.Time) error {
+	lock := oas.onAir.Lock
+	if !lock.Active(now) {
+		return nil
+	}
+
+	userID, _ := acontext.UserID(ctx)
+	isHolder := SourceFrom(ctx) == entities.SourceManual && userID == lock.Holder
+	if isHolder || acontext.UserRole(ctx) == string(entities.RoleAdmin) {
+		return nil
+	}
+
+	holder := lock.Holder
+	if holder == "" {
+		holder = "an anonymous user"
+	}
+	var until, reason string
+	if lock.ExpiresAt.Valid {
+		until = " until " + lock.ExpiresAt.Time.UTC().Format(time.RFC3339)
+	}
+	if lock.Reason != "" {
+		reason = ": " + lock.Reason
+	}
+
+	return fmt.Errorf("%w by %s%s%s", ErrLocked, holder, until, reason)
+}
+
+// scheduleUnlock releases the current lock when it expires, replacing any
+// previously scheduled release.
+// It must be called with the lock held.
+func (oas *onAirService) scheduleUnlock(wl wlog.Logger) {
+	if oas.lockTimer != nil {
+		oas.lockTimer.Stop()
+		oas.lockTimer = nil
+	}
+
+	lock := oas.onAir.Lock
+	if lock == nil || !lock.ExpiresAt.Valid {
+		return
+	}
+
+	oas.lockTimer = oas.clock.AfterFunc(lock.ExpiresAt.Time.Sub(oas.clock.Now()), func() {
+		oas.mu.Lock()
+		// the lock may have been replaced or released in the meantime
+		if oas.onAir.Lock != lock {
+			oas.mu.Unlock()
+			return
+		}
+
+		oas.onAir.Lock = nil
+		oas.lockTimer = nil
+		updated := oas.commit(oas.onAir.IsOnAir, lock.ExpiresAt.Time)
+		oas.mu.Unlock()
+
+		wl.Info("status lock expired")
+		oas.notify(context.Background(), wl, updated)
+	})
+}
@@ -2,11 +2,10 @@ package onair
 
 import (
 	"context"
+	"errors"
 	"on-air/internal/entities"
 	"on-air/internal/wlog"
 	"time"
-
-	"github.com/guregu/null"
 )
 
 func (oas *onAirService) SetOnAirStatus(
@@ -14,24 +13,25 @@ func (oas *onAirService) SetOnAirStatus(
 	wl wlog.Logger,
 	onAir entities.OnAirStatus,
 ) (entities.OnAirStatus, error) {
-
-	oas.onAir = onAir
-	oas.onAir.LastUpdated = null.TimeFrom(time.Now())
-
-	if onAir.IsOnAir {
-		oas.onAir.LastOnAir = null.TimeFrom(time.Now())
+	updated, err := oas.Claim(ctx, wl, entities.Claim{
+		Source:  SourceFrom(ctx),
+		IsOnAir: onAir.IsOnAir,
+		Message: onAir.Message,
+	})
+	if err != nil {
+		return entities.OnAirStatus{}, err
 	}
 
-	wl.Debugf("setting onAir: %v", oas.onAir)
-
-	return oas.onAir, nil
-
+	wl.Debugf("setting onAir: %v", updated)
+	return updated, nil
 }
 
 func (oas *onAirService) GetOnAirStatus(
 	ctx context.Context,
 	wl wlog.Logger,
 ) (entities.OnAirStatus, error) {
+	oas.mu.RLock()
+	defer oas.mu.RUnlock()
 
 	wl.Debugf("getting onAir: %v", oas.onAir)
 
@@ -42,14 +42,69 @@ func (oas *onAirService) ToggleOnAirStatus(
 	ctx context.Context,
 	wl wlog.Logger,
 ) (entities.OnAirStatus, error) {
-	oas.onAir.LastUpdated = null.TimeFrom(time.Now())
+	source := SourceFrom(ctx)
+	updated, err := oas.update(ctx, wl, source, func(now time.Time) ([]entities.Claim, bool) {
+		return withClaim(oas.onAir.Claims, entities.Claim{
+			Source:    source,
+			IsOnAir:   !oas.onAir.IsOnAir,
+			Message:   oas.onAir.Message,
+			ClaimedAt: now,
+		}), true
+	})
+	if err != nil {
+		return entities.OnAirStatus{}, err
+	}
+
+	wl.Debugf("toggling onAir: %v", updated)
+	return updated, nil
+}
+
+func (oas *onAirService) WaitForChange(
+	ctx context.Context,
+	wl wlog.Logger,
+	since uint64,
+) (entities.OnAirStatus, error) {
+	for {
+		oas.mu.RLock()
+		onAir, changed := oas.onAir, oas.changed
+		oas.mu.RUnlock()
+
+		if onAir.Revision > since {
+			wl.Debugf("onAir changed since revision %d: %v", since, onAir)
+			return onAir, nil
+		}
 
-	if oas.onAir.IsOnAir {
-		wl.Debug("currenty on air, setting to off and setting last on air")
-		oas.onAir.LastOnAir = null.TimeFrom(time.Now())
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			// a deadline is the expected way for a wait to end
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				wl.Debugf("no onAir change since revision %d", since)
+				return onAir, nil
+			}
+			return onAir, ctx.Err()
+		}
 	}
+}
 
-	oas.onAir.IsOnAir = !oas.onAir.IsOnAir
-	wl.Debugf("toggling onAir: %v", oas.onAir)
-	return oas.onAir, nil
+func (oas *onAirService) GetHistory(
+	ctx context.Context,
+	wl wlog.Logger,
+	limit int,
+) ([]entities.Transition, error) {
+	oas.mu.RLock()
+	defer oas.mu.RUnlock()
+
+	if limit <= 0 || limit > len(oas.history) {
+		limit = len(oas.history)
+	}
+
+	history := make([]entities.Transition, 0, limit)
+	for i := len(oas.history) - 1; i >= len(oas.history)-limit; i-- {
+		history = append(history, oas.history[i])
+	}
+
+	wl.Debugf("getting %d onAir transitions", len(history))
+
+	return history, nil
 }
@@ -0,0 +1,794 @@
+package onair_test
+
+import (
+	"context"
+	"on-air/internal/acontext"
+	"on-air/internal/clock"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"gotest.tools/v3/assert"
+)
+
+// t0 is when every test service is created.
+var t0 = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// at returns the time d after t0.
+func at(d time.Duration) time.Time {
+	return t0.Add(d)
+}
+
+// since returns the time d after t0 as a null.Time.
+func since(d time.Duration) null.Time {
+	return null.TimeFrom(at(d))
+}
+
+// action changes the service at now on behalf of the user in the context.
+type action func(ctx context.Context, svc onair.SVC, now time.Time) (entities.OnAirStatus, error)
+
+func set(isOnAir bool, message string) action {
+	return func(ctx context.Context, svc onair.SVC, now time.Time) (entities.OnAirStatus, error) {
+		return svc.SetOnAirStatus(ctx, wlog.NewNopLogger(), entities.OnAirStatus{IsOnAir: isOnAir, Message: message})
+	}
+}
+
+func toggle() action {
+	return func(ctx context.Context, svc onair.SVC, now time.Time) (entities.OnAirStatus, error) {
+		return svc.ToggleOnAirStatus(ctx, wlog.NewNopLogger())
+	}
+}
+
+// claim claims the status for the source, until ttl has elapsed when set.
+func claim(source entities.Source, isOnAir bool, message string, ttl time.Duration) action {
+	return func(ctx context.Context, svc onair.SVC, now time.Time) (entities.OnAirStatus, error) {
+		c := entities.Claim{Source: source, IsOnAir: isOnAir, Message: message}
+		if ttl > 0 {
+			c.ExpiresAt = null.TimeFrom(now.Add(ttl))
+		}
+		return svc.Claim(ctx, wlog.NewNopLogger(), c)
+	}
+}
+
+func release(source entities.Source) action {
+	return func(ctx context.Context, svc onair.SVC, now time.Time) (entities.OnAirStatus, error) {
+		return svc.Release(ctx, wlog.NewNopLogger(), source)
+	}
+}
+
+// lock locks the status, until ttl has elapsed when set.
+func lock(ttl time.Duration) action {
+	return func(ctx context.Context, svc onair.SVC, now time.Time) (entities.OnAirStatus, error) {
+		var expiresAt null.Time
+		if ttl > 0 {
+			expiresAt = null.TimeFrom(now.Add(ttl))
+		}
+		return svc.Lock(ctx, wlog.NewNopLogger(), "recording", expiresAt)
+	}
+}
+
+func unlock() action {
+	return func(ctx context.Context, svc onair.SVC, now time.Time) (entities.OnAirStatus, error) {
+		return svc.Unlock(ctx, wlog.NewNopLogger())
+	}
+}
+
+// restore replaces the state by the one returned by state.
+func restore(state func(now time.Time) onair.State) action {
+	return func(ctx context.Context, svc onair.SVC, now time.Time) (entities.OnAirStatus, error) {
+		if err := svc.Restore(ctx, wlog.NewNopLogger(), state(now)); err != nil {
+			return entities.OnAirStatus{}, err
+		}
+		return svc.GetOnAirStatus(ctx, wlog.NewNopLogger())
+	}
+}
+
+// status is the part of entities.OnAirStatus checked by the steps.
+type status struct {
+	IsOnAir   bool
+	Message   string
+	Source    entities.Source
+	LastOnAir null.Time
+	Locked    bool
+	Claims    []entities.Source
+}
+
+func statusOf(s entities.OnAirStatus) status {
+	claims := []entities.Source{}
+	for _, c := range s.Claims {
+		claims = append(claims, c.Source)
+	}
+	return status{
+		IsOnAir:   s.IsOnAir,
+		Message:   s.Message,
+		Source:    s.Source,
+		LastOnAir: s.LastOnAir,
+		Locked:    s.Lock != nil,
+		Claims:    claims,
+	}
+}
+
+// transition is the part of entities.Transition checked by the cases.
+type transition struct {
+	At      time.Time
+	IsOnAir bool
+}
+
+// session is the part of entities.Session checked by the cases.
+type session struct {
+	Start time.Time
+	End   null.Time
+}
+
+type step struct {
+	name string
+	// after is how long the clock is advanced before the step
+	after time.Duration
+	// do is the step, the status is only read when nil
+	do action
+	// user and role run the step on behalf of an authenticated user
+	user string
+	role entities.Role
+	// source runs the step on behalf of an integration
+	source  entities.Source
+	wantErr error
+	// want is the status once the step is done
+	want status
+}
+
+func TestOnAir(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   onair.Config
+		limit int
+		steps []step
+		// wantHistory and wantSessions are oldest first, and not checked when nil
+		wantHistory  []transition
+		wantSessions []session
+	}{
+		{
+			name: "set on and off air",
+			steps: []step{
+				{name: "initial", want: status{Claims: []entities.Source{}}},
+				{
+					name: "on", after: time.Minute, do: set(true, "live"),
+					want: status{IsOnAir: true, Message: "live", Source: entities.SourceManual, LastOnAir: since(time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "new message", after: time.Minute, do: set(true, "still live"),
+					want: status{IsOnAir: true, Message: "still live", Source: entities.SourceManual, LastOnAir: since(2 * time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "off", after: 10 * time.Minute, do: set(false, ""),
+					want: status{Source: entities.SourceManual, LastOnAir: since(12 * time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "off again", after: time.Hour, do: set(false, ""),
+					want: status{Source: entities.SourceManual, LastOnAir: since(12 * time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+			},
+			wantHistory: []transition{
+				{At: at(0)},
+				{At: at(time.Minute), IsOnAir: true},
+				{At: at(2 * time.Minute), IsOnAir: true},
+				{At: at(12 * time.Minute)},
+			},
+			wantSessions: []session{
+				{Start: at(time.Minute), End: since(12 * time.Minute)},
+			},
+		},
+		{
+			name: "toggle",
+			steps: []step{
+				{
+					name: "on", after: time.Minute, do: toggle(),
+					want: status{IsOnAir: true, Source: entities.SourceManual, LastOnAir: since(time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "off", after: time.Minute, do: toggle(),
+					want: status{Source: entities.SourceManual, LastOnAir: since(2 * time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "on again", after: time.Minute, do: toggle(),
+					want: status{IsOnAir: true, Source: entities.SourceManual, LastOnAir: since(3 * time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+			},
+			wantSessions: []session{
+				{Start: at(time.Minute), End: since(2 * time.Minute)},
+				{Start: at(3 * time.Minute)},
+			},
+		},
+		{
+			name:  "history and sessions limit",
+			limit: 2,
+			steps: []step{
+				{name: "on", do: set(true, "1"), want: status{IsOnAir: true, Message: "1", Source: entities.SourceManual,
+					LastOnAir: since(0), Claims: []entities.Source{entities.SourceManual}}},
+				{name: "off", after: time.Minute, do: set(false, ""), want: status{Source: entities.SourceManual,
+					LastOnAir: since(time.Minute), Claims: []entities.Source{entities.SourceManual}}},
+				{name: "on again", after: time.Minute, do: set(true, "2"), want: status{IsOnAir: true, Message: "2",
+					Source: entities.SourceManual, LastOnAir: since(2 * time.Minute), Claims: []entities.Source{entities.SourceManual}}},
+				{name: "off again", after: time.Minute, do: set(false, ""), want: status{Source: entities.SourceManual,
+					LastOnAir: since(3 * time.Minute), Claims: []entities.Source{entities.SourceManual}}},
+				{name: "on once more", after: time.Minute, do: set(true, "3"), want: status{IsOnAir: true, Message: "3",
+					Source: entities.SourceManual, LastOnAir: since(4 * time.Minute), Claims: []entities.Source{entities.SourceManual}}},
+			},
+			wantHistory: []transition{
+				{At: at(3 * time.Minute)},
+				{At: at(4 * time.Minute), IsOnAir: true},
+			},
+			wantSessions: []session{
+				{Start: at(2 * time.Minute), End: since(3 * time.Minute)},
+				{Start: at(4 * time.Minute)},
+			},
+		},
+		{
+			name: "claims by priority",
+			steps: []step{
+				{
+					name: "calendar on", do: claim(entities.SourceCalendar, true, "meeting", 0),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "schedule off outranks the calendar", after: time.Minute, do: claim(entities.SourceSchedule, false, "", 0),
+					want: status{Source: entities.SourceSchedule, LastOnAir: since(time.Minute),
+						Claims: []entities.Source{entities.SourceSchedule, entities.SourceCalendar}},
+				},
+				{
+					name: "manual on outranks them all", after: time.Minute, do: claim(entities.SourceManual, true, "live", time.Hour),
+					want: status{IsOnAir: true, Message: "live", Source: entities.SourceManual, LastOnAir: since(2 * time.Minute),
+						Claims: []entities.Source{entities.SourceManual, entities.SourceSchedule, entities.SourceCalendar}},
+				},
+				{
+					name: "lower claims change nothing", after: time.Minute, do: claim(entities.SourcePubSub, false, "", 0),
+					want: status{IsOnAir: true, Message: "live", Source: entities.SourceManual, LastOnAir: since(3 * time.Minute),
+						Claims: []entities.Source{entities.SourceManual, entities.SourcePubSub, entities.SourceSchedule, entities.SourceCalendar}},
+				},
+				{
+					name: "falls back to the next claim", after: time.Minute, do: release(entities.SourceManual),
+					want: status{Source: entities.SourcePubSub, LastOnAir: since(4 * time.Minute),
+						Claims: []entities.Source{entities.SourcePubSub, entities.SourceSchedule, entities.SourceCalendar}},
+				},
+				{
+					name: "releasing twice", after: time.Minute, do: release(entities.SourceManual),
+					want: status{Source: entities.SourcePubSub, LastOnAir: since(4 * time.Minute),
+						Claims: []entities.Source{entities.SourcePubSub, entities.SourceSchedule, entities.SourceCalendar}},
+				},
+				{name: "release pubsub", do: release(entities.SourcePubSub), want: status{Source: entities.SourceSchedule,
+					LastOnAir: since(4 * time.Minute), Claims: []entities.Source{entities.SourceSchedule, entities.SourceCalendar}}},
+				{
+					name: "back to the calendar", after: time.Minute, do: release(entities.SourceSchedule),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(6 * time.Minute),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "off without claims", after: time.Minute, do: release(entities.SourceCalendar),
+					want: status{LastOnAir: since(7 * time.Minute), Claims: []entities.Source{}},
+				},
+			},
+			wantSessions: []session{
+				{Start: at(0), End: since(time.Minute)},
+				{Start: at(2 * time.Minute), End: since(4 * time.Minute)},
+				{Start: at(6 * time.Minute), End: since(7 * time.Minute)},
+			},
+		},
+		{
+			name: "manual claims without expiry",
+			steps: []step{
+				{
+					name: "calendar on", do: claim(entities.SourceCalendar, true, "meeting", 0),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "toggled off", after: time.Minute, do: toggle(),
+					want: status{Message: "meeting", Source: entities.SourceManual, LastOnAir: since(time.Minute),
+						Claims: []entities.Source{entities.SourceManual, entities.SourceCalendar}},
+				},
+				{
+					name: "renewing the same claim keeps the manual one", after: time.Minute, do: claim(entities.SourceCalendar, true, "meeting", 0),
+					want: status{Message: "meeting", Source: entities.SourceManual, LastOnAir: since(time.Minute),
+						Claims: []entities.Source{entities.SourceManual, entities.SourceCalendar}},
+				},
+				{
+					name: "released by the next calendar change", after: time.Minute, do: release(entities.SourceCalendar),
+					source: entities.SourceCalendar,
+					want:   status{LastOnAir: since(time.Minute), Claims: []entities.Source{}},
+				},
+			},
+		},
+		{
+			name: "configured priority",
+			cfg:  onair.Config{SourcePriority: []string{"calendar"}},
+			steps: []step{
+				{
+					name: "manual on", do: claim(entities.SourceManual, true, "live", time.Hour),
+					want: status{IsOnAir: true, Message: "live", Source: entities.SourceManual, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "calendar off outranks manual", after: time.Minute, do: claim(entities.SourceCalendar, false, "", 0),
+					want: status{Source: entities.SourceCalendar, LastOnAir: since(time.Minute),
+						Claims: []entities.Source{entities.SourceCalendar, entities.SourceManual}},
+				},
+			},
+		},
+		{
+			name: "claim expiry",
+			steps: []step{
+				{
+					name: "manual on for 10m", do: claim(entities.SourceManual, true, "live", 10*time.Minute),
+					want: status{IsOnAir: true, Message: "live", Source: entities.SourceManual, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "calendar on for 30m", after: time.Minute, do: claim(entities.SourceCalendar, true, "meeting", 30*time.Minute),
+					want: status{IsOnAir: true, Message: "live", Source: entities.SourceManual, LastOnAir: since(time.Minute),
+						Claims: []entities.Source{entities.SourceManual, entities.SourceCalendar}},
+				},
+				{
+					name: "before the manual claim expires", after: 9*time.Minute - time.Second,
+					want: status{IsOnAir: true, Message: "live", Source: entities.SourceManual, LastOnAir: since(time.Minute),
+						Claims: []entities.Source{entities.SourceManual, entities.SourceCalendar}},
+				},
+				{
+					name: "falls back to the calendar", after: time.Second,
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(10 * time.Minute),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "off once the calendar claim expires", after: time.Hour,
+					want: status{LastOnAir: since(31 * time.Minute), Claims: []entities.Source{}},
+				},
+			},
+			wantSessions: []session{
+				{Start: at(0), End: since(31 * time.Minute)},
+			},
+		},
+		{
+			name: "claim renewed before it expires",
+			steps: []step{
+				{
+					name: "on for 10m", do: claim(entities.SourceSchedule, true, "show", 10*time.Minute),
+					want: status{IsOnAir: true, Message: "show", Source: entities.SourceSchedule, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceSchedule}},
+				},
+				{
+					name: "renewed for 10m", after: 5 * time.Minute, do: claim(entities.SourceSchedule, true, "show", 10*time.Minute),
+					want: status{IsOnAir: true, Message: "show", Source: entities.SourceSchedule, LastOnAir: since(5 * time.Minute),
+						Claims: []entities.Source{entities.SourceSchedule}},
+				},
+				{
+					name: "past the first expiry", after: 5 * time.Minute,
+					want: status{IsOnAir: true, Message: "show", Source: entities.SourceSchedule, LastOnAir: since(5 * time.Minute),
+						Claims: []entities.Source{entities.SourceSchedule}},
+				},
+				{
+					name: "past the renewed expiry", after: 5 * time.Minute,
+					want: status{LastOnAir: since(15 * time.Minute), Claims: []entities.Source{}},
+				},
+			},
+		},
+		{
+			name: "lock",
+			steps: []step{
+				{
+					name: "locked by alice", user: "alice", role: entities.RoleOperator, do: lock(0),
+					want: status{Locked: true, Claims: []entities.Source{}},
+				},
+				{name: "bob can't set", user: "bob", role: entities.RoleOperator, do: set(true, "bob"), wantErr: onair.ErrLocked,
+					want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "integrations can't claim", do: claim(entities.SourcePubSub, true, "", 0), wantErr: onair.ErrLocked,
+					want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "bob can't toggle", user: "bob", role: entities.RoleOperator, do: toggle(), wantErr: onair.ErrLocked,
+					want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "bob can't release", user: "bob", role: entities.RoleOperator, do: release(entities.SourceManual),
+					wantErr: onair.ErrLocked, want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "bob can't take the lock", user: "bob", role: entities.RoleOperator, do: lock(0), wantErr: onair.ErrLocked,
+					want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "bob can't unlock", user: "bob", role: entities.RoleOperator, do: unlock(), wantErr: onair.ErrLocked,
+					want: status{Locked: true, Claims: []entities.Source{}}},
+				{
+					name: "alice can set", after: time.Minute, user: "alice", role: entities.RoleOperator, do: set(true, "alice"),
+					want: status{IsOnAir: true, Message: "alice", Source: entities.SourceManual, LastOnAir: since(time.Minute),
+						Locked: true, Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "admins can set", after: time.Minute, user: "carol", role: entities.RoleAdmin, do: set(true, "carol"),
+					want: status{IsOnAir: true, Message: "carol", Source: entities.SourceManual, LastOnAir: since(2 * time.Minute),
+						Locked: true, Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "alice unlocks", user: "alice", role: entities.RoleOperator, do: unlock(),
+					want: status{IsOnAir: true, Message: "carol", Source: entities.SourceManual, LastOnAir: since(2 * time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "bob can set", after: time.Minute, user: "bob", role: entities.RoleOperator, do: set(false, ""),
+					want: status{Source: entities.SourceManual, LastOnAir: since(3 * time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+			},
+		},
+		{
+			name: "anonymous lock",
+			steps: []step{
+				{name: "locked without auth", do: lock(0), want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "the calendar can't claim", do: claim(entities.SourceCalendar, true, "meeting", 0),
+					wantErr: onair.ErrLocked, want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "pubsub can't set", source: entities.SourcePubSub, do: set(true, ""),
+					wantErr: onair.ErrLocked, want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "home assistant can't toggle", source: entities.SourceHomeAssistant, do: toggle(),
+					wantErr: onair.ErrLocked, want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "the schedules can't release", source: entities.SourceSchedule, do: release(entities.SourceSchedule),
+					wantErr: onair.ErrLocked, want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "integrations can't unlock", source: entities.SourcePubSub, do: unlock(),
+					wantErr: onair.ErrLocked, want: status{Locked: true, Claims: []entities.Source{}}},
+				{
+					name: "anonymous users can toggle", after: time.Minute, do: toggle(),
+					want: status{IsOnAir: true, Source: entities.SourceManual, LastOnAir: since(time.Minute), Locked: true,
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "anonymous users can unlock", do: unlock(),
+					want: status{IsOnAir: true, Source: entities.SourceManual, LastOnAir: since(time.Minute),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+			},
+		},
+		{
+			name: "lock expiry",
+			steps: []step{
+				{
+					name: "locked for an hour", user: "alice", do: lock(time.Hour),
+					want: status{Locked: true, Claims: []entities.Source{}},
+				},
+				{name: "still locked", after: time.Hour - time.Second, user: "bob", do: set(true, ""), wantErr: onair.ErrLocked,
+					want: status{Locked: true, Claims: []entities.Source{}}},
+				{name: "released when it expires", after: time.Second, want: status{Claims: []entities.Source{}}},
+				{
+					name: "bob can set", user: "bob", do: set(true, "bob"),
+					want: status{IsOnAir: true, Message: "bob", Source: entities.SourceManual, LastOnAir: since(time.Hour),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+			},
+			wantHistory: []transition{
+				{At: at(0)},
+				{At: at(0)},
+				{At: at(time.Hour)},
+				{At: at(time.Hour), IsOnAir: true},
+			},
+		},
+		{
+			name: "restored expired lock",
+			steps: []step{
+				{
+					name: "restored locked",
+					do: restore(func(now time.Time) onair.State {
+						return onair.State{Status: entities.OnAirStatus{
+							Revision: 5,
+							Claims:   []entities.Claim{},
+							Lock: &entities.Lock{
+								Holder: "alice", LockedAt: now.Add(-time.Hour), ExpiresAt: null.TimeFrom(now.Add(-time.Minute)),
+							},
+						}}
+					}),
+					want: status{Locked: true, Claims: []entities.Source{}},
+				},
+				{name: "released right away", want: status{Claims: []entities.Source{}}},
+			},
+		},
+		{
+			name: "restored claims expire",
+			steps: []step{
+				{
+					name: "restored on air",
+					do: restore(func(now time.Time) onair.State {
+						return onair.State{Status: entities.OnAirStatus{
+							Revision:  5,
+							IsOnAir:   true,
+							Message:   "meeting",
+							Source:    entities.SourceCalendar,
+							LastOnAir: null.TimeFrom(now),
+							Claims: []entities.Claim{{
+								Source: entities.SourceCalendar, IsOnAir: true, Message: "meeting",
+								ClaimedAt: now.Add(-time.Hour), ExpiresAt: null.TimeFrom(now.Add(time.Minute)),
+							}},
+						}}
+					}),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "off once expired", after: time.Minute,
+					want: status{LastOnAir: since(time.Minute), Claims: []entities.Source{}},
+				},
+			},
+		},
+		{
+			name: "minimum on air",
+			cfg:  onair.Config{MinOnAir: 10 * time.Minute},
+			steps: []step{
+				{
+					name: "calendar on", do: claim(entities.SourceCalendar, true, "meeting", 0),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "off deferred", after: 2 * time.Minute, do: release(entities.SourceCalendar),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(0),
+						Claims: []entities.Source{}},
+				},
+				{
+					name: "still on", after: 8*time.Minute - time.Second,
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(0),
+						Claims: []entities.Source{}},
+				},
+				{
+					name: "off once due", after: time.Second,
+					want: status{LastOnAir: since(10 * time.Minute), Claims: []entities.Source{}},
+				},
+			},
+			wantSessions: []session{
+				{Start: at(0), End: since(10 * time.Minute)},
+			},
+		},
+		{
+			name: "cool-down",
+			cfg:  onair.Config{Cooldown: 5 * time.Minute},
+			steps: []step{
+				{
+					name: "calendar on", do: claim(entities.SourceCalendar, true, "meeting", 0),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "off right away", after: time.Minute, do: release(entities.SourceCalendar),
+					want: status{LastOnAir: since(time.Minute), Claims: []entities.Source{}},
+				},
+				{
+					name: "on deferred", after: time.Minute, do: claim(entities.SourceCalendar, true, "meeting", 0),
+					want: status{LastOnAir: since(time.Minute), Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "on once due", after: 4 * time.Minute,
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(6 * time.Minute),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+			},
+			wantSessions: []session{
+				{Start: at(0), End: since(time.Minute)},
+				{Start: at(6 * time.Minute)},
+			},
+		},
+		{
+			name: "reverted transition suppressed",
+			cfg:  onair.Config{MinOnAir: 10 * time.Minute},
+			steps: []step{
+				{
+					name: "calendar on", do: claim(entities.SourceCalendar, true, "meeting", 0),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "off deferred", after: time.Minute, do: release(entities.SourceCalendar),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(0),
+						Claims: []entities.Source{}},
+				},
+				{
+					name: "on again", after: time.Minute, do: claim(entities.SourceCalendar, true, "meeting", 0),
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(2 * time.Minute),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+				{
+					name: "never went off", after: time.Hour,
+					want: status{IsOnAir: true, Message: "meeting", Source: entities.SourceCalendar, LastOnAir: since(2 * time.Minute),
+						Claims: []entities.Source{entities.SourceCalendar}},
+				},
+			},
+			wantSessions: []session{
+				{Start: at(0)},
+			},
+		},
+		{
+			name: "manual changes skip the hysteresis",
+			cfg:  onair.Config{MinOnAir: 10 * time.Minute, Cooldown: 10 * time.Minute, CoalesceWindow: time.Minute},
+			steps: []step{
+				{
+					name: "on", do: set(true, "live"),
+					want: status{IsOnAir: true, Message: "live", Source: entities.SourceManual, LastOnAir: since(0),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "off", after: time.Second, do: set(false, ""),
+					want: status{Source: entities.SourceManual, LastOnAir: since(time.Second),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+				{
+					name: "toggled on", after: time.Second, do: toggle(),
+					want: status{IsOnAir: true, Source: entities.SourceManual, LastOnAir: since(2 * time.Second),
+						Claims: []entities.Source{entities.SourceManual}},
+				},
+			},
+		},
+		{
+			name: "coalesce window",
+			cfg:  onair.Config{CoalesceWindow: time.Minute},
+			steps: []step{
+				{
+					name: "on deferred", do: claim(entities.SourcePubSub, true, "live", 0),
+					want: status{Claims: []entities.Source{entities.SourcePubSub}},
+				},
+				{
+					name: "flapped off", after: 30 * time.Second, do: claim(entities.SourcePubSub, false, "", 0),
+					want: status{Source: entities.SourcePubSub, Claims: []entities.Source{entities.SourcePubSub}},
+				},
+				{
+					name: "never went on", after: time.Hour - 30*time.Second,
+					want: status{Source: entities.SourcePubSub, Claims: []entities.Source{entities.SourcePubSub}},
+				},
+				{
+					name: "on deferred again", do: claim(entities.SourcePubSub, true, "live", 0),
+					want: status{Source: entities.SourcePubSub, Claims: []entities.Source{entities.SourcePubSub}},
+				},
+				{
+					name: "on once the window passed", after: time.Minute,
+					want: status{IsOnAir: true, Message: "live", Source: entities.SourcePubSub, LastOnAir: since(61 * time.Minute),
+						Claims: []entities.Source{entities.SourcePubSub}},
+				},
+			},
+			wantSessions: []session{
+				{Start: at(61 * time.Minute)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wl := wlog.NewNopLogger()
+			clk := clock.NewFake(t0)
+
+			svc, err := onair.New(onair.WithClock(clk), onair.WithConfig(&tt.cfg), onair.WithHistoryLimit(tt.limit))
+			assert.NilError(t, err)
+
+			for _, s := range tt.steps {
+				clk.Advance(s.after)
+
+				ctx := context.Background()
+				if s.user != "" {
+					ctx = acontext.WithUserID(ctx, s.user)
+					ctx = acontext.WithUserRole(ctx, string(s.role))
+				}
+				if s.source != "" {
+					ctx = onair.WithSource(ctx, s.source)
+				}
+
+				// the steps run in order, a failed step fails the ones after it
+				ok := t.Run(s.name, func(t *testing.T) {
+					if s.do != nil {
+						got, err := s.do(ctx, svc, clk.Now())
+						if s.wantErr != nil {
+							assert.ErrorIs(t, err, s.wantErr)
+						} else {
+							assert.NilError(t, err)
+							assert.DeepEqual(t, statusOf(got), s.want)
+						}
+					}
+
+					got, err := svc.GetOnAirStatus(ctx, wl)
+					assert.NilError(t, err)
+					assert.DeepEqual(t, statusOf(got), s.want)
+				})
+				if !ok {
+					return
+				}
+			}
+
+			if tt.wantHistory != nil {
+				history, err := svc.GetHistory(context.Background(), wl, onair.DefaultHistoryLimit)
+				assert.NilError(t, err)
+				got := []transition{}
+				for i := len(history) - 1; i >= 0; i-- {
+					got = append(got, transition{At: history[i].At, IsOnAir: history[i].IsOnAir})
+				}
+				assert.DeepEqual(t, got, tt.wantHistory)
+			}
+
+			if tt.wantSessions != nil {
+				sessions, err := svc.ListSessions(context.Background(), wl, entities.SessionFilter{})
+				assert.NilError(t, err)
+				got := []session{}
+				for i := len(sessions) - 1; i >= 0; i-- {
+					got = append(got, session{Start: sessions[i].Start, End: sessions[i].End})
+				}
+				assert.DeepEqual(t, got, tt.wantSessions)
+			}
+		})
+	}
+}
+
+func TestNotifiesStatusChangesOnly(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+	clk := clock.NewFake(t0)
+
+	var notified []entities.OnAirStatus
+	svc, err := onair.New(
+		onair.WithClock(clk),
+		onair.WithConfig(&onair.Config{CoalesceWindow: time.Minute}),
+		onair.WithListener(func(ctx context.Context, wl wlog.Logger, onAir entities.OnAirStatus) {
+			notified = append(notified, onAir)
+		}),
+	)
+	assert.NilError(t, err)
+
+	initial, err := svc.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+
+	// a deferred transition and a lower claim don't change the status
+	s, err := svc.Claim(ctx, wl, entities.Claim{Source: entities.SourcePubSub, IsOnAir: true, Message: "live"})
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsOnAir, false)
+	s, err = svc.Claim(ctx, wl, entities.Claim{Source: entities.SourceCalendar, IsOnAir: true, Message: "meeting"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(s.Claims), 2)
+	assert.Equal(t, s.Revision, initial.Revision)
+	assert.Equal(t, len(notified), 0)
+
+	// until the deferred transition is applied
+	clk.Advance(time.Minute)
+	s, err = svc.WaitForChange(ctx, wl, initial.Revision)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Message, "live")
+	assert.Equal(t, s.Revision, initial.Revision+1)
+	assert.Equal(t, len(notified), 1)
+}
+
+func TestNotifiesInRevisionOrder(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	var (
+		mu       sync.Mutex
+		notified []uint64
+	)
+	svc, err := onair.New(onair.WithListener(func(ctx context.Context, wl wlog.Logger, onAir entities.OnAirStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		notified = append(notified, onAir.Revision)
+	}))
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.ToggleOnAirStatus(ctx, wl)
+			assert.Check(t, err)
+		}()
+	}
+	wg.Wait()
+
+	final, err := svc.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+
+	// stale statuses are dropped, the listeners end on the final one
+	assert.Assert(t, len(notified) > 0)
+	for i := 1; i < len(notified); i++ {
+		assert.Assert(t, notified[i] > notified[i-1], "revision %d notified after %d", notified[i], notified[i-1])
+	}
+	assert.Equal(t, notified[len(notified)-1], final.Revision)
+}
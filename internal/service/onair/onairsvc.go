@@ -2,34 +2,274 @@ package onair
 
 import (
 	"context"
+	"on-air/internal/clock"
 	"on-air/internal/entities"
+	"on-air/internal/randid"
 	"on-air/internal/wlog"
+	"sync"
 	"time"
 
 	"github.com/guregu/null"
 )
 
+// Reader is the read-only part of SVC, also implemented by virtual channels.
+type Reader interface {
+	GetOnAirStatus(ctx context.Context, wl wlog.Logger) (entities.OnAirStatus, error)
+	// WaitForChange blocks until the status revision is greater than since
+	// or the context is done, and returns the current status.
+	WaitForChange(ctx context.Context, wl wlog.Logger, since uint64) (entities.OnAirStatus, error)
+}
+
 type SVC interface {
 	SetOnAirStatus(ctx context.Context, wl wlog.Logger, onAir entities.OnAirStatus) (entities.OnAirStatus, error)
 	GetOnAirStatus(ctx context.Context, wl wlog.Logger) (entities.OnAirStatus, error)
 	ToggleOnAirStatus(ctx context.Context, wl wlog.Logger) (entities.OnAirStatus, error)
+	// WaitForChange blocks until the status revision is greater than since
+	// or the context is done, and returns the current status.
+	WaitForChange(ctx context.Context, wl wlog.Logger, since uint64) (entities.OnAirStatus, error)
+	// GetHistory returns up to limit of the most recent transitions, newest first.
+	GetHistory(ctx context.Context, wl wlog.Logger, limit int) ([]entities.Transition, error)
+	// EachTransition calls fn for every transition in the range, oldest first.
+	// The transitions are read page by page and fn runs without holding any lock.
+	EachTransition(ctx context.Context, wl wlog.Logger, from null.Time, to null.Time, fn func(entities.Transition) error) error
+	// EachSession calls fn for every session matching the filter, oldest first.
+	// The sessions are read page by page and fn runs without holding any lock.
+	EachSession(ctx context.Context, wl wlog.Logger, filter entities.SessionFilter, fn func(entities.Session) error) error
+	// ListSessions returns the sessions matching the filter, newest first.
+	ListSessions(ctx context.Context, wl wlog.Logger, filter entities.SessionFilter) ([]entities.Session, error)
+	// SessionsSince returns the start of the oldest session kept once the
+	// sessions reach the history limit, the older ones may have been dropped.
+	// It's null while every session is kept.
+	SessionsSince(ctx context.Context, wl wlog.Logger) (null.Time, error)
+	// GetSession returns a single session.
+	GetSession(ctx context.Context, wl wlog.Logger, id string) (entities.Session, error)
+	// Claim sets the status wanted by the source of the claim, replacing its
+	// previous claim. The status is the one of the active claim with the
+	// highest priority. SetOnAirStatus and ToggleOnAirStatus claim the status
+	// for the source of the context, see WithSource. A manual claim without
+	// expiry is dropped once another source changes its claim or its claim
+	// expires.
+	Claim(ctx context.Context, wl wlog.Logger, claim entities.Claim) (entities.OnAirStatus, error)
+	// Release drops the claim of the source, if any.
+	Release(ctx context.Context, wl wlog.Logger, source entities.Source) (entities.OnAirStatus, error)
+	// Lock keeps anyone but the caller and the admins from setting or
+	// toggling the status, until it's unlocked or expiresAt. The holder of
+	// the lock can lock again to change the reason or expiry.
+	Lock(ctx context.Context, wl wlog.Logger, reason string, expiresAt null.Time) (entities.OnAirStatus, error)
+	// Unlock releases the lock, if any. Only its holder and the admins can.
+	Unlock(ctx context.Context, wl wlog.Logger) (entities.OnAirStatus, error)
+	// UpdateSession edits the notes and tags of a session.
+	UpdateSession(ctx context.Context, wl wlog.Logger, id string, update entities.SessionUpdate) (entities.Session, error)
+	// Snapshot returns the status, up to historyTail of the most recent
+	// transitions and every session kept.
+	Snapshot(ctx context.Context, wl wlog.Logger, historyTail int) (State, error)
+	// Restore replaces the whole state, e.g. at startup. Listeners are not notified.
+	Restore(ctx context.Context, wl wlog.Logger, state State) error
+}
+
+// State is everything the service keeps in memory.
+type State struct {
+	Status entities.OnAirStatus
+	// History is oldest first
+	History []entities.Transition
+	// Sessions are oldest first
+	Sessions []entities.Session
+}
+
+// DefaultChannel is the ID of the channel served by the service.
+const DefaultChannel = "default"
+
+// DefaultHistoryLimit is the number of transitions, and sessions,
+// kept in memory by default.
+const DefaultHistoryLimit = 1000
+
+const (
+	sessionIDLen = 8
+	// pageSize is the number of items copied at once by the iterators
+	pageSize = 100
+)
+
+// Listener is called with the updated status every time it changes. The
+// listeners are called one status at a time, in revision order, and must not
+// change the status themselves.
+type Listener func(ctx context.Context, wl wlog.Logger, onAir entities.OnAirStatus)
+
+// Option configures the on air service.
+type Option func(*onAirService)
+
+// WithListener registers a Listener that is notified of every status change.
+func WithListener(l Listener) Option {
+	return func(oas *onAirService) {
+		if l != nil {
+			oas.listeners = append(oas.listeners, l)
+		}
+	}
+}
+
+// WithConfig configures the on air service, see Config.
+func WithConfig(cfg *Config) Option {
+	return func(oas *onAirService) {
+		if cfg != nil {
+			oas.cfg = cfg
+		}
+	}
+}
+
+// WithClock sets the clock telling the time of the transitions and
+// expiring the claims and locks, the system clock by default.
+func WithClock(c clock.Clock) Option {
+	return func(oas *onAirService) {
+		if c != nil {
+			oas.clock = c
+		}
+	}
+}
+
+// WithHistoryLimit sets how many transitions and sessions are kept in memory.
+func WithHistoryLimit(n int) Option {
+	return func(oas *onAirService) {
+		if n > 0 {
+			oas.historyLimit = n
+		}
+	}
 }
 
 type onAirService struct {
 	// add any dependencies here (DB, Client, etc.)
-	onAir entities.OnAirStatus
+	mu        sync.RWMutex
+	onAir     entities.OnAirStatus
+	listeners []Listener
+	// history holds the most recent transitions, oldest first
+	history      []entities.Transition
+	historyLimit int
+	// sessions holds the most recent sessions, oldest first
+	sessions []entities.Session
+	// changed is closed and replaced on every status change to wake up waiters
+	changed chan struct{}
+	// lockTimer releases an expiring lock
+	lockTimer clock.Timer
+	cfg       *Config
+	clock     clock.Clock
+	// priority ranks the claim sources, lowest first
+	priority map[entities.Source]int
+	// claimTimer drops the claims as they expire
+	claimTimer clock.Timer
+	// since is when the status last went on or off air, zero if it never did
+	since time.Time
+	// pendingTimer applies a transition deferred by the hysteresis
+	pendingTimer clock.Timer
+	// notifyMu serializes the notifications, notified is the revision the
+	// listeners were last notified of
+	notifyMu sync.Mutex
+	notified uint64
 }
 
-func New(
-// pass in any dependencies
-) (SVC, error) {
+func New(opts ...Option) (SVC, error) {
+	oas := &onAirService{
+		changed:      make(chan struct{}),
+		historyLimit: DefaultHistoryLimit,
+		cfg:          &Config{},
+		clock:        clock.Real{},
+	}
+	for _, opt := range opts {
+		opt(oas)
+	}
+
+	if err := oas.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	oas.priority = oas.cfg.priority()
 
 	// initiatlize onAir
-	initOnAir := entities.OnAirStatus{
+	oas.onAir = entities.OnAirStatus{
 		IsOnAir:     false,
-		LastUpdated: null.TimeFrom(time.Now()),
+		LastUpdated: null.TimeFrom(oas.clock.Now()),
 		LastOnAir:   null.Time{},
+		Revision:    1,
+	}
+	oas.record(oas.onAir.LastUpdated.Time)
+
+	return oas, nil
+}
+
+// commit bumps the revision, records the transition at the given time,
+// tracks sessions and wakes up any waiters. wasOnAir is the status before
+// the change. It must be called with the write lock held.
+func (oas *onAirService) commit(wasOnAir bool, at time.Time) entities.OnAirStatus {
+	oas.onAir.Revision++
+	oas.record(at)
+	oas.trackSession(wasOnAir)
+	close(oas.changed)
+	oas.changed = make(chan struct{})
+
+	return oas.onAir
+}
+
+// record appends the current status to the history, dropping the oldest
+// transitions past the history limit. Changes that only touch the claims
+// aren't recorded.
+// It must be called with the write lock held.
+func (oas *onAirService) record(at time.Time) {
+	if n := len(oas.history); n > 0 {
+		last := oas.history[n-1]
+		if last.IsOnAir == oas.onAir.IsOnAir && last.Message == oas.onAir.Message &&
+			last.Lock == oas.onAir.Lock && last.Source == oas.onAir.Source {
+			return
+		}
 	}
 
-	return &onAirService{onAir: initOnAir}, nil
+	oas.history = append(oas.history, entities.Transition{
+		Revision: oas.onAir.Revision,
+		IsOnAir:  oas.onAir.IsOnAir,
+		Message:  oas.onAir.Message,
+		At:       at,
+		Lock:     oas.onAir.Lock,
+		Source:   oas.onAir.Source,
+	})
+
+	if over := len(oas.history) - oas.historyLimit; over > 0 {
+		oas.history = append(oas.history[:0:0], oas.history[over:]...)
+	}
+}
+
+// trackSession starts a session when going on air and ends it when going off air.
+// It must be called with the write lock held.
+func (oas *onAirService) trackSession(wasOnAir bool) {
+	at := oas.onAir.LastUpdated.Time
+
+	switch {
+	case !wasOnAir && oas.onAir.IsOnAir:
+		oas.sessions = append(oas.sessions, entities.Session{
+			ID:    randid.New(sessionIDLen),
+			Start: at,
+			Notes: oas.onAir.Message,
+			Tags:  []string{},
+		})
+		if over := len(oas.sessions) - oas.historyLimit; over > 0 {
+			oas.sessions = append(oas.sessions[:0:0], oas.sessions[over:]...)
+		}
+	case wasOnAir && !oas.onAir.IsOnAir:
+		if n := len(oas.sessions); n > 0 && !oas.sessions[n-1].End.Valid {
+			oas.sessions[n-1].End = null.TimeFrom(at)
+		}
+	}
+}
+
+// notify passes the status to every registered listener, one status at a
+// time. A status older than the last one notified is dropped, so listeners
+// don't end on a stale status when concurrent updates race to notify.
+// It must be called without holding the lock.
+func (oas *onAirService) notify(ctx context.Context, wl wlog.Logger, onAir entities.OnAirStatus) {
+	oas.notifyMu.Lock()
+	defer oas.notifyMu.Unlock()
+
+	if onAir.Revision <= oas.notified {
+		wl.Debugf("dropping the notification of revision %d, revision %d was notified", onAir.Revision, oas.notified)
+		return
+	}
+	oas.notified = onAir.Revision
+
+	for _, l := range oas.listeners {
+		l(ctx, wl, onAir)
+	}
 }
@@ -0,0 +1,142 @@
+package onair
+
+import (
+	"context"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"strings"
+
+	"github.com/guregu/null"
+)
+
+func (oas *onAirService) ListSessions(
+	ctx context.Context,
+	wl wlog.Logger,
+	filter entities.SessionFilter,
+) ([]entities.Session, error) {
+	oas.mu.RLock()
+	defer oas.mu.RUnlock()
+
+	tag := normalizeTag(filter.Tag)
+	sessions := []entities.Session{}
+	for i := len(oas.sessions) - 1; i >= 0; i-- {
+		s := oas.sessions[i]
+
+		if tag != "" && !hasTag(s, tag) {
+			continue
+		}
+		if filter.To.Valid && !s.Start.Before(filter.To.Time) {
+			continue
+		}
+		if filter.From.Valid && s.End.Valid && s.End.Time.Before(filter.From.Time) {
+			continue
+		}
+
+		sessions = append(sessions, copySession(s))
+	}
+
+	wl.Debugf("found %d sessions", len(sessions))
+
+	return sessions, nil
+}
+
+func (oas *onAirService) SessionsSince(
+	ctx context.Context,
+	wl wlog.Logger,
+) (null.Time, error) {
+	oas.mu.RLock()
+	defer oas.mu.RUnlock()
+
+	if len(oas.sessions) < oas.historyLimit {
+		return null.Time{}, nil
+	}
+	return null.TimeFrom(oas.sessions[0].Start), nil
+}
+
+func (oas *onAirService) GetSession(
+	ctx context.Context,
+	wl wlog.Logger,
+	id string,
+) (entities.Session, error) {
+	oas.mu.RLock()
+	defer oas.mu.RUnlock()
+
+	i, err := oas.findSession(id)
+	if err != nil {
+		return entities.Session{}, err
+	}
+
+	return copySession(oas.sessions[i]), nil
+}
+
+func (oas *onAirService) UpdateSession(
+	ctx context.Context,
+	wl wlog.Logger,
+	id string,
+	update entities.SessionUpdate,
+) (entities.Session, error) {
+	oas.mu.Lock()
+	defer oas.mu.Unlock()
+
+	i, err := oas.findSession(id)
+	if err != nil {
+		return entities.Session{}, err
+	}
+
+	if update.Notes != nil {
+		oas.sessions[i].Notes = *update.Notes
+	}
+	if update.Tags != nil {
+		oas.sessions[i].Tags = normalizeTags(*update.Tags)
+	}
+
+	wl.Debugf("updated session %s", id)
+
+	return copySession(oas.sessions[i]), nil
+}
+
+// findSession returns the index of the session.
+// It must be called with the lock held.
+func (oas *onAirService) findSession(id string) (int, error) {
+	for i := len(oas.sessions) - 1; i >= 0; i-- {
+		if oas.sessions[i].ID == id {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+}
+
+// normalizeTags lowercases, trims and deduplicates the tags.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = normalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+func normalizeTag(t string) string {
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+func hasTag(s entities.Session, tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// copySession returns a copy of the session that doesn't share its tags.
+func copySession(s entities.Session) entities.Session {
+	s.Tags = append([]string{}, s.Tags...)
+	return s
+}
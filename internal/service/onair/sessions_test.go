@@ -0,0 +1,88 @@
+package onair_test
+
+import (
+	"context"
+	"on-air/internal/clock"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"gotest.tools/v3/assert"
+)
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+	clk := clock.NewFake(t0)
+
+	svc, err := onair.New(onair.WithClock(clk))
+	assert.NilError(t, err)
+
+	list := func(filter entities.SessionFilter) []session {
+		t.Helper()
+		sessions, err := svc.ListSessions(ctx, wl, filter)
+		assert.NilError(t, err)
+		got := []session{}
+		for _, s := range sessions {
+			got = append(got, session{Start: s.Start, End: s.End})
+		}
+		return got
+	}
+
+	// going on air opens a session, a new message keeps it running
+	_, err = svc.SetOnAirStatus(ctx, wl, entities.OnAirStatus{IsOnAir: true, Message: "live"})
+	assert.NilError(t, err)
+	clk.Advance(10 * time.Minute)
+	_, err = svc.SetOnAirStatus(ctx, wl, entities.OnAirStatus{IsOnAir: true, Message: "still live"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, list(entities.SessionFilter{}), []session{{Start: t0}})
+
+	// going off air closes it
+	clk.Advance(50 * time.Minute)
+	_, err = svc.ToggleOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	clk.Advance(time.Hour)
+	_, err = svc.ToggleOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+
+	first := session{Start: t0, End: since(time.Hour)}
+	second := session{Start: at(2 * time.Hour)}
+	assert.DeepEqual(t, list(entities.SessionFilter{}), []session{second, first})
+	assert.DeepEqual(t, list(entities.SessionFilter{From: since(90 * time.Minute)}), []session{second})
+	assert.DeepEqual(t, list(entities.SessionFilter{To: since(90 * time.Minute)}), []session{first})
+
+	sessions, err := svc.ListSessions(ctx, wl, entities.SessionFilter{})
+	assert.NilError(t, err)
+	id := sessions[1].ID
+
+	notes := "ep 1"
+	tags := []string{" Podcast", "podcast", "LIVE", ""}
+	updated, err := svc.UpdateSession(ctx, wl, id, entities.SessionUpdate{Notes: &notes, Tags: &tags})
+	assert.NilError(t, err)
+	assert.Equal(t, updated.Notes, "ep 1")
+	assert.DeepEqual(t, updated.Tags, []string{"podcast", "live"})
+
+	// omitted fields are left untouched
+	notes = "ep 1, take 2"
+	updated, err = svc.UpdateSession(ctx, wl, id, entities.SessionUpdate{Notes: &notes})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, updated.Tags, []string{"podcast", "live"})
+
+	// the returned sessions don't share their tags
+	updated.Tags[0] = "changed"
+	got, err := svc.GetSession(ctx, wl, id)
+	assert.NilError(t, err)
+	assert.Equal(t, got.Notes, "ep 1, take 2")
+	assert.Equal(t, got.End, null.TimeFrom(at(time.Hour)))
+	assert.DeepEqual(t, got.Tags, []string{"podcast", "live"})
+
+	assert.DeepEqual(t, list(entities.SessionFilter{Tag: " LIVE"}), []session{first})
+
+	_, err = svc.GetSession(ctx, wl, "nope")
+	assert.ErrorIs(t, err, onair.ErrSessionNotFound)
+	_, err = svc.UpdateSession(ctx, wl, "nope", entities.SessionUpdate{Notes: &notes})
+	assert.ErrorIs(t, err, onair.ErrSessionNotFound)
+}
@@ -0,0 +1,124 @@
+package onair
+
+import (
+	"context"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+)
+
+func (oas *onAirService) Snapshot(
+	ctx context.Context,
+	wl wlog.Logger,
+	historyTail int,
+) (State, error) {
+	oas.mu.RLock()
+	defer oas.mu.RUnlock()
+
+	history := oas.history
+	if historyTail >= 0 && len(history) > historyTail {
+		history = history[len(history)-historyTail:]
+	}
+
+	state := State{
+		Status:   oas.onAir,
+		History:  append([]entities.Transition{}, history...),
+		Sessions: make([]entities.Session, 0, len(oas.sessions)),
+	}
+	for _, s := range oas.sessions {
+		state.Sessions = append(state.Sessions, copySession(s))
+	}
+
+	return state, nil
+}
+
+func (oas *onAirService) Restore(
+	ctx context.Context,
+	wl wlog.Logger,
+	state State,
+) error {
+	if err := validateState(state); err != nil {
+		return err
+	}
+
+	// the restored revision may be lower than the last one notified
+	oas.notifyMu.Lock()
+	oas.notified = 0
+	oas.notifyMu.Unlock()
+
+	oas.mu.Lock()
+	defer oas.mu.Unlock()
+
+	oas.onAir = state.Status
+	oas.history = append([]entities.Transition{}, state.History...)
+	if over := len(oas.history) - oas.historyLimit; over > 0 {
+		oas.history = oas.history[over:]
+	}
+	oas.sessions = make([]entities.Session, 0, len(state.Sessions))
+	for _, s := range state.Sessions {
+		oas.sessions = append(oas.sessions, copySession(s))
+	}
+	if over := len(oas.sessions) - oas.historyLimit; over > 0 {
+		oas.sessions = oas.sessions[over:]
+	}
+
+	oas.since = lastFlip(oas.history)
+	oas.cancelPending()
+	oas.scheduleUnlock(wl)
+	oas.scheduleClaimExpiry(wl)
+
+	// wake up the waiters since the revision may have moved
+	close(oas.changed)
+	oas.changed = make(chan struct{})
+
+	wl.Infof("restored status at revision %d with %d transitions and %d sessions",
+		state.Status.Revision, len(oas.history), len(oas.sessions))
+
+	return nil
+}
+
+// validateState makes sure the state is consistent, the iterators rely on
+// the ordering of the history and sessions.
+func validateState(state State) error {
+	if state.Status.Revision == 0 {
+		return fmt.Errorf("%w: missing revision", ErrInvalidState)
+	}
+
+	for i, t := range state.History {
+		if t.Revision > state.Status.Revision {
+			return fmt.Errorf("%w: transition %d is past the status revision %d",
+				ErrInvalidState, t.Revision, state.Status.Revision)
+		}
+		if i > 0 && t.Revision <= state.History[i-1].Revision {
+			return fmt.Errorf("%w: transitions are not ordered by revision", ErrInvalidState)
+		}
+	}
+
+	sources := make(map[entities.Source]bool, len(state.Status.Claims))
+	for _, c := range state.Status.Claims {
+		if c.Source == "" || sources[c.Source] {
+			return fmt.Errorf("%w: missing or duplicate claim source %q", ErrInvalidState, c.Source)
+		}
+		sources[c.Source] = true
+	}
+
+	ids := make(map[string]bool, len(state.Sessions))
+	for i, s := range state.Sessions {
+		if s.ID == "" || ids[s.ID] {
+			return fmt.Errorf("%w: missing or duplicate session ID %q", ErrInvalidState, s.ID)
+		}
+		ids[s.ID] = true
+
+		if i > 0 && s.Start.Before(state.Sessions[i-1].Start) {
+			return fmt.Errorf("%w: sessions are not ordered by start", ErrInvalidState)
+		}
+		if s.End.Valid && s.End.Time.Before(s.Start) {
+			return fmt.Errorf("%w: session %s ends before it starts", ErrInvalidState, s.ID)
+		}
+		if !s.End.Valid && i < len(state.Sessions)-1 {
+			return fmt.Errorf("%w: only the last session can be running", ErrInvalidState)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package schedule
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Config holds the configuration options for schedules.
+type Config struct {
+	// How often schedules are checked
+	Tick time.Duration `env:"SCHEDULE_TICK" envDefault:"5s"`
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.Tick, validation.Min(time.Second)),
+	)
+}
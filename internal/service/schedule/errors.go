@@ -0,0 +1,9 @@
+package schedule
+
+import "errors"
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrScheduleOverlap  = errors.New("schedule overlaps an existing schedule")
+	ErrInvalidState     = errors.New("invalid state")
+)
@@ -0,0 +1,12 @@
+package schedule
+
+import (
+	"context"
+	"on-air/internal/wlog"
+	"time"
+)
+
+// Apply applies the schedules as the runner would at now.
+func Apply(svc SVC, ctx context.Context, wl wlog.Logger, now time.Time) {
+	svc.(*scheduleService).apply(ctx, wl, now)
+}
@@ -0,0 +1,220 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/randid"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"sort"
+	"time"
+)
+
+const scheduleIDLen = 8
+
+func (ss *scheduleService) CreateSchedule(
+	ctx context.Context,
+	wl wlog.Logger,
+	s entities.Schedule,
+) (entities.Schedule, error) {
+	if err := validateSchedule(&s); err != nil {
+		return entities.Schedule{}, err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for _, existing := range ss.schedules {
+		if s.Start.Before(existing.End) && existing.Start.Before(s.End) {
+			return entities.Schedule{}, fmt.Errorf("%w: %s", ErrScheduleOverlap, existing.ID)
+		}
+	}
+
+	s.ID = randid.New(scheduleIDLen)
+	s.CreatedAt = ss.clock.Now()
+	ss.schedules = append(ss.schedules, s)
+	sort.Slice(ss.schedules, func(i, j int) bool {
+		return ss.schedules[i].Start.Before(ss.schedules[j].Start)
+	})
+
+	wl.Debugf("created schedule %s from %s to %s", s.ID, s.Start, s.End)
+
+	return s, nil
+}
+
+func (ss *scheduleService) ListSchedules(
+	ctx context.Context,
+	wl wlog.Logger,
+	filter entities.ScheduleFilter,
+) ([]entities.Schedule, error) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	schedules := []entities.Schedule{}
+	for _, s := range ss.schedules {
+		if filter.From.Valid && !s.End.After(filter.From.Time) {
+			continue
+		}
+		if filter.To.Valid && !s.Start.Before(filter.To.Time) {
+			continue
+		}
+		schedules = append(schedules, s)
+	}
+
+	return schedules, nil
+}
+
+func (ss *scheduleService) DeleteSchedule(
+	ctx context.Context,
+	wl wlog.Logger,
+	id string,
+) error {
+	ss.applyMu.Lock()
+	defer ss.applyMu.Unlock()
+
+	ss.mu.RLock()
+	found := ss.index(id) >= 0
+	running := ss.running[id]
+	ss.mu.RUnlock()
+
+	if !found {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+
+	if running {
+		if err := ss.setOnAir(ctx, wl, false, ""); err != nil {
+			return err
+		}
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	// only the runner, held off by applyMu, removes windows
+	i := ss.index(id)
+	ss.schedules = append(ss.schedules[:i], ss.schedules[i+1:]...)
+	delete(ss.running, id)
+
+	wl.Debugf("deleted schedule %s", id)
+
+	return nil
+}
+
+// index returns the index of the schedule, -1 when there's none.
+func (ss *scheduleService) index(id string) int {
+	for i, s := range ss.schedules {
+		if s.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (ss *scheduleService) Run(ctx context.Context, wl wlog.Logger) {
+	ticker := time.NewTicker(ss.tick)
+	defer ticker.Stop()
+
+	for {
+		ss.apply(ctx, wl, ss.clock.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// apply starts the windows that are due and ends the ones that are over.
+// Windows that have ended are dropped, a window that couldn't be ended is
+// retried on the next tick.
+func (ss *scheduleService) apply(ctx context.Context, wl wlog.Logger, now time.Time) {
+	ss.applyMu.Lock()
+	defer ss.applyMu.Unlock()
+
+	// the windows are copied so the lock isn't held while the on air
+	// service notifies its listeners
+	ss.mu.RLock()
+	schedules := append([]entities.Schedule{}, ss.schedules...)
+	running := make(map[string]bool, len(ss.running))
+	for id := range ss.running {
+		running[id] = true
+	}
+	ss.mu.RUnlock()
+
+	var ended []string
+	for _, s := range schedules {
+		active := !now.Before(s.Start) && now.Before(s.End)
+		if !active && !now.Before(s.End) {
+			ended = append(ended, s.ID)
+		}
+
+		switch {
+		case active && !running[s.ID]:
+			wl.Infof("starting scheduled window %s", s.ID)
+			if err := ss.setOnAir(ctx, wl, true, s.Message); err != nil {
+				wl.Error(fmt.Errorf("error starting schedule %s: %w", s.ID, err))
+				continue
+			}
+			ss.setRunning(s.ID, true)
+		case !active && running[s.ID]:
+			wl.Infof("ending scheduled window %s", s.ID)
+			if err := ss.setOnAir(ctx, wl, false, ""); err != nil {
+				wl.Error(fmt.Errorf("error ending schedule %s: %w", s.ID, err))
+				continue
+			}
+			ss.setRunning(s.ID, false)
+		}
+	}
+
+	ss.drop(wl, ended)
+}
+
+// drop removes the windows that aren't running anymore.
+func (ss *scheduleService) drop(wl wlog.Logger, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for _, id := range ids {
+		if ss.running[id] {
+			continue
+		}
+		if i := ss.index(id); i >= 0 {
+			ss.schedules = append(ss.schedules[:i], ss.schedules[i+1:]...)
+			wl.Debugf("dropped ended schedule %s", id)
+		}
+	}
+}
+
+func (ss *scheduleService) setRunning(id string, running bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if running {
+		ss.running[id] = true
+		return
+	}
+	delete(ss.running, id)
+}
+
+// setOnAir claims the status on air with the message, or releases the claim
+// of the schedules so the status falls back to the other sources.
+func (ss *scheduleService) setOnAir(ctx context.Context, wl wlog.Logger, isOnAir bool, message string) error {
+	ctx = onair.WithSource(ctx, entities.SourceSchedule)
+	if !isOnAir {
+		_, err := ss.onAirService.Release(ctx, wl, entities.SourceSchedule)
+		return err
+	}
+
+	_, err := ss.onAirService.Claim(ctx, wl, entities.Claim{
+		Source:  entities.SourceSchedule,
+		IsOnAir: true,
+		Message: message,
+	})
+	return err
+}
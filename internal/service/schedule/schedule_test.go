@@ -0,0 +1,96 @@
+package schedule_test
+
+import (
+	"context"
+	"errors"
+	"on-air/internal/clock"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/service/schedule"
+	"on-air/internal/wlog"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"gotest.tools/v3/assert"
+)
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+	t0 := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(t0)
+
+	onAirService, err := onair.New(onair.WithClock(clk))
+	assert.NilError(t, err)
+	svc, err := schedule.New(onAirService, &schedule.Config{Tick: time.Second}, schedule.WithClock(clk))
+	assert.NilError(t, err)
+
+	// apply runs at the time of the clock so the status changes are dated
+	applyAt := func(d time.Duration) entities.OnAirStatus {
+		t.Helper()
+		clk.Advance(t0.Add(d).Sub(clk.Now()))
+		schedule.Apply(svc, ctx, wl, clk.Now())
+		status, err := onAirService.GetOnAirStatus(ctx, wl)
+		assert.NilError(t, err)
+		return status
+	}
+	list := func() []string {
+		t.Helper()
+		schedules, err := svc.ListSchedules(ctx, wl, entities.ScheduleFilter{})
+		assert.NilError(t, err)
+		ids := []string{}
+		for _, s := range schedules {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	create := func(start time.Duration, end time.Duration) (entities.Schedule, error) {
+		return svc.CreateSchedule(ctx, wl, entities.Schedule{
+			Start:   t0.Add(start),
+			End:     t0.Add(end),
+			Message: "show",
+		})
+	}
+
+	first, err := create(time.Hour, 2*time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, first.CreatedAt, t0)
+	second, err := create(3*time.Hour, 5*time.Hour)
+	assert.NilError(t, err)
+
+	// windows can't overlap
+	_, err = create(4*time.Hour, 6*time.Hour)
+	assert.Assert(t, errors.Is(err, schedule.ErrScheduleOverlap))
+	assert.DeepEqual(t, list(), []string{first.ID, second.ID})
+
+	// the window starts and ends
+	assert.Assert(t, !applyAt(0).IsOnAir)
+	status := applyAt(time.Hour)
+	assert.Assert(t, status.IsOnAir)
+	assert.Equal(t, status.Message, "show")
+	assert.Equal(t, status.Source, entities.SourceSchedule)
+	assert.Assert(t, !applyAt(2*time.Hour).IsOnAir)
+
+	// and is dropped once it has ended
+	assert.DeepEqual(t, list(), []string{second.ID})
+
+	// a claim failure is retried on the next tick
+	_, err = onAirService.Lock(ctx, wl, "recording", null.Time{})
+	assert.NilError(t, err)
+	assert.Assert(t, !applyAt(3*time.Hour).IsOnAir)
+	_, err = onAirService.Unlock(ctx, wl)
+	assert.NilError(t, err)
+	assert.Assert(t, applyAt(3*time.Hour+time.Second).IsOnAir)
+
+	// deleting a running window ends it
+	assert.NilError(t, svc.DeleteSchedule(ctx, wl, second.ID))
+	status, err = onAirService.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Assert(t, !status.IsOnAir)
+	assert.DeepEqual(t, list(), []string{})
+	assert.Assert(t, !applyAt(4*time.Hour).IsOnAir)
+
+	err = svc.DeleteSchedule(ctx, wl, second.ID)
+	assert.Assert(t, errors.Is(err, schedule.ErrScheduleNotFound))
+}
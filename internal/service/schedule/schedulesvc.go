@@ -0,0 +1,103 @@
+// Package schedule sets the on air status during scheduled windows.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"on-air/internal/clock"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"sync"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const maxMessageLen = 200
+
+type SVC interface {
+	// CreateSchedule adds a window. Windows can't overlap. It returns
+	// validation.Errors when the window is not valid.
+	CreateSchedule(ctx context.Context, wl wlog.Logger, s entities.Schedule) (entities.Schedule, error)
+	// ListSchedules returns the schedules overlapping the filter range, soonest
+	// first. Windows are dropped once they have ended.
+	ListSchedules(ctx context.Context, wl wlog.Logger, filter entities.ScheduleFilter) ([]entities.Schedule, error)
+	// DeleteSchedule removes a window, ending it first if it's running.
+	DeleteSchedule(ctx context.Context, wl wlog.Logger, id string) error
+	// Run applies the schedules until the context is done.
+	Run(ctx context.Context, wl wlog.Logger)
+	// Snapshot returns every schedule and the IDs of the running ones.
+	Snapshot(ctx context.Context, wl wlog.Logger) (State, error)
+	// Restore replaces every schedule, e.g. at startup.
+	Restore(ctx context.Context, wl wlog.Logger, state State) error
+}
+
+// State is everything the service keeps in memory.
+type State struct {
+	// Schedules are ordered by start
+	Schedules []entities.Schedule
+	// Running holds the IDs of the schedules that set the status on air
+	Running []string
+}
+
+// Option configures the schedule service.
+type Option func(*scheduleService)
+
+// WithClock sets the clock the windows are applied with, the system clock by
+// default.
+func WithClock(c clock.Clock) Option {
+	return func(ss *scheduleService) {
+		if c != nil {
+			ss.clock = c
+		}
+	}
+}
+
+type scheduleService struct {
+	onAirService onair.SVC
+	tick         time.Duration
+	clock        clock.Clock
+
+	// applyMu serializes the changes to the on air status, so a window
+	// isn't ended before it started. It's held without mu.
+	applyMu sync.Mutex
+
+	mu        sync.RWMutex
+	schedules []entities.Schedule
+	// running holds the IDs of the windows the status has been set on air for
+	running map[string]bool
+}
+
+func New(onAirService onair.SVC, cfg *Config, opts ...Option) (SVC, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	ss := &scheduleService{
+		onAirService: onAirService,
+		tick:         cfg.Tick,
+		clock:        clock.Real{},
+		running:      make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(ss)
+	}
+
+	return ss, nil
+}
+
+// validateSchedule returns validation.Errors keyed by the JSON fields of
+// the schedule when it's not valid.
+func validateSchedule(s *entities.Schedule) error {
+	return validation.Errors{
+		"start": validation.Validate(s.Start, validation.Required),
+		"end": validation.Validate(s.End, validation.Required, validation.By(func(value interface{}) error {
+			if end, _ := value.(time.Time); !end.After(s.Start) {
+				return errors.New("must be after start")
+			}
+			return nil
+		})),
+		"message": validation.Validate(s.Message, validation.RuneLength(0, maxMessageLen)),
+	}.Filter()
+}
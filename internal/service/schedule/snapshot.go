@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"sort"
+)
+
+func (ss *scheduleService) Snapshot(ctx context.Context, wl wlog.Logger) (State, error) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	state := State{
+		Schedules: append([]entities.Schedule{}, ss.schedules...),
+		Running:   make([]string, 0, len(ss.running)),
+	}
+	for id := range ss.running {
+		state.Running = append(state.Running, id)
+	}
+	sort.Strings(state.Running)
+
+	return state, nil
+}
+
+func (ss *scheduleService) Restore(ctx context.Context, wl wlog.Logger, state State) error {
+	schedules := append([]entities.Schedule{}, state.Schedules...)
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Start.Before(schedules[j].Start)
+	})
+
+	ids := make(map[string]bool, len(schedules))
+	for i := range schedules {
+		s := &schedules[i]
+		if s.ID == "" || ids[s.ID] {
+			return fmt.Errorf("%w: missing or duplicate schedule ID %q", ErrInvalidState, s.ID)
+		}
+		ids[s.ID] = true
+
+		if err := validateSchedule(s); err != nil {
+			return fmt.Errorf("%w: schedule %s: %s", ErrInvalidState, s.ID, err)
+		}
+		if i > 0 && s.Start.Before(schedules[i-1].End) {
+			return fmt.Errorf("%w: schedules %s and %s overlap", ErrInvalidState, schedules[i-1].ID, s.ID)
+		}
+	}
+
+	running := make(map[string]bool, len(state.Running))
+	for _, id := range state.Running {
+		if !ids[id] {
+			return fmt.Errorf("%w: unknown running schedule %q", ErrInvalidState, id)
+		}
+		running[id] = true
+	}
+
+	ss.applyMu.Lock()
+	defer ss.applyMu.Unlock()
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.schedules = schedules
+	ss.running = running
+
+	wl.Infof("restored %d schedules", len(schedules))
+
+	return nil
+}
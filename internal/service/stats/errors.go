@@ -0,0 +1,7 @@
+package stats
+
+import "errors"
+
+var (
+	ErrTooManyBuckets = errors.New("the range contains too many buckets for the period")
+)
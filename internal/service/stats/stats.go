@@ -0,0 +1,162 @@
+package stats
+
+import (
+	"context"
+	"on-air/internal/entities"
+	"on-air/internal/wlog"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+func (ss *statsService) GetStats(
+	ctx context.Context,
+	wl wlog.Logger,
+	q Query,
+) (entities.Stats, error) {
+	if err := q.Validate(); err != nil {
+		return entities.Stats{}, err
+	}
+
+	// read before the sessions so it can't miss a session dropped meanwhile
+	since, err := ss.onAirService.SessionsSince(ctx, wl)
+	if err != nil {
+		return entities.Stats{}, err
+	}
+
+	var sessions []entities.Session
+	filter := entities.SessionFilter{From: null.TimeFrom(q.From), To: null.TimeFrom(q.To)}
+	err = ss.onAirService.EachSession(ctx, wl, filter, func(s entities.Session) error {
+		sessions = append(sessions, s)
+		return nil
+	})
+	if err != nil {
+		return entities.Stats{}, err
+	}
+
+	wl.Debugf("computing stats from %d sessions", len(sessions))
+
+	stats, err := Compute(sessions, q, ss.clock.Now())
+	if err != nil {
+		return entities.Stats{}, err
+	}
+	stats.CoveredFrom = since
+
+	return stats, nil
+}
+
+// interval is a span of on air time.
+type interval struct {
+	start time.Time
+	end   time.Time
+}
+
+// Compute aggregates the sessions, oldest first, that overlap the query
+// range. Sessions are clipped to the range and a session still running is
+// considered to end now.
+func Compute(sessions []entities.Session, q Query, now time.Time) (entities.Stats, error) {
+	stats := entities.Stats{
+		From:     q.From.In(q.Location),
+		To:       q.To.In(q.Location),
+		Location: q.Location,
+	}
+
+	buckets, err := newBuckets(stats.From, stats.To, q.Period)
+	if err != nil {
+		return entities.Stats{}, err
+	}
+
+	for _, session := range sessions {
+		s := interval{start: session.Start, end: session.End.ValueOrZero()}
+		if !session.End.Valid {
+			s.end = now
+		}
+
+		// clip to the range
+		if s.start.Before(q.From) {
+			s.start = q.From
+		}
+		if s.end.After(q.To) {
+			s.end = q.To
+		}
+		if !s.end.After(s.start) {
+			continue
+		}
+
+		d := s.end.Sub(s.start)
+		stats.TotalOnAir += d
+		stats.Sessions++
+		if d > stats.LongestSession {
+			stats.LongestSession = d
+		}
+
+		for i := range buckets {
+			b := &buckets[i]
+			if !s.start.Before(b.Start) && s.start.Before(b.End) {
+				b.Sessions++
+			}
+			b.OnAir += overlap(s, b.Start, b.End)
+		}
+	}
+
+	if stats.Sessions > 0 {
+		stats.AverageSession = stats.TotalOnAir / time.Duration(stats.Sessions)
+	}
+	stats.Buckets = buckets
+
+	return stats, nil
+}
+
+// newBuckets splits the range on the period boundaries of its timezone.
+// The first and last buckets are truncated to the range.
+func newBuckets(from time.Time, to time.Time, p Period) ([]entities.StatsBucket, error) {
+	var buckets []entities.StatsBucket
+	for start := from; start.Before(to); {
+		end := nextBoundary(start, p)
+		if end.After(to) {
+			end = to
+		}
+
+		buckets = append(buckets, entities.StatsBucket{Start: start, End: end})
+		if len(buckets) > maxBuckets {
+			return nil, ErrTooManyBuckets
+		}
+		start = end
+	}
+
+	return buckets, nil
+}
+
+// nextBoundary returns the start of the period following t, in t's location.
+// Dates are used rather than durations so DST changes are handled.
+func nextBoundary(t time.Time, p Period) time.Time {
+	y, m, d := t.Date()
+	loc := t.Location()
+
+	switch p {
+	case PeriodWeek:
+		// days until next monday
+		days := (8 - int(t.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		return time.Date(y, m, d+days, 0, 0, 0, 0, loc)
+	case PeriodMonth:
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+	}
+}
+
+func overlap(s interval, start time.Time, end time.Time) time.Duration {
+	if s.start.After(start) {
+		start = s.start
+	}
+	if s.end.Before(end) {
+		end = s.end
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
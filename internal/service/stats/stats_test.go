@@ -0,0 +1,125 @@
+package stats_test
+
+import (
+	"context"
+	"on-air/internal/clock"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/service/stats"
+	"on-air/internal/wlog"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"gotest.tools/v3/assert"
+)
+
+func TestCompute(t *testing.T) {
+	montreal, err := time.LoadLocation("America/Montreal")
+	assert.NilError(t, err)
+
+	at := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		assert.NilError(t, err)
+		return ts
+	}
+
+	end := func(s string) null.Time { return null.TimeFrom(at(s)) }
+
+	sessions := []entities.Session{
+		// 23:00 to 01:00 local, spans two local days
+		{Start: at("2024-03-09T04:00:00Z"), End: end("2024-03-09T06:00:00Z")},
+		{Start: at("2024-03-10T14:00:00Z"), End: end("2024-03-10T15:00:00Z")},
+		// still on air
+		{Start: at("2024-03-11T13:00:00Z")},
+	}
+
+	now := at("2024-03-11T13:30:00Z")
+	q := stats.Query{
+		From:     time.Date(2024, 3, 8, 0, 0, 0, 0, montreal),
+		To:       now,
+		Location: montreal,
+		Period:   stats.PeriodDay,
+	}
+
+	s, err := stats.Compute(sessions, q, now)
+	assert.NilError(t, err)
+
+	assert.Equal(t, s.Sessions, 3)
+	assert.Equal(t, s.TotalOnAir, 3*time.Hour+30*time.Minute)
+	assert.Equal(t, s.LongestSession, 2*time.Hour)
+	assert.Equal(t, s.AverageSession, 70*time.Minute)
+
+	// 8th, 9th, 10th (DST starts, 23h long) and the partial 11th
+	assert.Equal(t, len(s.Buckets), 4)
+	assert.Equal(t, s.Buckets[0].OnAir, time.Hour)
+	assert.Equal(t, s.Buckets[0].Sessions, 1)
+	assert.Equal(t, s.Buckets[1].OnAir, time.Hour)
+	assert.Equal(t, s.Buckets[1].Sessions, 0)
+	assert.Equal(t, s.Buckets[2].End.Sub(s.Buckets[2].Start), 23*time.Hour)
+	assert.Equal(t, s.Buckets[2].OnAir, time.Hour)
+	assert.Equal(t, s.Buckets[3].OnAir, 30*time.Minute)
+	assert.Equal(t, s.Buckets[3].End, now.In(montreal))
+}
+
+func TestComputePeriods(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	testData := []struct {
+		period   stats.Period
+		expected int
+	}{
+		{stats.PeriodDay, 74},
+		// 2024-01-01 is a monday
+		{stats.PeriodWeek, 11},
+		{stats.PeriodMonth, 3},
+	}
+
+	for _, tc := range testData {
+		s, err := stats.Compute(nil, stats.Query{From: from, To: to, Location: time.UTC, Period: tc.period}, to)
+		assert.NilError(t, err)
+		assert.Equal(t, len(s.Buckets), tc.expected, string(tc.period))
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+	t0 := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(t0)
+
+	onAirService, err := onair.New(onair.WithClock(clk), onair.WithHistoryLimit(2))
+	assert.NilError(t, err)
+	statsService, err := stats.New(onAirService, stats.WithClock(clk))
+	assert.NilError(t, err)
+
+	toggle := func(d time.Duration) {
+		t.Helper()
+		_, err := onAirService.ToggleOnAirStatus(ctx, wl)
+		assert.NilError(t, err)
+		clk.Advance(d)
+	}
+
+	q := stats.Query{From: t0, To: t0.Add(24 * time.Hour), Location: time.UTC, Period: stats.PeriodDay}
+
+	// the running session ends on the injected clock
+	toggle(time.Hour)
+	s, err := statsService.GetStats(ctx, wl, q)
+	assert.NilError(t, err)
+	assert.Equal(t, s.TotalOnAir, time.Hour)
+	assert.Assert(t, !s.Truncated())
+
+	// the first session is dropped once the limit is reached
+	toggle(time.Hour)
+	toggle(time.Hour)
+	toggle(time.Hour)
+	toggle(time.Hour)
+	toggle(time.Hour)
+	s, err = statsService.GetStats(ctx, wl, q)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Sessions, 2)
+	assert.Equal(t, s.TotalOnAir, 2*time.Hour)
+	assert.Assert(t, s.Truncated())
+	assert.Equal(t, s.CoveredFrom.Time, t0.Add(2*time.Hour))
+}
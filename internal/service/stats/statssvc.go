@@ -0,0 +1,77 @@
+// Package stats computes on air time statistics from the sessions.
+package stats
+
+import (
+	"context"
+	"on-air/internal/clock"
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/wlog"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Period is the size of the buckets of a statistics time series.
+type Period string
+
+// Supported periods. Weeks start on Monday.
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+)
+
+// maxBuckets caps the size of a time series.
+const maxBuckets = 1000
+
+// Query selects the time range, timezone and bucket size of the statistics.
+type Query struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+	Period   Period
+}
+
+// Validate makes sure the query is valid.
+// It returns an error when the query is not valid.
+func (q *Query) Validate() error {
+	return validation.ValidateStruct(
+		q,
+		validation.Field(&q.From, validation.Required),
+		validation.Field(&q.To, validation.Required, validation.Min(q.From)),
+		validation.Field(&q.Location, validation.Required),
+		validation.Field(&q.Period, validation.Required, validation.In(PeriodDay, PeriodWeek, PeriodMonth)),
+	)
+}
+
+type SVC interface {
+	// GetStats computes the statistics of the on air sessions overlapping the query range.
+	GetStats(ctx context.Context, wl wlog.Logger, q Query) (entities.Stats, error)
+}
+
+// Option configures the stats service.
+type Option func(*statsService)
+
+// WithClock sets the clock telling when running sessions end, the system
+// clock by default.
+func WithClock(c clock.Clock) Option {
+	return func(ss *statsService) {
+		if c != nil {
+			ss.clock = c
+		}
+	}
+}
+
+type statsService struct {
+	onAirService onair.SVC
+	clock        clock.Clock
+}
+
+func New(onAirService onair.SVC, opts ...Option) (SVC, error) {
+	ss := &statsService{onAirService: onAirService, clock: clock.Real{}}
+	for _, opt := range opts {
+		opt(ss)
+	}
+	return ss, nil
+}
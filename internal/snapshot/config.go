@@ -0,0 +1,43 @@
+package snapshot
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Config holds the configuration options for snapshots.
+type Config struct {
+	// The directory snapshots are written to
+	Dir string `env:"SNAPSHOT_DIR"`
+	// The GCS bucket snapshots are written to, instead of a directory
+	Bucket string `env:"SNAPSHOT_BUCKET"`
+	// The URL of a GCS emulator, requests to it are not authenticated
+	StorageEmulatorHost string `env:"STORAGE_EMULATOR_HOST"`
+	// How long a read or write of the bucket can take
+	StorageTimeout time.Duration `env:"SNAPSHOT_STORAGE_TIMEOUT" envDefault:"30s"`
+	// The name of the snapshot file
+	Name string `env:"SNAPSHOT_NAME" envDefault:"on-air-snapshot.json"`
+	// How often a snapshot is written, besides on shutdown
+	Interval time.Duration `env:"SNAPSHOT_INTERVAL" envDefault:"1m"`
+	// How many of the most recent transitions are kept
+	HistoryTail int `env:"SNAPSHOT_HISTORY_TAIL" envDefault:"1000"`
+}
+
+// Enabled reports whether a directory or a bucket has been configured.
+func (c *Config) Enabled() bool {
+	return c.Dir != "" || c.Bucket != ""
+}
+
+// Validate makes sure the configuration is valid.
+// It returns an error when the configuration is not valid.
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.Bucket, validation.When(c.Dir != "", validation.Empty.Error("can't be set along with a directory"))),
+		validation.Field(&c.Name, validation.Required),
+		validation.Field(&c.Interval, validation.Min(time.Second)),
+		validation.Field(&c.StorageTimeout, validation.When(c.Bucket != "", validation.Min(time.Second))),
+		validation.Field(&c.HistoryTail, validation.Min(0)),
+	)
+}
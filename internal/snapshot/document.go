@@ -0,0 +1,243 @@
+package snapshot
+
+import (
+	"on-air/internal/entities"
+	"on-air/internal/service/onair"
+	"on-air/internal/service/schedule"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// Version is the version of the snapshots written. Bump it when the
+// document changes in a way older versions can't read.
+//
+//  1. the status, history, sessions and schedules
+//  2. the lock and claims of the status, and the source of the transitions
+//  3. the channels besides the default one
+const Version = 3
+
+// document is the JSON snapshot. It's decoupled from the entities so
+// renaming a field doesn't break the snapshots already written.
+type document struct {
+	Version int       `json:"version"`
+	TakenAt time.Time `json:"taken_at"`
+	// the default channel is kept at the top level, where the snapshots
+	// taken before channels have it
+	channelDoc
+	// Channels holds the other channels by ID
+	Channels  map[string]channelDoc `json:"channels,omitempty"`
+	Schedules []scheduleDoc         `json:"schedules"`
+	// Running holds the IDs of the schedules that set the status on air
+	Running []string `json:"running_schedules"`
+}
+
+// channelDoc is the state of the on air service of a channel.
+type channelDoc struct {
+	Status   *statusDoc      `json:"status"`
+	History  []transitionDoc `json:"history"`
+	Sessions []sessionDoc    `json:"sessions"`
+}
+
+type statusDoc struct {
+	IsOnAir     bool      `json:"is_on_air"`
+	Message     string    `json:"message"`
+	LastUpdated null.Time `json:"last_updated"`
+	LastOnAir   null.Time `json:"last_on_air"`
+	Revision    uint64    `json:"revision"`
+	Lock        *lockDoc  `json:"lock,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	// Claims is missing from the snapshots taken before claims
+	Claims []claimDoc `json:"claims,omitempty"`
+}
+
+type transitionDoc struct {
+	Revision uint64    `json:"revision"`
+	IsOnAir  bool      `json:"is_on_air"`
+	Message  string    `json:"message"`
+	At       time.Time `json:"at"`
+	Lock     *lockDoc  `json:"lock,omitempty"`
+	Source   string    `json:"source,omitempty"`
+}
+
+type claimDoc struct {
+	Source    string    `json:"source"`
+	IsOnAir   bool      `json:"is_on_air"`
+	Message   string    `json:"message"`
+	ClaimedAt time.Time `json:"claimed_at"`
+	ExpiresAt null.Time `json:"expires_at"`
+}
+
+type lockDoc struct {
+	Holder    string    `json:"holder"`
+	Reason    string    `json:"reason"`
+	LockedAt  time.Time `json:"locked_at"`
+	ExpiresAt null.Time `json:"expires_at"`
+}
+
+type sessionDoc struct {
+	ID    string    `json:"id"`
+	Start time.Time `json:"start"`
+	End   null.Time `json:"end"`
+	Notes string    `json:"notes"`
+	Tags  []string  `json:"tags"`
+}
+
+type scheduleDoc struct {
+	ID        string    `json:"id"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newDocument(
+	onAir onair.State,
+	channels map[string]onair.State,
+	schedules schedule.State,
+	now time.Time,
+) document {
+	doc := document{
+		Version:    Version,
+		TakenAt:    now.UTC(),
+		channelDoc: newChannelDoc(onAir),
+		Schedules:  make([]scheduleDoc, 0, len(schedules.Schedules)),
+		Running:    schedules.Running,
+	}
+
+	if len(channels) > 0 {
+		doc.Channels = make(map[string]channelDoc, len(channels))
+		for id, state := range channels {
+			doc.Channels[id] = newChannelDoc(state)
+		}
+	}
+	for _, s := range schedules.Schedules {
+		doc.Schedules = append(doc.Schedules, scheduleDoc(s))
+	}
+
+	return doc
+}
+
+func newChannelDoc(onAir onair.State) channelDoc {
+	doc := channelDoc{
+		Status: &statusDoc{
+			IsOnAir:     onAir.Status.IsOnAir,
+			Message:     onAir.Status.Message,
+			LastUpdated: onAir.Status.LastUpdated,
+			LastOnAir:   onAir.Status.LastOnAir,
+			Revision:    onAir.Status.Revision,
+			Lock:        newLockDoc(onAir.Status.Lock),
+			Source:      string(onAir.Status.Source),
+			Claims:      make([]claimDoc, 0, len(onAir.Status.Claims)),
+		},
+		History:  make([]transitionDoc, 0, len(onAir.History)),
+		Sessions: make([]sessionDoc, 0, len(onAir.Sessions)),
+	}
+
+	for _, t := range onAir.History {
+		doc.History = append(doc.History, transitionDoc{
+			Revision: t.Revision,
+			IsOnAir:  t.IsOnAir,
+			Message:  t.Message,
+			At:       t.At,
+			Lock:     newLockDoc(t.Lock),
+			Source:   string(t.Source),
+		})
+	}
+	for _, c := range onAir.Status.Claims {
+		doc.Status.Claims = append(doc.Status.Claims, claimDoc{
+			Source:    string(c.Source),
+			IsOnAir:   c.IsOnAir,
+			Message:   c.Message,
+			ClaimedAt: c.ClaimedAt,
+			ExpiresAt: c.ExpiresAt,
+		})
+	}
+	for _, s := range onAir.Sessions {
+		doc.Sessions = append(doc.Sessions, sessionDoc(s))
+	}
+
+	return doc
+}
+
+func (doc channelDoc) onAirState() onair.State {
+	state := onair.State{
+		Status: entities.OnAirStatus{
+			IsOnAir:     doc.Status.IsOnAir,
+			Message:     doc.Status.Message,
+			LastUpdated: doc.Status.LastUpdated,
+			LastOnAir:   doc.Status.LastOnAir,
+			Revision:    doc.Status.Revision,
+			Lock:        doc.Status.Lock.lock(),
+			Source:      entities.Source(doc.Status.Source),
+			Claims:      make([]entities.Claim, 0, len(doc.Status.Claims)),
+		},
+		History:  make([]entities.Transition, 0, len(doc.History)),
+		Sessions: make([]entities.Session, 0, len(doc.Sessions)),
+	}
+
+	for _, t := range doc.History {
+		state.History = append(state.History, entities.Transition{
+			Revision: t.Revision,
+			IsOnAir:  t.IsOnAir,
+			Message:  t.Message,
+			At:       t.At,
+			Lock:     t.Lock.lock(),
+			Source:   entities.Source(t.Source),
+		})
+	}
+	for _, c := range doc.Status.Claims {
+		state.Status.Claims = append(state.Status.Claims, entities.Claim{
+			Source:    entities.Source(c.Source),
+			IsOnAir:   c.IsOnAir,
+			Message:   c.Message,
+			ClaimedAt: c.ClaimedAt,
+			ExpiresAt: c.ExpiresAt,
+		})
+	}
+	// the status of the snapshots taken before claims was set manually
+	if doc.Status.Claims == nil && doc.Status.IsOnAir {
+		state.Status.Source = entities.SourceManual
+		state.Status.Claims = append(state.Status.Claims, entities.Claim{
+			Source:    entities.SourceManual,
+			IsOnAir:   true,
+			Message:   doc.Status.Message,
+			ClaimedAt: doc.Status.LastUpdated.Time,
+		})
+	}
+	for _, s := range doc.Sessions {
+		if s.Tags == nil {
+			s.Tags = []string{}
+		}
+		state.Sessions = append(state.Sessions, entities.Session(s))
+	}
+
+	return state
+}
+
+func (doc document) scheduleState() schedule.State {
+	state := schedule.State{
+		Schedules: make([]entities.Schedule, 0, len(doc.Schedules)),
+		Running:   doc.Running,
+	}
+	for _, s := range doc.Schedules {
+		state.Schedules = append(state.Schedules, entities.Schedule(s))
+	}
+	return state
+}
+
+func newLockDoc(l *entities.Lock) *lockDoc {
+	if l == nil {
+		return nil
+	}
+	d := lockDoc(*l)
+	return &d
+}
+
+func (d *lockDoc) lock() *entities.Lock {
+	if d == nil {
+		return nil
+	}
+	l := entities.Lock(*d)
+	return &l
+}
@@ -0,0 +1,8 @@
+package snapshot
+
+import "errors"
+
+var (
+	ErrCorruptSnapshot    = errors.New("corrupt snapshot")
+	ErrUnsupportedVersion = errors.New("unsupported snapshot version")
+)
@@ -0,0 +1,7 @@
+package snapshot
+
+// DecodeAs decodes a snapshot like the reader of the given version.
+func DecodeAs(b []byte, version int) error {
+	_, err := decodeAs(b, version)
+	return err
+}
@@ -0,0 +1,234 @@
+// Package snapshot periodically writes the state of the services as a
+// versioned JSON document and restores it at startup, so the status,
+// history and sessions of every channel and the schedules survive restarts.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"on-air/internal/service/channel"
+	"on-air/internal/service/onair"
+	"on-air/internal/service/schedule"
+	"on-air/internal/wlog"
+	"on-air/pkg/client"
+	"time"
+)
+
+// Snapshotter saves and restores the state of the services.
+type Snapshotter struct {
+	storage         client.FileStorageProvider
+	name            string
+	interval        time.Duration
+	historyTail     int
+	channels        channel.SVC
+	scheduleService schedule.SVC
+}
+
+// NewStorage returns the FileStorageProvider the configuration points to.
+func NewStorage(cfg *Config) (client.FileStorageProvider, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Bucket != "" {
+		opts := []client.GCSOption{client.WithGCSTimeout(cfg.StorageTimeout)}
+		if cfg.StorageEmulatorHost != "" {
+			opts = append(opts, client.WithGCSEndpoint(cfg.StorageEmulatorHost))
+		}
+		return client.NewGCSStorage(cfg.Bucket, opts...), nil
+	}
+
+	return client.NewLocalStorage(cfg.Dir)
+}
+
+// New creates a Snapshotter writing the state of the services to storage.
+func New(
+	cfg *Config,
+	storage client.FileStorageProvider,
+	channels channel.SVC,
+	scheduleService schedule.SVC,
+) (*Snapshotter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Snapshotter{
+		storage:         storage,
+		name:            cfg.Name,
+		interval:        cfg.Interval,
+		historyTail:     cfg.HistoryTail,
+		channels:        channels,
+		scheduleService: scheduleService,
+	}, nil
+}
+
+// Save writes a snapshot of the current state.
+func (sn *Snapshotter) Save(ctx context.Context, wl wlog.Logger) error {
+	var onAirState onair.State
+	channelStates := map[string]onair.State{}
+	for _, id := range sn.channels.List(ctx, wl) {
+		onAirService, err := sn.channels.Get(ctx, wl, id)
+		if err != nil {
+			return err
+		}
+		state, err := onAirService.Snapshot(ctx, wl, sn.historyTail)
+		if err != nil {
+			return fmt.Errorf("error reading channel %s: %w", id, err)
+		}
+
+		if id == onair.DefaultChannel {
+			onAirState = state
+			continue
+		}
+		channelStates[id] = state
+	}
+
+	scheduleState, err := sn.scheduleService.Snapshot(ctx, wl)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(newDocument(onAirState, channelStates, scheduleState, time.Now())); err != nil {
+		return err
+	}
+
+	if err := sn.storage.Save(ctx, sn.name, &buf); err != nil {
+		return fmt.Errorf("error writing snapshot %s: %w", sn.name, err)
+	}
+
+	wl.Debugf("saved snapshot %s at revision %d", sn.name, onAirState.Status.Revision)
+
+	return nil
+}
+
+// Restore reads the snapshot and replaces the state of the services with it.
+// A missing snapshot isn't an error, the services keep their initial state.
+// It returns ErrCorruptSnapshot when the snapshot can't be read and
+// ErrUnsupportedVersion when it was written by a newer version.
+func (sn *Snapshotter) Restore(ctx context.Context, wl wlog.Logger) error {
+	var buf bytes.Buffer
+	if err := sn.storage.Load(ctx, sn.name, &buf); err != nil {
+		if errors.Is(err, client.ErrFileNotFound) {
+			wl.Infof("no snapshot %s to restore, starting fresh", sn.name)
+			return nil
+		}
+		return fmt.Errorf("error reading snapshot %s: %w", sn.name, err)
+	}
+
+	doc, err := decode(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %w", sn.name, err)
+	}
+
+	for _, id := range sn.channels.List(ctx, wl) {
+		state := doc.channelDoc
+		if id != onair.DefaultChannel {
+			var ok bool
+			if state, ok = doc.Channels[id]; !ok {
+				// added since the snapshot
+				continue
+			}
+		}
+
+		onAirService, err := sn.channels.Get(ctx, wl, id)
+		if err != nil {
+			return err
+		}
+		if err := onAirService.Restore(ctx, wl, state.onAirState()); err != nil {
+			return fmt.Errorf("%w: %s: channel %s: %s", ErrCorruptSnapshot, sn.name, id, err)
+		}
+	}
+	for id := range doc.Channels {
+		if _, err := sn.channels.Get(ctx, wl, id); errors.Is(err, channel.ErrChannelNotFound) {
+			wl.Warnf("channel %s of snapshot %s isn't configured anymore, dropping it", id, sn.name)
+		}
+	}
+
+	if err := sn.scheduleService.Restore(ctx, wl, doc.scheduleState()); err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrCorruptSnapshot, sn.name, err)
+	}
+
+	wl.Infof("restored snapshot %s taken at %s, revision %d",
+		sn.name, doc.TakenAt.Format(time.RFC3339), doc.Status.Revision)
+
+	return nil
+}
+
+// Start saves a snapshot every interval until the context is done, then
+// saves one last snapshot. The returned channel is closed once it's written.
+func (sn *Snapshotter) Start(ctx context.Context, wl wlog.Logger) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(sn.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				saveCtx, cancel := context.WithTimeout(context.Background(), sn.interval)
+				defer cancel()
+				if err := sn.Save(saveCtx, wl); err != nil {
+					wl.Error(fmt.Errorf("error saving the final snapshot: %w", err))
+				}
+				return
+			case <-ticker.C:
+			}
+
+			if err := sn.Save(ctx, wl); err != nil {
+				wl.Error(fmt.Errorf("error saving snapshot: %w", err))
+			}
+		}
+	}()
+
+	return done
+}
+
+// decode parses and checks the version of a snapshot.
+func decode(b []byte) (document, error) {
+	return decodeAs(b, Version)
+}
+
+// decodeAs decodes a snapshot like the reader of the given version, which
+// rejects the snapshots of newer versions.
+func decodeAs(b []byte, version int) (document, error) {
+	// read the version alone first so a future document that doesn't
+	// decode into this version is reported as such
+	var header struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		return document{}, fmt.Errorf("%w: %s", ErrCorruptSnapshot, err)
+	}
+
+	switch {
+	case header.Version == nil || *header.Version < 1:
+		return document{}, fmt.Errorf("%w: missing or invalid version", ErrCorruptSnapshot)
+	case *header.Version > version:
+		return document{}, fmt.Errorf("%w: %d, the latest supported is %d",
+			ErrUnsupportedVersion, *header.Version, version)
+	}
+
+	var doc document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return document{}, fmt.Errorf("%w: %s", ErrCorruptSnapshot, err)
+	}
+	if doc.Status == nil {
+		return document{}, fmt.Errorf("%w: missing status", ErrCorruptSnapshot)
+	}
+	for id, c := range doc.Channels {
+		if c.Status == nil {
+			return document{}, fmt.Errorf("%w: missing status of channel %s", ErrCorruptSnapshot, id)
+		}
+	}
+
+	return doc, nil
+}
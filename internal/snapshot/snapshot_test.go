@@ -0,0 +1,279 @@
+package snapshot_test
+
+import (
+	"context"
+	"on-air/internal/entities"
+	"on-air/internal/service/channel"
+	"on-air/internal/service/onair"
+	"on-air/internal/service/schedule"
+	"on-air/internal/snapshot"
+	"on-air/internal/wlog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"gotest.tools/v3/assert"
+)
+
+type services struct {
+	onAir    onair.SVC
+	channels channel.SVC
+	schedule schedule.SVC
+	snap     *snapshot.Snapshotter
+}
+
+func newServices(t *testing.T, dir string, channels ...string) services {
+	t.Helper()
+
+	onAirService, err := onair.New()
+	assert.NilError(t, err)
+
+	channelService, err := channel.New(&channel.Config{Channels: channels}, onAirService)
+	assert.NilError(t, err)
+
+	scheduleService, err := schedule.New(onAirService, &schedule.Config{Tick: time.Second})
+	assert.NilError(t, err)
+
+	cfg := &snapshot.Config{Dir: dir, Name: "state.json", Interval: time.Minute, HistoryTail: 2}
+	storage, err := snapshot.NewStorage(cfg)
+	assert.NilError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	snap, err := snapshot.New(cfg, storage, channelService, scheduleService)
+	assert.NilError(t, err)
+
+	return services{onAir: onAirService, channels: channelService, schedule: scheduleService, snap: snap}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+	dir := t.TempDir()
+
+	src := newServices(t, dir)
+	for _, onAir := range []bool{true, false, true} {
+		_, err := src.onAir.SetOnAirStatus(ctx, wl, entities.OnAirStatus{IsOnAir: onAir, Message: "live"})
+		assert.NilError(t, err)
+	}
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	created, err := src.schedule.CreateSchedule(ctx, wl, entities.Schedule{
+		Start:   start,
+		End:     start.Add(time.Hour),
+		Message: "recording",
+	})
+	assert.NilError(t, err)
+
+	assert.NilError(t, src.snap.Save(ctx, wl))
+
+	dst := newServices(t, dir)
+	assert.NilError(t, dst.snap.Restore(ctx, wl))
+
+	want, err := src.onAir.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	got, err := dst.onAir.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, got.IsOnAir, want.IsOnAir)
+	assert.Equal(t, got.Message, want.Message)
+	assert.Equal(t, got.Revision, want.Revision)
+	assert.Assert(t, got.LastUpdated.Time.Equal(want.LastUpdated.Time))
+
+	// only the tail of the history is kept
+	history, err := dst.onAir.GetHistory(ctx, wl, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(history), 2)
+	assert.Equal(t, history[0].Revision, want.Revision)
+
+	sessions, err := dst.onAir.ListSessions(ctx, wl, entities.SessionFilter{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 2)
+	assert.Assert(t, !sessions[0].End.Valid)
+	assert.Assert(t, sessions[1].End.Valid)
+
+	schedules, err := dst.schedule.ListSchedules(ctx, wl, entities.ScheduleFilter{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(schedules), 1)
+	assert.Equal(t, schedules[0].ID, created.ID)
+	assert.Assert(t, schedules[0].Start.Equal(start))
+}
+
+func TestRestore(t *testing.T) {
+	testData := []struct {
+		name     string
+		content  string
+		expected error
+	}{
+		{
+			name:     "missing snapshot",
+			expected: nil,
+		},
+		{
+			name:     "not json",
+			content:  `{"version": 1, "status": `,
+			expected: snapshot.ErrCorruptSnapshot,
+		},
+		{
+			name:     "missing version",
+			content:  `{"status": {"revision": 1}}`,
+			expected: snapshot.ErrCorruptSnapshot,
+		},
+		{
+			name:     "missing status",
+			content:  `{"version": 1}`,
+			expected: snapshot.ErrCorruptSnapshot,
+		},
+		{
+			name:     "invalid state",
+			content:  `{"version": 1, "status": {"revision": 0}}`,
+			expected: snapshot.ErrCorruptSnapshot,
+		},
+		{
+			name: "unknown running schedule",
+			content: `{"version": 1, "status": {"revision": 1},
+				"schedules": [], "running_schedules": ["abc"]}`,
+			expected: snapshot.ErrCorruptSnapshot,
+		},
+		{
+			name: "duplicate claim",
+			content: `{"version": 1, "status": {"revision": 1, "claims": [
+				{"source": "manual", "is_on_air": true}, {"source": "manual", "is_on_air": false}]}}`,
+			expected: snapshot.ErrCorruptSnapshot,
+		},
+		{
+			name:     "missing channel status",
+			content:  `{"version": 3, "status": {"revision": 1}, "channels": {"studio-b": {}}}`,
+			expected: snapshot.ErrCorruptSnapshot,
+		},
+		{
+			name:     "future version",
+			content:  `{"version": 99, "status": "changed shape"}`,
+			expected: snapshot.ErrUnsupportedVersion,
+		},
+	}
+
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	for _, tc := range testData {
+		dir := t.TempDir()
+		if tc.content != "" {
+			assert.NilError(t, os.WriteFile(filepath.Join(dir, "state.json"), []byte(tc.content), 0o600), tc.name)
+		}
+
+		svcs := newServices(t, dir)
+		err := svcs.snap.Restore(ctx, wl)
+		if tc.expected == nil {
+			assert.NilError(t, err, tc.name)
+			continue
+		}
+		assert.ErrorIs(t, err, tc.expected, tc.name)
+	}
+}
+
+func TestRoundTripChannels(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+	dir := t.TempDir()
+
+	src := newServices(t, dir, "studio-a", "studio-b")
+	studioA, err := src.channels.Get(ctx, wl, "studio-a")
+	assert.NilError(t, err)
+	for _, onAir := range []bool{true, false, true} {
+		_, err := studioA.SetOnAirStatus(ctx, wl, entities.OnAirStatus{IsOnAir: onAir, Message: "studio a"})
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, src.snap.Save(ctx, wl))
+
+	// studio-b has been removed from the configuration and studio-c added
+	dst := newServices(t, dir, "studio-a", "studio-c")
+	assert.NilError(t, dst.snap.Restore(ctx, wl))
+
+	restored, err := dst.channels.Get(ctx, wl, "studio-a")
+	assert.NilError(t, err)
+	want, err := studioA.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	got, err := restored.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, got.IsOnAir, true)
+	assert.Equal(t, got.Message, "studio a")
+	assert.Equal(t, got.Revision, want.Revision)
+
+	sessions, err := restored.ListSessions(ctx, wl, entities.SessionFilter{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 2)
+
+	// the other channels keep their own state
+	onAir, err := dst.onAir.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, onAir.IsOnAir, false)
+	studioC, err := dst.channels.Get(ctx, wl, "studio-c")
+	assert.NilError(t, err)
+	onAir, err = studioC.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, onAir.IsOnAir, false)
+}
+
+// TestOlderVersionRejectsLocksAndClaims makes sure a rollback doesn't drop
+// the locks and claims silently.
+func TestOlderVersionRejectsLocksAndClaims(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+	dir := t.TempDir()
+
+	svcs := newServices(t, dir)
+	_, err := svcs.onAir.Claim(ctx, wl, entities.Claim{Source: entities.SourceCalendar, IsOnAir: true, Message: "meeting"})
+	assert.NilError(t, err)
+	_, err = svcs.onAir.Lock(ctx, wl, "recording", null.Time{})
+	assert.NilError(t, err)
+	assert.NilError(t, svcs.snap.Save(ctx, wl))
+
+	b, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	assert.NilError(t, err)
+
+	assert.Equal(t, snapshot.Version, 3)
+	assert.NilError(t, snapshot.DecodeAs(b, snapshot.Version))
+	assert.ErrorIs(t, snapshot.DecodeAs(b, 1), snapshot.ErrUnsupportedVersion)
+}
+
+// TestRestoreWithoutClaims makes sure the status of the snapshots taken
+// before claims is restored as a manual change, which lasts until another
+// source changes its claim.
+func TestRestoreWithoutClaims(t *testing.T) {
+	ctx := context.Background()
+	wl := wlog.NewNopLogger()
+
+	dir := t.TempDir()
+	content := `{"version": 1, "status": {"revision": 3, "is_on_air": true, "message": "live"}}`
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "state.json"), []byte(content), 0o600))
+
+	svcs := newServices(t, dir)
+	assert.NilError(t, svcs.snap.Restore(ctx, wl))
+
+	onAir, err := svcs.onAir.GetOnAirStatus(ctx, wl)
+	assert.NilError(t, err)
+	assert.Equal(t, onAir.IsOnAir, true)
+	assert.Equal(t, onAir.Message, "live")
+	assert.Equal(t, onAir.Source, entities.SourceManual)
+	assert.Equal(t, len(onAir.Claims), 1)
+
+	onAir, err = svcs.onAir.Claim(ctx, wl, entities.Claim{Source: entities.SourceCalendar, IsOnAir: true, Message: "meeting"})
+	assert.NilError(t, err)
+	assert.Equal(t, onAir.Message, "meeting")
+	assert.Equal(t, onAir.Source, entities.SourceCalendar)
+	assert.Equal(t, len(onAir.Claims), 1)
+}
+
+func TestStartSavesOnShutdown(t *testing.T) {
+	wl := wlog.NewNopLogger()
+	dir := t.TempDir()
+
+	svcs := newServices(t, dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := svcs.snap.Start(ctx, wl)
+	cancel()
+	<-done
+
+	_, err := os.Stat(filepath.Join(dir, "state.json"))
+	assert.NilError(t, err)
+}
@@ -1,6 +1,14 @@
 package wlog
 
 import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"time"
+
 	"github.com/caarlos0/env/v6"
 	"github.com/rs/zerolog"
 )
@@ -9,31 +17,153 @@ import (
 // at different severity levels as well as methods to add metadata to the logger.
 type BasicLogger struct {
 	zlog zerolog.Logger
+	// stack records the stack trace of the logged errors
+	stack bool
+	// exit ends the program after a fatal error, Fatal doesn't exit when nil
+	exit func(code int)
+	// gcp formats the entries for Cloud Logging, see FormatGCP
+	gcp bool
+	// project prefixes the trace IDs in the gcp format
+	project string
+	// service and version identify the errors reported in the gcp format
+	service string
+	version string
+	// labels are added to every entry, they're never modified in place
+	labels map[string]string
 }
 
 // Debug logs a Debug level message.
 func (bl BasicLogger) Debug(msg string) {
-	bl.zlog.Debug().Msg(msg)
+	bl.newEvent(zerolog.DebugLevel).Msg(msg)
 }
 
 // Debugf logs a Debug level message with formatting.
 func (bl BasicLogger) Debugf(msg string, v ...interface{}) {
-	bl.zlog.Debug().Msgf(msg, v...)
+	bl.newEvent(zerolog.DebugLevel).Msgf(msg, v...)
 }
 
 // Info logs an Info level message.
 func (bl BasicLogger) Info(msg string) {
-	bl.zlog.Info().Msg(msg)
+	bl.newEvent(zerolog.InfoLevel).Msg(msg)
 }
 
 // Infof logs an Info level message with formatting.
 func (bl BasicLogger) Infof(msg string, v ...interface{}) {
-	bl.zlog.Info().Msgf(msg, v...)
+	bl.newEvent(zerolog.InfoLevel).Msgf(msg, v...)
+}
+
+// Warn logs a Warn level message.
+func (bl BasicLogger) Warn(msg string) {
+	bl.newEvent(zerolog.WarnLevel).Msg(msg)
+}
+
+// Warnf logs a Warn level message with formatting.
+func (bl BasicLogger) Warnf(msg string, v ...interface{}) {
+	bl.newEvent(zerolog.WarnLevel).Msgf(msg, v...)
 }
 
-// Error logs an Error level message.
+// Error logs an Error level message with the chain of wrapped errors, and
+// the stack trace when enabled.
 func (bl BasicLogger) Error(err error) {
-	bl.zlog.Error().Msg(err.Error())
+	bl.errorEvent(bl.newEvent(zerolog.ErrorLevel), err).Msg(err.Error())
+}
+
+// Fatal logs a Fatal level message like Error then exits the program.
+func (bl BasicLogger) Fatal(err error) {
+	// zerolog's Fatal would exit even when the level is disabled
+	bl.errorEvent(bl.newEvent(zerolog.FatalLevel), err).Msg(err.Error())
+	if bl.exit != nil {
+		bl.exit(1)
+	}
+}
+
+// Access logs an Info level access log entry for the request.
+func (bl BasicLogger) Access(req HTTPRequest) {
+	bl.newEvent(zerolog.InfoLevel).
+		Dict(LogKeyHTTPRequest, httpRequestDict(req)).
+		Msgf("%s %s %d", req.Method, req.URL, req.Status)
+}
+
+// newEvent starts an entry at the level, nil when the level is disabled. It
+// must be called by the logging methods themselves, the gcp format records
+// their caller as the source location.
+func (bl BasicLogger) newEvent(level zerolog.Level) *zerolog.Event {
+	if !bl.gcp {
+		return bl.withLabels(bl.zlog.WithLevel(level), LogKeyLabels)
+	}
+
+	// the entries are filtered by level but carry the severity of GCP instead
+	if level < bl.zlog.GetLevel() {
+		return nil
+	}
+	e := bl.zlog.WithLevel(zerolog.NoLevel).
+		Str(zerolog.LevelFieldName, gcpSeverities[level]).
+		Dict(gcpKeySourceLocation, gcpSourceLocation(caller(2)))
+
+	return bl.withLabels(e, gcpKeyLabels)
+}
+
+// withLabels adds the labels of the logger to the event under key.
+func (bl BasicLogger) withLabels(e *zerolog.Event, key string) *zerolog.Event {
+	if len(bl.labels) == 0 || !e.Enabled() {
+		return e
+	}
+
+	keys := make([]string, 0, len(bl.labels))
+	for k := range bl.labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	labels := zerolog.Dict()
+	for _, k := range keys {
+		labels = labels.Str(k, bl.labels[k])
+	}
+	return e.Dict(key, labels)
+}
+
+// errorEvent adds the chain of errors wrapped by err, and the stack trace of
+// the caller of Error or Fatal when enabled, to the event. In the gcp format
+// the error is reported to Error Reporting.
+func (bl BasicLogger) errorEvent(e *zerolog.Event, err error) *zerolog.Event {
+	if !e.Enabled() {
+		return e
+	}
+	if chain := errorChain(err); len(chain) > 0 {
+		e = e.Strs(LogKeyErrorChain, chain)
+	}
+
+	// skip errorEvent and Error or Fatal
+	var stack []runtime.Frame
+	if bl.stack {
+		stack = callers(2)
+	}
+
+	if !bl.gcp {
+		if stack != nil {
+			frames := make([]string, 0, len(stack))
+			for _, f := range stack {
+				frames = append(frames, fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line))
+			}
+			e = e.Strs(LogKeyStack, frames)
+		}
+		return e
+	}
+
+	e = e.Str(gcpKeyType, gcpReportedErrorEvent).
+		Dict(gcpKeyContext, gcpErrorContext(caller(2)))
+	if bl.service != "" {
+		svc := zerolog.Dict().Str("service", bl.service)
+		if bl.version != "" {
+			svc = svc.Str("version", bl.version)
+		}
+		e = e.Dict(gcpKeyServiceContext, svc)
+	}
+	if stack != nil {
+		e = e.Str(gcpKeyStackTrace, gcpStackTrace(err, stack))
+	}
+
+	return e
 }
 
 // WithStr returns the logger with added key-value strings metadata.
@@ -42,6 +172,58 @@ func (bl BasicLogger) WithStr(key string, value string) Logger {
 	return bl
 }
 
+// WithInt returns the logger with added key-value integer metadata.
+func (bl BasicLogger) WithInt(key string, value int) Logger {
+	bl.zlog = bl.zlog.With().Int(key, value).Logger()
+	return bl
+}
+
+// WithDur returns the logger with added key-value duration metadata.
+func (bl BasicLogger) WithDur(key string, value time.Duration) Logger {
+	bl.zlog = bl.zlog.With().Dur(key, value).Logger()
+	return bl
+}
+
+// WithBool returns the logger with added key-value boolean metadata.
+func (bl BasicLogger) WithBool(key string, value bool) Logger {
+	bl.zlog = bl.zlog.With().Bool(key, value).Logger()
+	return bl
+}
+
+// WithErr returns the logger with the error added as metadata.
+func (bl BasicLogger) WithErr(err error) Logger {
+	bl.zlog = bl.zlog.With().Err(err).Logger()
+	return bl
+}
+
+// WithLabel returns the logger with an added label, to filter the entries
+// by in Cloud Logging.
+func (bl BasicLogger) WithLabel(key string, value string) Logger {
+	labels := maps.Clone(bl.labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[key] = value
+	bl.labels = labels
+	return bl
+}
+
+// WithTrace returns the logger with the entries tied to the trace and,
+// when set, the hexadecimal span. The gcp format prefixes the trace with
+// the project, as Cloud Logging expects.
+func (bl BasicLogger) WithTrace(traceID string, spanID string) Logger {
+	if bl.gcp {
+		traceID = "projects/" + bl.project + "/traces/" + traceID
+	}
+
+	ctx := bl.zlog.With().Str(LogKeyTraceID, traceID)
+	if spanID != "" {
+		ctx = ctx.Str(LogKeySpanID, spanID)
+	}
+	bl.zlog = ctx.Logger()
+	return bl
+}
+
 // NewBasicLogger initializes a new BasicLogger with
 // config values parsed from the runtime environment.
 func NewBasicLogger() (Logger, error) {
@@ -56,14 +238,29 @@ func NewBasicLogger() (Logger, error) {
 // NewBasicLoggerWithConfig is the same as NewBasicLogger
 // but can be used to specify a custom Logger config.
 func NewBasicLoggerWithConfig(cfg *Config) (Logger, error) {
+	return NewBasicLoggerWithOutput(cfg, os.Stderr)
+}
+
+// NewBasicLoggerWithOutput is the same as NewBasicLoggerWithConfig
+// but writes the logs to w instead of stderr.
+func NewBasicLoggerWithOutput(cfg *Config, w io.Writer) (Logger, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 
-	zlog, err := zLogFromConfig(cfg)
+	zlog, err := zLogFromConfig(cfg, w)
 	if err != nil {
 		return nil, err
 	}
 
-	return BasicLogger{zlog: zlog}, nil
+	return BasicLogger{
+		zlog:    zlog,
+		stack:   cfg.ErrorStack,
+		exit:    os.Exit,
+		gcp:     cfg.Format == FormatGCP,
+		project: cfg.Project,
+		service: cfg.Service,
+		version: cfg.Version,
+		labels:  cfg.labels(),
+	}, nil
 }
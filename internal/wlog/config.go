@@ -1,6 +1,19 @@
 package wlog
 
-import validation "github.com/go-ozzo/ozzo-validation/v4"
+import (
+	"errors"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Output formats
+const (
+	// FormatJSON writes an entry per line with the fields as they're set.
+	FormatJSON = "json"
+	// FormatGCP writes the entries in the structured format of Cloud Logging.
+	FormatGCP = "gcp"
+)
 
 // Config holds the configuration options for a Logger.
 type Config struct {
@@ -8,13 +21,49 @@ type Config struct {
 	MinLogLevel string `env:"MIN_LOG_LEVEL"`
 	// Enables pretty log printing if true
 	PrettyLogs bool `env:"PRETTY_LOGS"`
+	// Records the stack trace of the logged errors if true
+	ErrorStack bool `env:"LOG_ERROR_STACK"`
+	// The output format, json or gcp
+	Format string `env:"LOG_FORMAT" envDefault:"json"`
+	// The labels added to every entry, as key=value
+	Labels []string `env:"LOG_LABELS" envSeparator:","`
+	// The Google Cloud project prefixing the trace IDs in the gcp format
+	Project string `env:"GOOGLE_CLOUD_PROJECT"`
+	// The service and version the errors are reported for in the gcp format,
+	// set by Cloud Run
+	Service string `env:"K_SERVICE"`
+	Version string `env:"K_REVISION"`
 }
 
 // Validate makes sure the configuration is valid.
 // It returns an error when the configuration is not valid.
 func (c *Config) Validate() error {
+	gcp := c.Format == FormatGCP
+
 	return validation.ValidateStruct(
 		c,
-		validation.Field(&c.MinLogLevel, validation.In("debug", "info", "error")),
+		validation.Field(&c.MinLogLevel, validation.In("debug", "info", "warn", "error", "fatal")),
+		validation.Field(&c.Format, validation.In(FormatJSON, FormatGCP)),
+		validation.Field(&c.PrettyLogs, validation.When(gcp, validation.Empty.Error("can't be set along with the gcp format"))),
+		validation.Field(&c.Labels, validation.Each(validation.By(validateLabel))),
+		validation.Field(&c.Project, validation.When(gcp, validation.Required)),
 	)
 }
+
+func validateLabel(value interface{}) error {
+	label, _ := value.(string)
+	if key, _, ok := strings.Cut(label, "="); !ok || key == "" {
+		return errors.New("must be a key=value pair")
+	}
+	return nil
+}
+
+// labels returns the labels by key.
+func (c *Config) labels() map[string]string {
+	labels := make(map[string]string, len(c.Labels))
+	for _, l := range c.Labels {
+		key, value, _ := strings.Cut(l, "=")
+		labels[key] = value
+	}
+	return labels
+}
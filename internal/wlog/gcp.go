@@ -0,0 +1,76 @@
+package wlog
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// The special fields of Cloud Logging and Error Reporting, see
+// https://cloud.google.com/logging/docs/structured-logging and
+// https://cloud.google.com/error-reporting/docs/formatting-error-messages
+const (
+	gcpKeySourceLocation = "logging.googleapis.com/sourceLocation"
+	gcpKeyLabels         = "logging.googleapis.com/labels"
+	gcpKeyType           = "@type"
+	gcpKeyServiceContext = "serviceContext"
+	gcpKeyContext        = "context"
+	gcpKeyStackTrace     = "stack_trace"
+	// gcpReportedErrorEvent has Error Reporting group the errors logged
+	gcpReportedErrorEvent = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+)
+
+// gcpSeverities maps the levels to the severities of Cloud Logging.
+var gcpSeverities = map[zerolog.Level]string{
+	zerolog.DebugLevel: "DEBUG",
+	zerolog.InfoLevel:  "INFO",
+	zerolog.WarnLevel:  "WARNING",
+	zerolog.ErrorLevel: "ERROR",
+	zerolog.FatalLevel: "CRITICAL",
+}
+
+// gcpSourceLocation returns the sourceLocation of an entry logged at f.
+func gcpSourceLocation(f runtime.Frame) *zerolog.Event {
+	return zerolog.Dict().
+		Str("file", f.File).
+		Str("line", strconv.Itoa(f.Line)).
+		Str("function", f.Function)
+}
+
+// gcpErrorContext returns the context of an error reported at f.
+func gcpErrorContext(f runtime.Frame) *zerolog.Event {
+	return zerolog.Dict().Dict("reportLocation", zerolog.Dict().
+		Str("filePath", f.File).
+		Int("lineNumber", f.Line).
+		Str("functionName", f.Function))
+}
+
+// gcpStackTrace formats the error and stack like a Go panic, which Error
+// Reporting parses.
+func gcpStackTrace(err error, stack []runtime.Frame) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\ngoroutine 1 [running]:\n", err)
+	for _, f := range stack {
+		fmt.Fprintf(&b, "%s()\n\t%s:%d\n", f.Function, f.File, f.Line)
+	}
+	return b.String()
+}
+
+// httpRequestDict returns the request in the format of the httpRequest of
+// Cloud Logging, used by both formats.
+func httpRequestDict(req HTTPRequest) *zerolog.Event {
+	return zerolog.Dict().
+		Str("requestMethod", req.Method).
+		Str("requestUrl", req.URL).
+		Int("status", req.Status).
+		Str("requestSize", strconv.FormatInt(req.RequestSize, 10)).
+		Str("responseSize", strconv.FormatInt(req.ResponseSize, 10)).
+		Str("userAgent", req.UserAgent).
+		Str("remoteIp", req.RemoteIP).
+		Str("referer", req.Referer).
+		Str("protocol", req.Protocol).
+		Str("latency", strconv.FormatFloat(req.Latency.Seconds(), 'f', -1, 64)+"s")
+}
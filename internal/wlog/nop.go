@@ -2,7 +2,8 @@ package wlog
 
 import "github.com/rs/zerolog"
 
-// NewNopLogger returns a Logger where all operations are no-op.
+// NewNopLogger returns a Logger where all operations are no-op, Fatal
+// doesn't exit either.
 func NewNopLogger() Logger {
 	return BasicLogger{zlog: zerolog.Nop()}
 }
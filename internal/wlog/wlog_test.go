@@ -0,0 +1,311 @@
+package wlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"on-air/internal/wlog"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// newLogger returns a logger writing to the returned buffer.
+func newLogger(t *testing.T, cfg *wlog.Config) (wlog.Logger, *bytes.Buffer) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	wl, err := wlog.NewBasicLoggerWithOutput(cfg, &buf)
+	assert.NilError(t, err)
+
+	return wl, &buf
+}
+
+// entries decodes every line logged.
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var logged []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		assert.NilError(t, json.Unmarshal([]byte(line), &entry))
+		logged = append(logged, entry)
+	}
+
+	return logged
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     wlog.Config
+		wantErr string
+	}{
+		{name: "default", cfg: wlog.Config{}},
+		{name: "debug", cfg: wlog.Config{MinLogLevel: "debug"}},
+		{name: "info", cfg: wlog.Config{MinLogLevel: "info"}},
+		{name: "warn", cfg: wlog.Config{MinLogLevel: "warn"}},
+		{name: "error", cfg: wlog.Config{MinLogLevel: "error"}},
+		{name: "fatal", cfg: wlog.Config{MinLogLevel: "fatal"}},
+		{name: "trace", cfg: wlog.Config{MinLogLevel: "trace"}, wantErr: "MinLogLevel"},
+		{name: "verbose", cfg: wlog.Config{MinLogLevel: "verbose"}, wantErr: "MinLogLevel"},
+		{name: "gcp", cfg: wlog.Config{Format: wlog.FormatGCP, Project: "radio", Labels: []string{"env=prod"}}},
+		{name: "unknown format", cfg: wlog.Config{Format: "xml"}, wantErr: "Format"},
+		{name: "gcp without project", cfg: wlog.Config{Format: wlog.FormatGCP}, wantErr: "Project"},
+		{name: "pretty gcp", cfg: wlog.Config{Format: wlog.FormatGCP, Project: "radio", PrettyLogs: true}, wantErr: "PrettyLogs"},
+		{name: "label without value", cfg: wlog.Config{Labels: []string{"env"}}, wantErr: "Labels"},
+		{name: "label without key", cfg: wlog.Config{Labels: []string{"=prod"}}, wantErr: "Labels"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestLevels(t *testing.T) {
+	wl, buf := newLogger(t, &wlog.Config{MinLogLevel: "warn"})
+
+	wl.Debug("debug")
+	wl.Info("info")
+	wl.Warn("warn")
+	wl.Warnf("warn %d", 2)
+	wl.Error(errors.New("error"))
+
+	logged := entries(t, buf)
+	assert.Equal(t, len(logged), 3)
+	for i, want := range []string{"warn", "warn 2", "error"} {
+		assert.Equal(t, logged[i]["message"], want)
+	}
+	assert.Equal(t, logged[0]["severity"], "warn")
+	assert.Equal(t, logged[2]["severity"], "error")
+}
+
+func TestFields(t *testing.T) {
+	wl, buf := newLogger(t, &wlog.Config{MinLogLevel: "debug"})
+
+	wl.WithStr("channel", "studio").
+		WithInt("revision", 42).
+		WithDur("wait", 1500*time.Millisecond).
+		WithBool("on_air", true).
+		WithErr(errors.New("boom")).
+		Info("fields")
+
+	logged := entries(t, buf)
+	assert.Equal(t, len(logged), 1)
+	assert.Equal(t, logged[0]["channel"], "studio")
+	assert.Equal(t, logged[0]["revision"], float64(42))
+	assert.Equal(t, logged[0]["wait"], float64(1500))
+	assert.Equal(t, logged[0]["on_air"], true)
+	assert.Equal(t, logged[0]["error"], "boom")
+}
+
+func TestErrorChain(t *testing.T) {
+	root := errors.New("connection refused")
+	other := errors.New("timeout")
+	err := fmt.Errorf("unable to publish: %w", errors.Join(fmt.Errorf("dial: %w", root), other))
+
+	tests := []struct {
+		name      string
+		err       error
+		wantChain []interface{}
+	}{
+		{name: "single", err: root},
+		{
+			name: "wrapped",
+			err:  err,
+			wantChain: []interface{}{
+				"dial: connection refused\ntimeout",
+				"dial: connection refused",
+				"connection refused",
+				"timeout",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wl, buf := newLogger(t, &wlog.Config{MinLogLevel: "debug"})
+			wl.Error(tt.err)
+
+			logged := entries(t, buf)
+			assert.Equal(t, len(logged), 1)
+			assert.Equal(t, logged[0]["message"], tt.err.Error())
+			if tt.wantChain == nil {
+				_, ok := logged[0]["error_chain"]
+				assert.Assert(t, !ok)
+				return
+			}
+			assert.DeepEqual(t, logged[0]["error_chain"], tt.wantChain)
+		})
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprint(enabled), func(t *testing.T) {
+			wl, buf := newLogger(t, &wlog.Config{MinLogLevel: "debug", ErrorStack: enabled})
+			wl.Error(errors.New("boom"))
+
+			logged := entries(t, buf)
+			assert.Equal(t, len(logged), 1)
+			stack, ok := logged[0]["stack"].([]interface{})
+			assert.Equal(t, ok, enabled)
+			if enabled {
+				// the stack starts at the caller of Error
+				assert.Assert(t, strings.HasPrefix(stack[0].(string), "on-air/internal/wlog_test.TestErrorStack."), stack[0])
+			}
+		})
+	}
+}
+
+func TestNopLogger(t *testing.T) {
+	wl := wlog.NewNopLogger().WithInt("revision", 1).WithDur("wait", time.Second).WithBool("on_air", true)
+
+	wl.Warn("warn")
+	wl.Error(errors.New("error"))
+	// the program keeps running
+	wl.Fatal(errors.New("fatal"))
+}
+
+func TestTrace(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       wlog.Config
+		header    string
+		wantTrace interface{}
+		wantSpan  interface{}
+	}{
+		{
+			name:      "json",
+			cfg:       wlog.Config{MinLogLevel: "info"},
+			header:    "105445aa7843bc8bf206b12000100000/1;o=1",
+			wantTrace: "105445aa7843bc8bf206b12000100000",
+			wantSpan:  "0000000000000001",
+		},
+		{
+			name:      "gcp",
+			cfg:       wlog.Config{MinLogLevel: "info", Format: wlog.FormatGCP, Project: "radio"},
+			header:    "105445aa7843bc8bf206b12000100000/1;o=1",
+			wantTrace: "projects/radio/traces/105445aa7843bc8bf206b12000100000",
+			wantSpan:  "0000000000000001",
+		},
+		{
+			name:      "without span",
+			cfg:       wlog.Config{MinLogLevel: "info", Format: wlog.FormatGCP, Project: "radio"},
+			header:    "105445aa7843bc8bf206b12000100000",
+			wantTrace: "projects/radio/traces/105445aa7843bc8bf206b12000100000",
+		},
+		{
+			name:   "without trace",
+			cfg:    wlog.Config{MinLogLevel: "info", Format: wlog.FormatGCP, Project: "radio"},
+			header: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wl, buf := newLogger(t, &tt.cfg)
+			wlog.WithTraceHeader(wl, tt.header).Info("traced")
+
+			logged := entries(t, buf)
+			assert.Equal(t, len(logged), 1)
+			assert.Equal(t, logged[0]["logging.googleapis.com/trace"], tt.wantTrace)
+			assert.Equal(t, logged[0]["logging.googleapis.com/spanId"], tt.wantSpan)
+		})
+	}
+}
+
+func TestGCPFormat(t *testing.T) {
+	wl, buf := newLogger(t, &wlog.Config{
+		MinLogLevel: "info",
+		Format:      wlog.FormatGCP,
+		Project:     "radio",
+		Labels:      []string{"env=prod"},
+		Service:     "on-air",
+		Version:     "on-air-00042",
+		ErrorStack:  true,
+	})
+
+	wl.Debug("filtered")
+	wl.Info("info")
+	wl.WithLabel("channel", "studio").Warn("warn")
+	wl.Error(fmt.Errorf("unable to publish: %w", errors.New("timeout")))
+
+	logged := entries(t, buf)
+	assert.Equal(t, len(logged), 3)
+	for i, want := range []string{"INFO", "WARNING", "ERROR"} {
+		assert.Equal(t, logged[i]["severity"], want)
+	}
+
+	// the source location is the caller of the logger
+	loc := logged[0]["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+	assert.Assert(t, strings.HasSuffix(loc["file"].(string), "/wlog_test.go"), loc["file"])
+	assert.Equal(t, loc["function"], "on-air/internal/wlog_test.TestGCPFormat")
+
+	assert.DeepEqual(t, logged[0]["logging.googleapis.com/labels"], map[string]interface{}{"env": "prod"})
+	assert.DeepEqual(t, logged[1]["logging.googleapis.com/labels"], map[string]interface{}{"env": "prod", "channel": "studio"})
+
+	// only the errors are reported
+	_, ok := logged[1]["@type"]
+	assert.Assert(t, !ok)
+
+	reported := logged[2]
+	assert.Equal(t, reported["@type"], "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent")
+	assert.Equal(t, reported["message"], "unable to publish: timeout")
+	assert.DeepEqual(t, reported["error_chain"], []interface{}{"timeout"})
+	assert.DeepEqual(t, reported["serviceContext"], map[string]interface{}{"service": "on-air", "version": "on-air-00042"})
+
+	report := reported["context"].(map[string]interface{})["reportLocation"].(map[string]interface{})
+	assert.Equal(t, report["functionName"], "on-air/internal/wlog_test.TestGCPFormat")
+	errLoc := reported["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+	assert.Equal(t, fmt.Sprint(report["lineNumber"]), errLoc["line"])
+
+	stack := reported["stack_trace"].(string)
+	assert.Assert(t, strings.HasPrefix(stack, "unable to publish: timeout\n\ngoroutine 1 [running]:\non-air/internal/wlog_test.TestGCPFormat()\n\t"), stack)
+	_, ok = reported["stack"]
+	assert.Assert(t, !ok)
+}
+
+func TestAccess(t *testing.T) {
+	wl, buf := newLogger(t, &wlog.Config{MinLogLevel: "info"})
+
+	wl.Access(wlog.HTTPRequest{
+		Method:       "GET",
+		URL:          "/v1/onAir",
+		Status:       200,
+		ResponseSize: 120,
+		RemoteIP:     "203.0.113.7",
+		Protocol:     "HTTP/1.1",
+		Latency:      1500 * time.Millisecond,
+	})
+
+	logged := entries(t, buf)
+	assert.Equal(t, len(logged), 1)
+	assert.Equal(t, logged[0]["severity"], "info")
+	assert.Equal(t, logged[0]["message"], "GET /v1/onAir 200")
+	assert.DeepEqual(t, logged[0]["httpRequest"], map[string]interface{}{
+		"requestMethod": "GET",
+		"requestUrl":    "/v1/onAir",
+		"status":        float64(200),
+		"requestSize":   "0",
+		"responseSize":  "120",
+		"userAgent":     "",
+		"remoteIp":      "203.0.113.7",
+		"referer":       "",
+		"protocol":      "HTTP/1.1",
+		"latency":       "1.5s",
+	})
+}
@@ -6,8 +6,11 @@ package wlog
 import (
 	"context"
 	"fmt"
+	"io"
 	"on-air/internal/acontext"
-	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -20,8 +23,20 @@ const (
 	LogKeyEventID   = "event_id"
 	LogKeyEventType = "event_type"
 	LogKeyTraceID   = "logging.googleapis.com/trace"
+	LogKeySpanID    = "logging.googleapis.com/spanId"
+	// LogKeyLabels holds the labels of an entry in the json format
+	LogKeyLabels = "labels"
+	// LogKeyHTTPRequest holds the request of an access log entry
+	LogKeyHTTPRequest = "httpRequest"
+	// LogKeyErrorChain holds the messages of the errors wrapped by a logged error
+	LogKeyErrorChain = "error_chain"
+	// LogKeyStack holds the stack trace of a logged error, see Config.ErrorStack
+	LogKeyStack = "stack"
 )
 
+// maxStackDepth is the number of frames recorded in a stack trace.
+const maxStackDepth = 32
+
 func init() {
 	// renames level to severity for GCP
 	zerolog.LevelFieldName = "severity"
@@ -37,19 +52,55 @@ type Logger interface {
 	Info(msg string)
 	// Infof logs an Info level message with formatting.
 	Infof(msg string, v ...interface{})
-	// Error logs an Error level message.
+	// Warn logs a Warn level message.
+	Warn(msg string)
+	// Warnf logs a Warn level message with formatting.
+	Warnf(msg string, v ...interface{})
+	// Error logs an Error level message with the chain of wrapped errors.
 	Error(err error)
+	// Fatal logs a Fatal level message like Error then exits the program.
+	Fatal(err error)
 	// WithStr returns the logger with added key-value strings metadata.
 	WithStr(key string, value string) Logger
+	// WithInt returns the logger with added key-value integer metadata.
+	WithInt(key string, value int) Logger
+	// WithDur returns the logger with added key-value duration metadata.
+	WithDur(key string, value time.Duration) Logger
+	// WithBool returns the logger with added key-value boolean metadata.
+	WithBool(key string, value bool) Logger
+	// WithErr returns the logger with the error added as metadata.
+	WithErr(err error) Logger
+	// WithLabel returns the logger with an added label, to filter the entries
+	// by in Cloud Logging.
+	WithLabel(key string, value string) Logger
+	// WithTrace returns the logger with the entries tied to the trace and,
+	// when set, the hexadecimal span.
+	WithTrace(traceID string, spanID string) Logger
+	// Access logs an Info level access log entry for the request.
+	Access(req HTTPRequest)
+}
+
+// HTTPRequest describes a request served, for access logs.
+type HTTPRequest struct {
+	Method       string
+	URL          string
+	Status       int
+	RequestSize  int64
+	ResponseSize int64
+	UserAgent    string
+	RemoteIP     string
+	Referer      string
+	Protocol     string
+	Latency      time.Duration
 }
 
-func zLogFromConfig(cfg *Config) (zerolog.Logger, error) {
+func zLogFromConfig(cfg *Config, w io.Writer) (zerolog.Logger, error) {
 	// create a new zerologger
-	l := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	l := zerolog.New(w).With().Timestamp().Logger()
 
 	// config pretty logs
 	if cfg.PrettyLogs {
-		l = l.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.StampMilli})
+		l = l.Output(zerolog.ConsoleWriter{Out: w, TimeFormat: time.StampMilli})
 	}
 
 	// we want all available precision
@@ -74,7 +125,7 @@ func zLogFromConfig(cfg *Config) (zerolog.Logger, error) {
 //   - callerID
 //   - serviceName
 //   - userID
-//   - traceID
+//   - traceID and spanID
 //   - chain
 func WithServiceRequest(ctx context.Context, l Logger, serviceName string) Logger {
 	if requestID, ok := ctx.Value(acontext.ContextKeyRequestIDHeader).(string); ok {
@@ -86,8 +137,8 @@ func WithServiceRequest(ctx context.Context, l Logger, serviceName string) Logge
 	if userID, ok := ctx.Value(acontext.ContextKeyUserID).(string); ok {
 		l = l.WithStr(LogKeyUserID, userID)
 	}
-	if traceID, ok := ctx.Value(acontext.ContextKeyTraceIDHeader).(string); ok {
-		l = l.WithStr(LogKeyTraceID, traceID)
+	if header, ok := ctx.Value(acontext.ContextKeyTraceIDHeader).(string); ok {
+		l = WithTraceHeader(l, header)
 	}
 
 	l = l.WithStr("serviceName", serviceName)
@@ -95,6 +146,24 @@ func WithServiceRequest(ctx context.Context, l Logger, serviceName string) Logge
 	return l
 }
 
+// WithTraceHeader adds the trace and span of an X-Cloud-Trace-Context header,
+// formatted as TRACE_ID/SPAN_ID;o=OPTIONS, to the logger.
+func WithTraceHeader(l Logger, header string) Logger {
+	traceID, rest, _ := strings.Cut(header, "/")
+	if traceID == "" {
+		return l
+	}
+
+	// the span ID is decimal in the header and hexadecimal in the logs
+	var spanID string
+	span, _, _ := strings.Cut(rest, ";")
+	if id, err := strconv.ParseUint(span, 10, 64); err == nil {
+		spanID = fmt.Sprintf("%016x", id)
+	}
+
+	return l.WithTrace(traceID, spanID)
+}
+
 // WithUserID adds the user id to the logger
 func WithUserID(l Logger, userID string) Logger {
 	return l.WithStr(LogKeyUserID, userID)
@@ -114,3 +183,59 @@ func WithEventID(l Logger, eventID string) Logger {
 func WithEventType(l Logger, eventType string) Logger {
 	return l.WithStr(LogKeyEventType, eventType)
 }
+
+// errorChain returns the messages of the errors wrapped by err, depth first.
+// The message of err itself is not included.
+func errorChain(err error) []string {
+	var chain []string
+
+	var walk func(err error)
+	walk = func(err error) {
+		var wrapped []error
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			wrapped = []error{e.Unwrap()}
+		case interface{ Unwrap() []error }:
+			wrapped = e.Unwrap()
+		}
+
+		for _, w := range wrapped {
+			if w != nil {
+				chain = append(chain, w.Error())
+				walk(w)
+			}
+		}
+	}
+	walk(err)
+
+	return chain
+}
+
+// caller returns the frame of the caller, skipping the given number of
+// frames, 0 being the caller of caller.
+func caller(skip int) runtime.Frame {
+	pcs := make([]uintptr, 1)
+	runtime.Callers(skip+2, pcs)
+	f, _ := runtime.CallersFrames(pcs).Next()
+
+	return f
+}
+
+// callers returns the stack trace of the goroutine, skipping the given
+// number of frames, 0 being the caller of callers.
+func callers(skip int) []runtime.Frame {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	stack := make([]runtime.Frame, 0, n)
+	for {
+		f, more := frames.Next()
+		stack = append(stack, f)
+		if !more {
+			break
+		}
+	}
+
+	return stack
+}
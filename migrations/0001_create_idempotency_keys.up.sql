@@ -0,0 +1,12 @@
+-- the responses replayed to requests retried with the same Idempotency-Key
+CREATE TABLE idempotency_keys (
+    key         TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    -- the response columns are null while the request is in flight
+    status_code INTEGER,
+    header      JSONB,
+    body        BYTEA,
+    expires_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,13 @@
+// Package migrations holds the schema migrations embedded in the binary.
+//
+// Migrations are named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// where the down file reverts the up one. Versions are applied in order and
+// never change once released: add a new migration instead.
+package migrations
+
+import "embed"
+
+// FS holds the migration files.
+//
+//go:embed *.sql
+var FS embed.FS
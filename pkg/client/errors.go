@@ -1,6 +1,7 @@
 package client
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -42,3 +43,9 @@ func (e *FundAccountError) Error() string {
 func (e *FundAccountError) Unwrap() error {
 	return e.Err
 }
+
+// ErrFileNotFound is returned when loading a file that doesn't exist.
+var ErrFileNotFound = errors.New("file not found")
+
+// ErrInvalidPath is returned when a file path escapes the storage root.
+var ErrInvalidPath = errors.New("invalid file path")
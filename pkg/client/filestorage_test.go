@@ -0,0 +1,182 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"on-air/pkg/client"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := client.NewLocalStorage(filepath.Join(dir, "root"))
+	assert.NilError(t, err)
+	defer s.Close()
+
+	assert.NilError(t, s.Save(ctx, "snapshots/state.json", strings.NewReader("v1")))
+	assert.NilError(t, s.Save(ctx, "snapshots/state.json", strings.NewReader("v2")))
+
+	var buf bytes.Buffer
+	assert.NilError(t, s.Load(ctx, "snapshots/state.json", &buf))
+	assert.Equal(t, buf.String(), "v2")
+
+	// no temporary file is left behind
+	entries, err := os.ReadDir(filepath.Join(dir, "root", "snapshots"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 1)
+
+	err = s.Load(ctx, "missing.json", &buf)
+	assert.ErrorIs(t, err, client.ErrFileNotFound)
+
+	// an outside file and a symlink pointing to it
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0o600))
+	assert.NilError(t, os.Symlink(dir, filepath.Join(dir, "root", "escape")))
+
+	for _, p := range []string{"../secret", "/etc/passwd", "a/../../secret", "", "escape/secret", "escape/sub/secret"} {
+		err := s.Load(ctx, p, io.Discard)
+		assert.ErrorIs(t, err, client.ErrInvalidPath, p)
+
+		err = s.Save(ctx, p, strings.NewReader("pwned"))
+		assert.ErrorIs(t, err, client.ErrInvalidPath, p)
+	}
+
+	secret, err := os.ReadFile(filepath.Join(dir, "secret"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(secret), "secret")
+
+	// no directory is created through the symlink
+	_, err = os.Stat(filepath.Join(dir, "sub"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestLocalStorageKeepsFileOnFailedWrite(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := client.NewLocalStorage(t.TempDir())
+	assert.NilError(t, err)
+
+	assert.NilError(t, s.Save(ctx, "state.json", strings.NewReader("complete")))
+
+	err = s.Save(ctx, "state.json", io.MultiReader(strings.NewReader("partial"), failingReader{}))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+	var buf bytes.Buffer
+	assert.NilError(t, s.Load(ctx, "state.json", &buf))
+	assert.Equal(t, buf.String(), "complete")
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// fakeGCS stands in for the Cloud Storage JSON API.
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+		if r.URL.Query().Get("uploadType") != "media" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Query().Get("name")] = b
+		w.Write([]byte(`{"kind":"storage#object"}`))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/storage/v1/b/bucket/o/"):
+		name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")
+		b, ok := f.objects[name]
+		if !ok || r.URL.Query().Get("alt") != "media" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+type staticToken string
+
+func (s staticToken) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+func TestGCSStorage(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeGCS{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	s := client.NewGCSStorage("bucket",
+		client.WithGCSEndpoint(srv.URL),
+		client.WithGCSTokenSource(staticToken("test-token")),
+	)
+	defer s.Close()
+
+	assert.NilError(t, s.Save(ctx, "snapshots/state v1.json", strings.NewReader("v1")))
+	assert.DeepEqual(t, fake.objects["snapshots/state v1.json"], []byte("v1"))
+
+	var buf bytes.Buffer
+	assert.NilError(t, s.Load(ctx, "snapshots/state v1.json", &buf))
+	assert.Equal(t, buf.String(), "v1")
+
+	err := s.Load(ctx, "missing.json", &buf)
+	assert.ErrorIs(t, err, client.ErrFileNotFound)
+
+	for _, p := range []string{"../x", "/x", "a//b", "a/./b", ""} {
+		assert.ErrorIs(t, s.Save(ctx, p, strings.NewReader("x")), client.ErrInvalidPath, p)
+	}
+
+	unauthenticated := client.NewGCSStorage("bucket", client.WithGCSEndpoint(srv.URL))
+	err = unauthenticated.Save(ctx, "x", strings.NewReader("x"))
+	assert.ErrorIs(t, err, &client.HTTPError{StatusCode: http.StatusUnauthorized})
+}
+
+func TestGCSStorageTimeout(t *testing.T) {
+	// the server hangs until the test is over
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	s := client.NewGCSStorage("bucket",
+		client.WithGCSEndpoint(srv.URL),
+		client.WithGCSTimeout(50*time.Millisecond),
+	)
+	defer s.Close()
+
+	err := s.Save(context.Background(), "state.json", strings.NewReader("{}"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var buf bytes.Buffer
+	err = s.Load(context.Background(), "state.json", &buf)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
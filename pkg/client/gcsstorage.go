@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GCSEndpoint is the Cloud Storage API.
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// DefaultGCSTimeout bounds every operation of a GCSStorage by default.
+const DefaultGCSTimeout = 30 * time.Second
+
+// GCSOption configures a GCSStorage.
+type GCSOption func(*GCSStorage)
+
+// WithGCSEndpoint sends the requests to another endpoint, e.g. an emulator
+// or a fake server. Requests to a custom endpoint are not authenticated
+// unless a TokenSource is set too.
+func WithGCSEndpoint(endpoint string) GCSOption {
+	return func(s *GCSStorage) {
+		s.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithGCSTokenSource authenticates the requests with the tokens.
+func WithGCSTokenSource(tokens TokenSource) GCSOption {
+	return func(s *GCSStorage) {
+		s.tokens = tokens
+	}
+}
+
+// WithGCSTimeout bounds every operation, fetching the token included, so a
+// hung request doesn't block the caller.
+func WithGCSTimeout(timeout time.Duration) GCSOption {
+	return func(s *GCSStorage) {
+		s.timeout = timeout
+	}
+}
+
+// WithGCSClientOptions configures the underlying http client.
+func WithGCSClientOptions(opts ...Option) GCSOption {
+	return func(s *GCSStorage) {
+		s.client = NewHTTPClient(opts...)
+	}
+}
+
+// GCSStorage is a FileStorageProvider storing files as the objects of a
+// Cloud Storage bucket, through the JSON API.
+type GCSStorage struct {
+	bucket   string
+	endpoint string
+	client   Client
+	tokens   TokenSource
+	timeout  time.Duration
+}
+
+// NewGCSStorage returns a GCSStorage for the bucket. Requests are
+// authenticated with the instance's service account by default.
+func NewGCSStorage(bucket string, opts ...GCSOption) *GCSStorage {
+	s := &GCSStorage{
+		bucket:   bucket,
+		endpoint: GCSEndpoint,
+		client:   NewHTTPClient(),
+		timeout:  DefaultGCSTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.tokens == nil && s.endpoint == GCSEndpoint {
+		s.tokens = NewMetadataTokenSource()
+	}
+
+	return s
+}
+
+// Save uploads the content as the object at path. Uploads are atomic: the
+// object is only replaced once the whole content has been received.
+func (s *GCSStorage) Save(ctx context.Context, path string, content io.Reader) error {
+	name, err := objectName(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", s.endpoint, url.PathEscape(s.bucket),
+		url.Values{"uploadType": {"media"}, "name": {name}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, content)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("error uploading %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// Load downloads the object at path to content.
+func (s *GCSStorage) Load(ctx context.Context, path string, content io.Writer) error {
+	name, err := objectName(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if errors.Is(err, &HTTPError{StatusCode: http.StatusNotFound}) {
+		return fmt.Errorf("%w: %s", ErrFileNotFound, path)
+	}
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(content, resp.Body); err != nil {
+		return fmt.Errorf("error downloading %s: %w", path, err)
+	}
+	return nil
+}
+
+// Close releases nothing, connections are reused across operations.
+func (s *GCSStorage) Close() error {
+	return nil
+}
+
+func (s *GCSStorage) do(req *http.Request) (*http.Response, error) {
+	if s.tokens != nil {
+		token, err := s.tokens.Token(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return s.client.Do(req)
+}
+
+// objectName rejects the paths that aren't valid or would be confusing as
+// object names.
+func objectName(path string) (string, error) {
+	if path == "" || strings.HasPrefix(path, "/") || strings.ContainsRune(path, '\\') {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, path)
+	}
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+	}
+	return path, nil
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	dirPerm  = 0o755
+	filePerm = 0o644
+)
+
+// LocalStorage is a FileStorageProvider storing files under a directory.
+// Paths are relative to the directory and can't escape it.
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage returns a LocalStorage rooted at dir, creating it if needed.
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("error creating storage directory: %w", err)
+	}
+
+	// resolve symlinks once so the containment checks compare real paths
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{root: root}, nil
+}
+
+// Save writes the content to the path atomically: readers see either the
+// previous file or the complete new one, even if the write fails midway.
+func (s *LocalStorage) Save(ctx context.Context, path string, content io.Reader) error {
+	dst, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+
+	// a symlinked directory could point outside of the root, it's checked
+	// before any directory is created through it
+	dir := filepath.Dir(dst)
+	if err := s.contain(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := io.Copy(tmp, &ctxReader{ctx: ctx, r: content}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), filePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("error renaming %s: %w", path, err)
+	}
+
+	// persist the rename, not every filesystem supports syncing directories
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// Load copies the file at path to content.
+func (s *LocalStorage) Load(ctx context.Context, path string, content io.Writer) error {
+	src, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := s.contain(src); err != nil {
+		return err
+	}
+
+	f, err := os.Open(src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrFileNotFound, path)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(content, &ctxReader{ctx: ctx, r: f}); err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return nil
+}
+
+// Close releases nothing, files are closed after every operation.
+func (s *LocalStorage) Close() error {
+	return nil
+}
+
+// resolve returns the absolute path of a slash separated relative path.
+func (s *LocalStorage) resolve(path string) (string, error) {
+	p := filepath.FromSlash(path)
+	if !filepath.IsLocal(p) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, path)
+	}
+	return filepath.Join(s.root, p), nil
+}
+
+// contain makes sure a path doesn't resolve outside of the root through a
+// symlink. A missing path is checked through its nearest existing ancestor.
+func (s *LocalStorage) contain(path string) error {
+	existing, err := existingAncestor(path)
+	if err != nil {
+		return err
+	}
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	if real != s.root && !strings.HasPrefix(real, s.root+string(filepath.Separator)) {
+		return fmt.Errorf("%w: %q resolves outside of the storage directory", ErrInvalidPath, path)
+	}
+	return nil
+}
+
+// existingAncestor returns the path itself or its nearest ancestor that
+// exists.
+func existingAncestor(path string) (string, error) {
+	for {
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		path = parent
+	}
+}
+
+// ctxReader stops reading once the context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
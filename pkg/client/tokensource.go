@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// MetadataTokenURL hands out access tokens for the service account of
+	// the instance on GCP
+	MetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	// tokens are refreshed this long before they expire
+	tokenRefreshMargin = time.Minute
+)
+
+// TokenSource returns OAuth2 access tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+type accessToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// MetadataTokenSource fetches access tokens from the GCP metadata server
+// and caches them until they expire.
+type MetadataTokenSource struct {
+	client Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewMetadataTokenSource returns a TokenSource backed by the metadata server.
+func NewMetadataTokenSource(opts ...Option) *MetadataTokenSource {
+	return &MetadataTokenSource{client: NewHTTPClient(opts...)}
+}
+
+// Token returns a valid access token.
+func (ts *MetadataTokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && time.Now().Before(ts.expires) {
+		return ts.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, MetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var tok accessToken
+	if err := ts.client.DoJSON(req, &tok); err != nil {
+		return "", fmt.Errorf("error fetching access token: %w", err)
+	}
+
+	ts.token = tok.AccessToken
+	ts.expires = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - tokenRefreshMargin)
+	return ts.token, nil
+}
@@ -81,6 +81,13 @@ func Conflict(ctx context.Context, wl wlog.Logger, w http.ResponseWriter, err er
 	JSONErr(ctx, wl, w, err, http.StatusConflict)
 }
 
+// UnprocessableEntity writes the json-encoded error message to the response
+// with a 422 unprocessable entity status code.
+func UnprocessableEntity(ctx context.Context, wl wlog.Logger, w http.ResponseWriter, err error) {
+	wl.Info(err.Error())
+	JSONErr(ctx, wl, w, err, http.StatusUnprocessableEntity)
+}
+
 // TooManyRequests writes the json-encoded error message to the response
 // with a 429 too many requests status code.
 func TooManyRequests(ctx context.Context, wl wlog.Logger, w http.ResponseWriter, err error) {
@@ -88,6 +95,13 @@ func TooManyRequests(ctx context.Context, wl wlog.Logger, w http.ResponseWriter,
 	JSONErr(ctx, wl, w, err, http.StatusTooManyRequests)
 }
 
+// RequestEntityTooLarge writes the json-encoded error message to the response
+// with a 413 request entity too large status code.
+func RequestEntityTooLarge(ctx context.Context, wl wlog.Logger, w http.ResponseWriter, err error) {
+	wl.Info(err.Error())
+	JSONErr(ctx, wl, w, err, http.StatusRequestEntityTooLarge)
+}
+
 // UpgradeRequired responds with a 412 status codes and includes the
 // the a friendly user message in the response body.
 func UpgradeRequired(ctx context.Context, wl wlog.Logger, w http.ResponseWriter) {
@@ -115,6 +129,22 @@ func ImagePNG(ctx context.Context, wl wlog.Logger, w http.ResponseWriter, b []by
 	}
 }
 
+// ImageSVG writes the svg document to the response.
+func ImageSVG(ctx context.Context, wl wlog.Logger, w http.ResponseWriter, b []byte) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.WriteHeader(http.StatusOK)
+
+	if b == nil {
+		return
+	}
+	n, err := w.Write(b)
+	if err != nil {
+		wl.Error(err)
+	} else if n != len(b) {
+		wl.Error(errors.New("failed to write all bytes"))
+	}
+}
+
 // ACKPushEvent acknowledges a PubSub events
 func ACKPushEvent(ctx context.Context, wl wlog.Logger, w http.ResponseWriter, err error) {
 	if err != nil {
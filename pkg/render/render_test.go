@@ -208,3 +208,51 @@ func TestConflict(t *testing.T) {
 		assert.Equal(t, strings.TrimSpace(w.Body.String()), tc.expectedResp)
 	}
 }
+
+func TestRequestEntityTooLarge(t *testing.T) {
+	testData := []struct {
+		name         string
+		input        error
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			"happy path",
+			render.NewErrorStr("fake error"),
+			413,
+			`{"error":"fake error"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		w := httptest.NewRecorder()
+		render.RequestEntityTooLarge(context.Background(), wlog.NewNopLogger(), w, tc.input)
+
+		assert.Equal(t, w.Code, tc.expectedCode)
+		assert.Equal(t, strings.TrimSpace(w.Body.String()), tc.expectedResp)
+	}
+}
+
+func TestUnprocessableEntity(t *testing.T) {
+	testData := []struct {
+		name         string
+		input        error
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			"happy path",
+			render.NewErrorStr("fake error"),
+			422,
+			`{"error":"fake error"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		w := httptest.NewRecorder()
+		render.UnprocessableEntity(context.Background(), wlog.NewNopLogger(), w, tc.input)
+
+		assert.Equal(t, w.Code, tc.expectedCode)
+		assert.Equal(t, strings.TrimSpace(w.Body.String()), tc.expectedResp)
+	}
+}